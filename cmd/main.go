@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/config"
 	"github.com/waltertaya/server_check_bd/internal/db"
 	"github.com/waltertaya/server_check_bd/internal/handlers"
 	"github.com/waltertaya/server_check_bd/internal/logger"
@@ -13,11 +14,20 @@ import (
 )
 
 func main() {
+	// Initialize configuration
+	config.Init()
+
 	// Initialize logger
 	if err := logger.Init(logger.INFO, "logs/app.log"); err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
+	// Load the key used to encrypt secrets at rest
+	if err := config.InitEncryptionKey(); err != nil {
+		logger.Error("Failed to initialize encryption key: %v", err)
+		return
+	}
+
 	// Initialize database
 	database, err := db.ConnectDB()
 	if err != nil {
@@ -34,12 +44,42 @@ func main() {
 
 	// Initialize services
 	serverService := services.NewServerService(database)
-	healthChecker := services.NewHealthChecker(serverService)
+	certificateService := services.NewCertificateService(database)
+	auditService := services.NewAuditService(database)
+	maintenanceService := services.NewMaintenanceService(database)
+	groupService := services.NewGroupService(database, serverService)
+	historyService := services.NewHistoryService(database)
+	notificationService := services.NewNotificationService(database)
+	incidentService := services.NewIncidentService(database, notificationService)
+	analyticsService := services.NewAnalyticsService(database, historyService)
+	sloService := services.NewSLOService(database, serverService)
+	sloAlerter := services.NewSLOAlerter(database, sloService, notificationService)
+	reportService := services.NewReportService(database, serverService, historyService, services.NewMailer())
+	transportManager := services.NewTransportManager(certificateService)
+	securityService := services.NewSecurityService(database, transportManager)
+	crawlerService := services.NewCrawlerService(database, transportManager)
+	healthChecker := services.NewHealthChecker(serverService, securityService, crawlerService, maintenanceService, incidentService, notificationService, transportManager)
 	go healthChecker.Start()
+	retentionService := services.NewRetentionService(database)
+	go retentionService.Start()
+	go sloAlerter.Start()
+	go reportService.Start()
+	go notificationService.Start()
 
 	// Initialize handlers
 	authHandlers := handlers.NewAuthHandlers(database)
-	serverHandlers := handlers.NewServerHandlers(serverService, healthChecker)
+	serverHandlers := handlers.NewServerHandlers(serverService, healthChecker, auditService, maintenanceService, historyService)
+	securityHandlers := handlers.NewSecurityHandlers(securityService)
+	crawlHandlers := handlers.NewCrawlHandlers(crawlerService)
+	certificateHandlers := handlers.NewCertificateHandlers(certificateService)
+	auditHandlers := handlers.NewAuditHandlers(auditService)
+	maintenanceHandlers := handlers.NewMaintenanceHandlers(maintenanceService)
+	groupHandlers := handlers.NewGroupHandlers(groupService)
+	sloHandlers := handlers.NewSLOHandlers(sloService, sloAlerter)
+	reportHandlers := handlers.NewReportHandlers(reportService)
+	incidentHandlers := handlers.NewIncidentHandlers(incidentService)
+	analyticsHandlers := handlers.NewAnalyticsHandlers(analyticsService)
+	notificationHandlers := handlers.NewNotificationHandlers(notificationService)
 	wsHandler := handlers.NewWebSocketHandler(healthChecker)
 
 	// Initialize router
@@ -50,7 +90,7 @@ func main() {
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -58,14 +98,100 @@ func main() {
 	// Auth routes
 	router.POST("/api/auth/register", authHandlers.RegisterHandler)
 	router.POST("/api/auth/login", authHandlers.LoginHandler)
+	router.POST("/api/auth/logout", authHandlers.LogoutHandler)
 
 	// Server routes
 	router.GET("/api/servers", serverHandlers.GetServers)
 	router.GET("/api/servers/:id", serverHandlers.GetServer)
 	router.POST("/api/servers", serverHandlers.CreateServer)
+	router.POST("/api/servers/test", serverHandlers.TestServer)
 	router.PUT("/api/servers/:id", serverHandlers.UpdateServer)
 	router.DELETE("/api/servers/:id", serverHandlers.DeleteServer)
 	router.GET("/api/servers/:id/history", serverHandlers.GetServerHistory)
+	router.PUT("/api/servers/:id/tags/:key", serverHandlers.SetServerTag)
+	router.DELETE("/api/servers/:id/tags/:key", serverHandlers.DeleteServerTag)
+	router.POST("/api/servers/:id/pause", serverHandlers.PauseServer)
+	router.POST("/api/servers/:id/resume", serverHandlers.ResumeServer)
+	router.POST("/api/servers/:id/check", serverHandlers.CheckServer)
+	router.GET("/api/servers/:id/security", securityHandlers.GetServerSecurity)
+	router.GET("/api/servers/:id/crawls", crawlHandlers.GetCrawlReports)
+	router.GET("/api/servers/:id/crawls/:reportId", crawlHandlers.GetCrawlReport)
+	router.GET("/api/servers/:id/slo", sloHandlers.GetServerSLO)
+	router.GET("/api/servers/:id/analytics", analyticsHandlers.GetServerAnalytics)
+
+	// History across servers, filtered by tag or group
+	router.GET("/api/history", serverHandlers.GetHistory)
+
+	// Tag and group routes
+	router.GET("/api/tags", serverHandlers.GetTags)
+	router.GET("/api/groups", groupHandlers.GetGroups)
+	router.GET("/api/groups/:id", groupHandlers.GetGroup)
+	router.POST("/api/groups", groupHandlers.CreateGroup)
+	router.PUT("/api/groups/:id", groupHandlers.UpdateGroup)
+	router.DELETE("/api/groups/:id", groupHandlers.DeleteGroup)
+	router.GET("/api/groups/:id/slo", sloHandlers.GetGroupSLO)
+	router.GET("/api/groups/:id/analytics", analyticsHandlers.GetGroupAnalytics)
+
+	// SLO routes
+	router.GET("/api/slos", sloHandlers.GetSLOs)
+	router.GET("/api/slos/:id", sloHandlers.GetSLO)
+	router.POST("/api/slos", sloHandlers.CreateSLO)
+	router.PUT("/api/slos/:id", sloHandlers.UpdateSLO)
+	router.DELETE("/api/slos/:id", sloHandlers.DeleteSLO)
+	router.GET("/api/slos/:id/alerts", sloHandlers.GetSLOAlerts)
+	router.GET("/api/alerts", sloHandlers.GetAlerts)
+
+	// Incident routes
+	router.GET("/api/incidents", incidentHandlers.GetIncidents)
+	router.GET("/api/incidents/:id", incidentHandlers.GetIncident)
+	router.POST("/api/incidents", incidentHandlers.CreateIncident)
+	router.PUT("/api/incidents/:id", incidentHandlers.UpdateIncident)
+	router.DELETE("/api/incidents/:id", incidentHandlers.DeleteIncident)
+	router.POST("/api/incidents/:id/notes", incidentHandlers.AddIncidentNote)
+	router.POST("/api/incidents/:id/acknowledge", authHandlers.RequireAuth, incidentHandlers.AcknowledgeIncident)
+	router.POST("/api/incidents/:id/unacknowledge", authHandlers.RequireAuth, incidentHandlers.UnacknowledgeIncident)
+	router.POST("/api/incidents/:id/assign", authHandlers.RequireAuth, incidentHandlers.AssignIncident)
+
+	// Notification routes
+	router.GET("/api/notification-channels", notificationHandlers.GetChannels)
+	router.GET("/api/notification-channels/:id", notificationHandlers.GetChannel)
+	router.POST("/api/notification-channels", notificationHandlers.CreateChannel)
+	router.PUT("/api/notification-channels/:id", notificationHandlers.UpdateChannel)
+	router.DELETE("/api/notification-channels/:id", notificationHandlers.DeleteChannel)
+	router.POST("/api/notification-channels/:id/test", notificationHandlers.TestChannel)
+	router.GET("/api/notification-channels/:id/deliveries", notificationHandlers.GetChannelDeliveries)
+	router.GET("/api/notification-deliveries", notificationHandlers.GetDeliveries)
+
+	// Report routes
+	router.GET("/api/reports", reportHandlers.GetReports)
+	router.GET("/api/reports/:id", reportHandlers.GetReport)
+	router.POST("/api/reports", reportHandlers.CreateReport)
+	router.DELETE("/api/reports/:id", reportHandlers.DeleteReport)
+	router.GET("/api/report-schedules", reportHandlers.GetSchedules)
+	router.GET("/api/report-schedules/:id", reportHandlers.GetSchedule)
+	router.POST("/api/report-schedules", reportHandlers.CreateSchedule)
+	router.PUT("/api/report-schedules/:id", reportHandlers.UpdateSchedule)
+	router.DELETE("/api/report-schedules/:id", reportHandlers.DeleteSchedule)
+	router.POST("/api/report-schedules/:id/run", reportHandlers.RunSchedule)
+
+	// Dependency routes
+	router.GET("/api/dependencies", serverHandlers.GetDependencyGraph)
+
+	// Certificate routes
+	router.GET("/api/certificates", certificateHandlers.GetCertificates)
+	router.GET("/api/certificates/:id", certificateHandlers.GetCertificate)
+	router.POST("/api/certificates", certificateHandlers.CreateCertificate)
+	router.DELETE("/api/certificates/:id", certificateHandlers.DeleteCertificate)
+
+	// Maintenance window routes
+	router.GET("/api/maintenance", maintenanceHandlers.GetWindows)
+	router.GET("/api/maintenance/:id", maintenanceHandlers.GetWindow)
+	router.POST("/api/maintenance", maintenanceHandlers.CreateWindow)
+	router.PUT("/api/maintenance/:id", maintenanceHandlers.UpdateWindow)
+	router.DELETE("/api/maintenance/:id", maintenanceHandlers.DeleteWindow)
+
+	// Audit log routes
+	router.GET("/api/audit-log", auditHandlers.GetAuditLog)
 
 	// WebSocket route
 	router.GET("/api/servers/:id/ws", wsHandler.HandleWebSocket)
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +30,75 @@ var (
 
 	// DefaultTimeout is the default timeout for HTTP requests
 	DefaultTimeout = 5 * time.Second
+
+	// SecurityAuditInterval is the default interval between security audits of a server
+	SecurityAuditInterval = 1 * time.Hour
+
+	// DefaultProxy is the proxy URL used by checks that do not configure their own
+	DefaultProxy string
+
+	// EncryptionKey is the key used to encrypt secrets stored in the database
+	EncryptionKey []byte
+
+	// DefaultCrawlDepth is the default number of link hops followed by crawler monitors
+	DefaultCrawlDepth = 2
+
+	// DefaultCrawlMaxPages is the default page budget of a single crawl
+	DefaultCrawlMaxPages = 100
+
+	// HistoryRetention is how long raw check history is kept before it is rolled up and deleted, 0 keeps it forever
+	HistoryRetention = 7 * 24 * time.Hour
+
+	// MinuteRollupRetention is how long per-minute history rollups are kept, 0 keeps them forever
+	MinuteRollupRetention = 30 * 24 * time.Hour
+
+	// HourlyRollupRetention is how long hourly history rollups are kept, 0 keeps them forever.
+	// Hourly rollups back the 30 day uptime of servers once raw history is gone.
+	HourlyRollupRetention = 365 * 24 * time.Hour
+
+	// DailyRollupRetention is how long daily history rollups are kept, 0 keeps them forever
+	DailyRollupRetention time.Duration
+
+	// RetentionInterval is the interval between runs of the history retention job, 0 disables retention
+	RetentionInterval = 1 * time.Hour
+
+	// SMTPHost is the SMTP server used to send email, email is disabled when empty
+	SMTPHost string
+
+	// SMTPPort is the port of the SMTP server
+	SMTPPort string
+
+	// SMTPUsername and SMTPPassword authenticate with the SMTP server when set
+	SMTPUsername string
+	SMTPPassword string
+
+	// SMTPFrom is the sender address of emails
+	SMTPFrom string
+
+	// SMTPTLS secures the SMTP connection, "starttls" when the server supports it, "tls" for
+	// implicit TLS or "none"
+	SMTPTLS string
+
+	// SLOAlertInterval is the interval between evaluations of SLO burn rate alerts, 0 disables alerting
+	SLOAlertInterval = 1 * time.Minute
+
+	// SessionTTL is how long a login token stays valid
+	SessionTTL = 24 * time.Hour
+
+	// IncidentRepeatInterval is how often an unacknowledged open incident is notified again, 0 disables reminders
+	IncidentRepeatInterval = 30 * time.Minute
+
+	// CertificateExpiryWarning is how long before the TLS certificate of a server expires that its channels are notified, 0 disables the notification
+	CertificateExpiryWarning = 14 * 24 * time.Hour
+
+	// DashboardURL is the address of the dashboard that notifications link back to, links are left out when empty
+	DashboardURL string
+
+	// NotificationMaxAttempts is how many times a notification is attempted before its delivery fails
+	NotificationMaxAttempts = 5
+
+	// NotificationRetryDelay is the delay before the first retry of a failed notification, doubled on every further retry
+	NotificationRetryDelay = 1 * time.Minute
 )
 
 // Init initializes the configuration
@@ -36,11 +111,73 @@ func Init() {
 	// Set up logs directory
 	LogDir = getEnv("LOG_DIR", "logs")
 
+	// Set up outbound proxy for checks
+	DefaultProxy = getEnv("CHECK_PROXY_URL", "")
+
+	// Set up history retention
+	HistoryRetention = getDuration("HISTORY_RETENTION", HistoryRetention)
+	MinuteRollupRetention = getDuration("HISTORY_MINUTE_ROLLUP_RETENTION", MinuteRollupRetention)
+	HourlyRollupRetention = getDuration("HISTORY_HOURLY_ROLLUP_RETENTION", HourlyRollupRetention)
+	DailyRollupRetention = getDuration("HISTORY_DAILY_ROLLUP_RETENTION", DailyRollupRetention)
+	RetentionInterval = getDuration("HISTORY_RETENTION_INTERVAL", RetentionInterval)
+
+	// Set up email delivery
+	SMTPHost = getEnv("SMTP_HOST", "")
+	SMTPPort = getEnv("SMTP_PORT", "25")
+	SMTPUsername = getEnv("SMTP_USERNAME", "")
+	SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	SMTPFrom = getEnv("SMTP_FROM", "monitor@localhost")
+	SMTPTLS = getEnv("SMTP_TLS", "starttls")
+
+	// Set up SLO alerting
+	SLOAlertInterval = getDuration("SLO_ALERT_INTERVAL", SLOAlertInterval)
+
+	// Set up authentication
+	SessionTTL = getDuration("SESSION_TTL", SessionTTL)
+
+	// Set up incident reminders
+	IncidentRepeatInterval = getDuration("INCIDENT_REPEAT_INTERVAL", IncidentRepeatInterval)
+
+	// Set up notifications
+	CertificateExpiryWarning = getDuration("CERTIFICATE_EXPIRY_WARNING", CertificateExpiryWarning)
+	DashboardURL = strings.TrimSuffix(getEnv("DASHBOARD_URL", ""), "/")
+	NotificationMaxAttempts = getInt("NOTIFICATION_MAX_ATTEMPTS", NotificationMaxAttempts)
+	NotificationRetryDelay = getDuration("NOTIFICATION_RETRY_DELAY", NotificationRetryDelay)
+
 	// Create directories if they don't exist
 	os.MkdirAll(DataDir, 0755)
 	os.MkdirAll(LogDir, 0755)
 }
 
+// InitEncryptionKey loads the key used to encrypt secrets at rest. The key is derived from
+// the ENCRYPTION_KEY environment variable, or generated once and kept in the data directory.
+func InitEncryptionKey() error {
+	secret := os.Getenv("ENCRYPTION_KEY")
+	if secret == "" {
+		keyFile := filepath.Join(DataDir, "secret.key")
+		data, err := os.ReadFile(keyFile)
+		switch {
+		case err == nil:
+			secret = strings.TrimSpace(string(data))
+		case os.IsNotExist(err):
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return fmt.Errorf("failed to generate encryption key: %w", err)
+			}
+			secret = hex.EncodeToString(key)
+			if err := os.WriteFile(keyFile, []byte(secret), 0600); err != nil {
+				return fmt.Errorf("failed to write encryption key: %w", err)
+			}
+		default:
+			return fmt.Errorf("failed to read encryption key: %w", err)
+		}
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	EncryptionKey = key[:]
+	return nil
+}
+
 // getEnv returns the value of an environment variable or a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -48,4 +185,35 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
+
+// getInt returns the positive integer in an environment variable or a default value when it
+// is unset or invalid
+func getInt(key string, defaultValue int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return defaultValue
+	}
+	return n
+}
+
+// getDuration returns the duration in an environment variable or a default value when it is
+// unset or invalid. Besides Go durations such as "12h", a number of days such as "30d" is accepted.
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return defaultValue
+		}
+		return time.Duration(n) * 24 * time.Hour
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return defaultValue
+	}
+	return duration
+}
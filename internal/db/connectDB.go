@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
@@ -15,8 +16,10 @@ func ConnectDB() (*sqlx.DB, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Open SQLite database
-	db, err := sqlx.Connect("sqlite3", "data/server_monitor.db")
+	// Open SQLite database. Writers wait for locks held by other connections, such as the
+	// retention job, instead of failing, and transactions take the write lock up front so
+	// two of them cannot deadlock upgrading from a read.
+	db, err := sqlx.Connect("sqlite3", "data/server_monitor.db?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -33,8 +36,33 @@ func ConnectDB() (*sqlx.DB, error) {
 func RunMigrations(db *sqlx.DB) error {
 	// Drop existing tables if they exist
 	_, err := db.Exec(`
+		DROP TABLE IF EXISTS maintenance_window_tags;
+		DROP TABLE IF EXISTS maintenance_window_servers;
+		DROP TABLE IF EXISTS maintenance_windows;
+		DROP TABLE IF EXISTS crawl_broken_links;
+		DROP TABLE IF EXISTS crawl_reports;
+		DROP TABLE IF EXISTS security_reports;
+		DROP TABLE IF EXISTS server_dependencies;
+		DROP TABLE IF EXISTS notification_threads;
+		DROP TABLE IF EXISTS notification_deliveries;
+		DROP TABLE IF EXISTS notification_channel_events;
+		DROP TABLE IF EXISTS notification_bindings;
+		DROP TABLE IF EXISTS notification_channels;
+		DROP TABLE IF EXISTS incident_events;
+		DROP TABLE IF EXISTS incidents;
+		DROP TABLE IF EXISTS reports;
+		DROP TABLE IF EXISTS report_recipients;
+		DROP TABLE IF EXISTS report_schedules;
+		DROP TABLE IF EXISTS slo_alerts;
+		DROP TABLE IF EXISTS slos;
+		DROP TABLE IF EXISTS history_rollups;
 		DROP TABLE IF EXISTS status_history;
+		DROP TABLE IF EXISTS server_tags;
 		DROP TABLE IF EXISTS servers;
+		DROP TABLE IF EXISTS server_groups;
+		DROP TABLE IF EXISTS certificates;
+		DROP TABLE IF EXISTS audit_log;
+		DROP TABLE IF EXISTS sessions;
 		DROP TABLE IF EXISTS users;
 	`)
 	if err != nil {
@@ -54,25 +82,127 @@ func RunMigrations(db *sqlx.DB) error {
 		return fmt.Errorf("failed to create users table: %v", err)
 	}
 
+	// Create sessions table
+	_, err = db.Exec(`
+		CREATE TABLE sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create sessions table: %v", err)
+	}
+
+	// Create certificates table
+	_, err = db.Exec(`
+		CREATE TABLE certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			issuer TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			not_after TIMESTAMP NOT NULL,
+			certificate TEXT NOT NULL,
+			private_key TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create certificates table: %v", err)
+	}
+
+	// Create audit_log table
+	_, err = db.Exec(`
+		CREATE TABLE audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			actor TEXT NOT NULL,
+			details TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create audit_log table: %v", err)
+	}
+
+	// Create server_groups table
+	_, err = db.Exec(`
+		CREATE TABLE server_groups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			parent_id INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (parent_id) REFERENCES server_groups(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create server_groups table: %v", err)
+	}
+
 	// Create servers table
 	_, err = db.Exec(`
 		CREATE TABLE servers (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
 			description TEXT,
+			type TEXT NOT NULL DEFAULT 'http',
 			url TEXT NOT NULL,
 			method TEXT NOT NULL,
 			interval INTEGER NOT NULL,
 			timeout INTEGER NOT NULL,
 			expected_status INTEGER NOT NULL,
+			security_audit BOOLEAN NOT NULL DEFAULT 0,
+			audit_interval INTEGER NOT NULL DEFAULT 0,
+			crawl_depth INTEGER NOT NULL DEFAULT 0,
+			crawl_max_pages INTEGER NOT NULL DEFAULT 0,
+			proxy_url TEXT NOT NULL DEFAULT '',
+			resolver_address TEXT NOT NULL DEFAULT '',
+			resolve_ip TEXT NOT NULL DEFAULT '',
+			source_address TEXT NOT NULL DEFAULT '',
+			client_cert_id INTEGER,
+			ca_bundle_id INTEGER,
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT 0,
+			connection_mode TEXT NOT NULL DEFAULT 'keepalive',
+			address_family TEXT NOT NULL DEFAULT 'auto',
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			group_id INTEGER,
+			last_state TEXT NOT NULL DEFAULT '',
+			last_checked_at TIMESTAMP,
+			last_response_time INTEGER,
+			certificate_expires_at TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (client_cert_id) REFERENCES certificates(id),
+			FOREIGN KEY (ca_bundle_id) REFERENCES certificates(id),
+			FOREIGN KEY (group_id) REFERENCES server_groups(id)
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to create servers table: %v", err)
 	}
 
+	// Create server_tags table
+	_, err = db.Exec(`
+		CREATE TABLE server_tags (
+			server_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (server_id, key),
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create server_tags table: %v", err)
+	}
+
 	// Create status_history table
 	_, err = db.Exec(`
 		CREATE TABLE status_history (
@@ -83,6 +213,9 @@ func RunMigrations(db *sqlx.DB) error {
 			response_time INTEGER,
 			response_body TEXT,
 			error TEXT,
+			state TEXT NOT NULL DEFAULT '',
+			address_family TEXT NOT NULL DEFAULT 'auto',
+			root_cause_id INTEGER,
 			checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (server_id) REFERENCES servers(id)
 		)
@@ -91,5 +224,408 @@ func RunMigrations(db *sqlx.DB) error {
 		return fmt.Errorf("failed to create status_history table: %v", err)
 	}
 
+	// Create history_rollups table
+	_, err = db.Exec(`
+		CREATE TABLE history_rollups (
+			server_id INTEGER NOT NULL,
+			resolution TEXT NOT NULL,
+			bucket_start TIMESTAMP NOT NULL,
+			checks INTEGER NOT NULL,
+			failures INTEGER NOT NULL,
+			maintenance INTEGER NOT NULL,
+			latency_count INTEGER NOT NULL,
+			latency_sum INTEGER NOT NULL,
+			min INTEGER,
+			max INTEGER,
+			p50 INTEGER,
+			p95 INTEGER,
+			p99 INTEGER,
+			PRIMARY KEY (server_id, resolution, bucket_start),
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create history_rollups table: %v", err)
+	}
+
+	// Create slos table
+	_, err = db.Exec(`
+		CREATE TABLE slos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			server_id INTEGER,
+			group_id INTEGER,
+			type TEXT NOT NULL,
+			target REAL NOT NULL,
+			threshold INTEGER,
+			window_days INTEGER NOT NULL DEFAULT 30,
+			calendar_period TEXT NOT NULL DEFAULT 'month',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (server_id) REFERENCES servers(id),
+			FOREIGN KEY (group_id) REFERENCES server_groups(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create slos table: %v", err)
+	}
+
+	// Create slo_alerts table
+	_, err = db.Exec(`
+		CREATE TABLE slo_alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slo_id INTEGER NOT NULL,
+			severity TEXT NOT NULL,
+			state TEXT NOT NULL,
+			long_window INTEGER NOT NULL,
+			short_window INTEGER NOT NULL,
+			threshold REAL NOT NULL,
+			long_burn_rate REAL NOT NULL,
+			short_burn_rate REAL NOT NULL,
+			fired_at TIMESTAMP NOT NULL,
+			resolved_at TIMESTAMP,
+			FOREIGN KEY (slo_id) REFERENCES slos(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create slo_alerts table: %v", err)
+	}
+
+	// Create report_schedules table
+	_, err = db.Exec(`
+		CREATE TABLE report_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			period TEXT NOT NULL,
+			schedule TEXT NOT NULL,
+			group_id INTEGER,
+			tag TEXT NOT NULL DEFAULT '',
+			last_run_at TIMESTAMP,
+			next_run_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (group_id) REFERENCES server_groups(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create report_schedules table: %v", err)
+	}
+
+	// Create report_recipients table
+	_, err = db.Exec(`
+		CREATE TABLE report_recipients (
+			schedule_id INTEGER NOT NULL,
+			email TEXT NOT NULL,
+			PRIMARY KEY (schedule_id, email),
+			FOREIGN KEY (schedule_id) REFERENCES report_schedules(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create report_recipients table: %v", err)
+	}
+
+	// Create reports table
+	_, err = db.Exec(`
+		CREATE TABLE reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id INTEGER,
+			name TEXT NOT NULL,
+			period TEXT NOT NULL,
+			period_start TIMESTAMP NOT NULL,
+			period_end TIMESTAMP NOT NULL,
+			group_id INTEGER,
+			tag TEXT NOT NULL DEFAULT '',
+			html TEXT NOT NULL,
+			csv TEXT NOT NULL,
+			delivered_at TIMESTAMP,
+			delivery_error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (schedule_id) REFERENCES report_schedules(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create reports table: %v", err)
+	}
+
+	// Create incidents table
+	_, err = db.Exec(`
+		CREATE TABLE incidents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			status TEXT NOT NULL,
+			automatic BOOLEAN NOT NULL DEFAULT 0,
+			started_at TIMESTAMP NOT NULL,
+			resolved_at TIMESTAMP,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMP,
+			last_error TEXT NOT NULL DEFAULT '',
+			acknowledged_by INTEGER,
+			acknowledged_at TIMESTAMP,
+			assignee_id INTEGER,
+			last_notified_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (server_id) REFERENCES servers(id),
+			FOREIGN KEY (acknowledged_by) REFERENCES users(id),
+			FOREIGN KEY (assignee_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create incidents table: %v", err)
+	}
+
+	// Create incident_events table
+	_, err = db.Exec(`
+		CREATE TABLE incident_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			incident_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			actor TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (incident_id) REFERENCES incidents(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create incident_events table: %v", err)
+	}
+
+	// Create notification_channels table
+	_, err = db.Exec(`
+		CREATE TABLE notification_channels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			config TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_channels table: %v", err)
+	}
+
+	// Create notification_bindings table
+	_, err = db.Exec(`
+		CREATE TABLE notification_bindings (
+			channel_id INTEGER NOT NULL,
+			server_id INTEGER,
+			tag_key TEXT,
+			tag_value TEXT,
+			FOREIGN KEY (channel_id) REFERENCES notification_channels(id),
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_bindings table: %v", err)
+	}
+
+	// Create notification_channel_events table
+	_, err = db.Exec(`
+		CREATE TABLE notification_channel_events (
+			channel_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			PRIMARY KEY (channel_id, event),
+			FOREIGN KEY (channel_id) REFERENCES notification_channels(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_channel_events table: %v", err)
+	}
+
+	// Create notification_deliveries table
+	_, err = db.Exec(`
+		CREATE TABLE notification_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel_id INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			title TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP,
+			last_error TEXT NOT NULL DEFAULT '',
+			response_status INTEGER,
+			created_at TIMESTAMP NOT NULL,
+			delivered_at TIMESTAMP,
+			FOREIGN KEY (channel_id) REFERENCES notification_channels(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_deliveries table: %v", err)
+	}
+
+	// Create notification_threads table
+	_, err = db.Exec(`
+		CREATE TABLE notification_threads (
+			channel_id INTEGER NOT NULL,
+			incident_id INTEGER NOT NULL,
+			thread_id TEXT NOT NULL,
+			PRIMARY KEY (channel_id, incident_id),
+			FOREIGN KEY (channel_id) REFERENCES notification_channels(id),
+			FOREIGN KEY (incident_id) REFERENCES incidents(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_threads table: %v", err)
+	}
+
+	// Create server_dependencies table
+	_, err = db.Exec(`
+		CREATE TABLE server_dependencies (
+			server_id INTEGER NOT NULL,
+			parent_id INTEGER NOT NULL,
+			PRIMARY KEY (server_id, parent_id),
+			FOREIGN KEY (server_id) REFERENCES servers(id),
+			FOREIGN KEY (parent_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create server_dependencies table: %v", err)
+	}
+
+	// Create security_reports table
+	_, err = db.Exec(`
+		CREATE TABLE security_reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server_id INTEGER NOT NULL,
+			grade TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			tls_version TEXT NOT NULL DEFAULT '',
+			cipher_suite TEXT NOT NULL DEFAULT '',
+			accepts_tls10 BOOLEAN NOT NULL DEFAULT 0,
+			accepts_tls11 BOOLEAN NOT NULL DEFAULT 0,
+			findings TEXT NOT NULL DEFAULT '[]',
+			error TEXT,
+			checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create security_reports table: %v", err)
+	}
+
+	// Create crawl_reports table
+	_, err = db.Exec(`
+		CREATE TABLE crawl_reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server_id INTEGER NOT NULL,
+			pages_crawled INTEGER NOT NULL,
+			links_checked INTEGER NOT NULL,
+			broken_count INTEGER NOT NULL,
+			duration INTEGER NOT NULL,
+			error TEXT,
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create crawl_reports table: %v", err)
+	}
+
+	// Create crawl_broken_links table
+	_, err = db.Exec(`
+		CREATE TABLE crawl_broken_links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			report_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			referrer TEXT NOT NULL,
+			status_code INTEGER,
+			error TEXT,
+			FOREIGN KEY (report_id) REFERENCES crawl_reports(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create crawl_broken_links table: %v", err)
+	}
+
+	// Create maintenance_windows table
+	_, err = db.Exec(`
+		CREATE TABLE maintenance_windows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			starts_at TIMESTAMP NOT NULL,
+			duration INTEGER NOT NULL,
+			recurrence TEXT NOT NULL DEFAULT '',
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance_windows table: %v", err)
+	}
+
+	// Create maintenance_window_servers table
+	_, err = db.Exec(`
+		CREATE TABLE maintenance_window_servers (
+			window_id INTEGER NOT NULL,
+			server_id INTEGER NOT NULL,
+			PRIMARY KEY (window_id, server_id),
+			FOREIGN KEY (window_id) REFERENCES maintenance_windows(id),
+			FOREIGN KEY (server_id) REFERENCES servers(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance_window_servers table: %v", err)
+	}
+
+	// Create maintenance_window_tags table
+	_, err = db.Exec(`
+		CREATE TABLE maintenance_window_tags (
+			window_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (window_id, key, value),
+			FOREIGN KEY (window_id) REFERENCES maintenance_windows(id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance_window_tags table: %v", err)
+	}
+
+	// Create indexes for filtering, sorting and paginating servers and their history.
+	// Expression indexes must match the sort expressions used by ServerService.
+	_, err = db.Exec(`
+		CREATE INDEX idx_servers_name ON servers(name COLLATE NOCASE, id);
+		CREATE INDEX idx_servers_created_at ON servers(created_at, id);
+		CREATE INDEX idx_servers_last_checked_at ON servers(COALESCE(last_checked_at, ''), id);
+		CREATE INDEX idx_servers_last_response_time ON servers(COALESCE(last_response_time, -1), id);
+		CREATE INDEX idx_servers_last_state ON servers(last_state);
+		CREATE INDEX idx_servers_state_rank ON servers((CASE last_state WHEN 'DOWN' THEN 5 WHEN 'UNREACHABLE' THEN 4 WHEN 'DEGRADED' THEN 3 WHEN 'MAINTENANCE' THEN 2 WHEN 'UP' THEN 1 ELSE 0 END), id);
+		CREATE INDEX idx_servers_type ON servers(type);
+		CREATE INDEX idx_servers_group_id ON servers(group_id);
+		CREATE INDEX idx_server_tags_key_value ON server_tags(key, value);
+		CREATE INDEX idx_status_history_server_checked ON status_history(server_id, checked_at);
+		CREATE INDEX idx_status_history_checked_at ON status_history(checked_at);
+		CREATE INDEX idx_history_rollups_resolution ON history_rollups(resolution, bucket_start);
+		CREATE INDEX idx_report_schedules_next_run_at ON report_schedules(next_run_at);
+		CREATE INDEX idx_incidents_server_status ON incidents(server_id, status);
+		CREATE INDEX idx_incidents_started_at ON incidents(started_at);
+		CREATE INDEX idx_incident_events_incident_id ON incident_events(incident_id);
+		CREATE INDEX idx_notification_bindings_channel_id ON notification_bindings(channel_id);
+		CREATE INDEX idx_notification_deliveries_due ON notification_deliveries(status, next_attempt_at);
+		CREATE INDEX idx_notification_deliveries_channel_id ON notification_deliveries(channel_id, created_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create indexes: %v", err)
+	}
+
+	// Full-text index of the name, URL and description of servers, keyed by server ID.
+	// go-sqlite3 only includes FTS5 when built with the sqlite_fts5 tag, without it the
+	// index is left out and ServerService searches with LIKE patterns instead.
+	_, err = db.Exec(`
+		DROP TABLE IF EXISTS servers_fts;
+		CREATE VIRTUAL TABLE servers_fts USING fts5(name, url, description);
+	`)
+	if err != nil && !strings.Contains(err.Error(), "no such module: fts5") {
+		return fmt.Errorf("failed to create servers_fts table: %v", err)
+	}
+
 	return nil
 }
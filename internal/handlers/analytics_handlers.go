@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// defaultAnalyticsRange is the time range of analytics requests without a start time
+const defaultAnalyticsRange = 30 * 24 * time.Hour
+
+// AnalyticsHandlers handles reliability analytics HTTP requests
+type AnalyticsHandlers struct {
+	service *services.AnalyticsService
+}
+
+// NewAnalyticsHandlers creates a new analytics handlers instance
+func NewAnalyticsHandlers(service *services.AnalyticsService) *AnalyticsHandlers {
+	return &AnalyticsHandlers{
+		service: service,
+	}
+}
+
+// GetServerAnalytics handles GET /api/servers/:id/analytics
+func (h *AnalyticsHandlers) GetServerAnalytics(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	from, to, breakdown, err := analyticsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetServerReliability(id, from, to, breakdown)
+	if err != nil {
+		logger.Error("Failed to get server analytics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server analytics"})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetGroupAnalytics handles GET /api/groups/:id/analytics
+func (h *AnalyticsHandlers) GetGroupAnalytics(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid group ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	from, to, breakdown, err := analyticsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetGroupReliability(id, from, to, breakdown)
+	if err != nil {
+		logger.Error("Failed to get group analytics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group analytics"})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// analyticsQuery parses the time range and breakdown of an analytics request, the range
+// defaults to the last 30 days
+func analyticsQuery(c *gin.Context) (time.Time, time.Time, string, error) {
+	fromPtr, err := queryTime(c, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	toPtr, err := queryTime(c, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+
+	to := time.Now().UTC()
+	if toPtr != nil {
+		to = toPtr.UTC()
+	}
+	from := to.Add(-defaultAnalyticsRange)
+	if fromPtr != nil {
+		from = fromPtr.UTC()
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("from must be before to")
+	}
+
+	breakdown := c.Query("breakdown")
+	switch breakdown {
+	case "", models.PeriodWeek, models.PeriodMonth:
+	default:
+		return time.Time{}, time.Time{}, "", fmt.Errorf("invalid breakdown %q, expected week or month", breakdown)
+	}
+	return from, to, breakdown, nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// AuditHandlers handles audit log HTTP requests
+type AuditHandlers struct {
+	service *services.AuditService
+}
+
+// NewAuditHandlers creates a new audit handlers instance
+func NewAuditHandlers(service *services.AuditService) *AuditHandlers {
+	return &AuditHandlers{
+		service: service,
+	}
+}
+
+// GetAuditLog handles GET /api/audit-log
+func (h *AuditHandlers) GetAuditLog(c *gin.Context) {
+	limit := 100 // Default limit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	entries, err := h.service.GetEntries(limit)
+	if err != nil {
+		logger.Error("Failed to get audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
 	"github.com/waltertaya/server_check_bd/internal/logger"
 	"github.com/waltertaya/server_check_bd/internal/models"
 	"golang.org/x/crypto/bcrypt"
@@ -106,6 +112,94 @@ func (h *AuthHandlers) LoginHandler(c *gin.Context) {
 		return
 	}
 
+	// Create a session, only a hash of the token is stored
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		logger.Error("Failed to generate session token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(config.SessionTTL)
+	_, err = h.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, hashToken(hex.EncodeToString(token)), user.ID, now, expiresAt)
+	if err != nil {
+		logger.Error("Failed to create session for user with email %s: %v", req.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
 	logger.Info("User with email %s logged in successfully", req.Email)
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Login successful",
+		"token":     hex.EncodeToString(token),
+		"expiresAt": expiresAt,
+	})
+}
+
+// LogoutHandler ends the session of the bearer token
+func (h *AuthHandlers) LogoutHandler(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	_, err := h.db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
+	if err != nil {
+		logger.Error("Failed to delete session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RequireAuth rejects requests without a valid bearer token and makes the authenticated
+// user available to the following handlers
+func (h *AuthHandlers) RequireAuth(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var user models.User
+	err := h.db.Get(&user, `
+		SELECT users.* FROM sessions JOIN users ON users.id = sessions.user_id
+		WHERE sessions.token_hash = ? AND sessions.expires_at > ?
+	`, hashToken(token), time.Now().UTC())
+	if err != nil {
+		logger.Error("Rejected invalid or expired session token: %v", err)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	c.Set(userKey, user)
+	c.Next()
+}
+
+// userKey is the context key of the authenticated user
+const userKey = "user"
+
+// currentUser returns the user authenticated by RequireAuth
+func currentUser(c *gin.Context) models.User {
+	return c.MustGet(userKey).(models.User)
+}
+
+// bearerToken returns the token of a bearer Authorization header, or an empty string
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// hashToken hashes a session token for storage
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// CertificateHandlers handles certificate store HTTP requests
+type CertificateHandlers struct {
+	service *services.CertificateService
+}
+
+// NewCertificateHandlers creates a new certificate handlers instance
+func NewCertificateHandlers(service *services.CertificateService) *CertificateHandlers {
+	return &CertificateHandlers{
+		service: service,
+	}
+}
+
+// GetCertificates handles GET /api/certificates
+func (h *CertificateHandlers) GetCertificates(c *gin.Context) {
+	certs, err := h.service.GetCertificates()
+	if err != nil {
+		logger.Error("Failed to get certificates: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get certificates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, certs)
+}
+
+// GetCertificate handles GET /api/certificates/:id
+func (h *CertificateHandlers) GetCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid certificate ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	cert, err := h.service.GetCertificateByID(id)
+	if err != nil {
+		logger.Error("Failed to get certificate: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get certificate"})
+		return
+	}
+
+	if cert == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cert)
+}
+
+// CreateCertificate handles POST /api/certificates
+func (h *CertificateHandlers) CreateCertificate(c *gin.Context) {
+	var req models.UploadCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	cert, err := h.service.CreateCertificate(req)
+	if err != nil {
+		logger.Error("Failed to create certificate: %v", err)
+		if errors.Is(err, services.ErrInvalidCertificate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create certificate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cert)
+}
+
+// DeleteCertificate handles DELETE /api/certificates/:id
+func (h *CertificateHandlers) DeleteCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid certificate ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	err = h.service.DeleteCertificate(id)
+	if err != nil {
+		logger.Error("Failed to delete certificate: %v", err)
+		if errors.Is(err, services.ErrCertificateInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Certificate is referenced by a monitor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete certificate"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// CrawlHandlers handles crawler report HTTP requests
+type CrawlHandlers struct {
+	service *services.CrawlerService
+}
+
+// NewCrawlHandlers creates a new crawl handlers instance
+func NewCrawlHandlers(service *services.CrawlerService) *CrawlHandlers {
+	return &CrawlHandlers{
+		service: service,
+	}
+}
+
+// GetCrawlReports handles GET /api/servers/:id/crawls
+func (h *CrawlHandlers) GetCrawlReports(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	limit := 10 // Default limit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	reports, err := h.service.GetReports(id, limit)
+	if err != nil {
+		logger.Error("Failed to get crawl reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get crawl reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetCrawlReport handles GET /api/servers/:id/crawls/:reportId
+func (h *CrawlHandlers) GetCrawlReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	reportID, err := strconv.Atoi(c.Param("reportId"))
+	if err != nil {
+		logger.Error("Invalid crawl report ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid crawl report ID"})
+		return
+	}
+
+	report, err := h.service.GetReport(id, reportID)
+	if err != nil {
+		logger.Error("Failed to get crawl report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get crawl report"})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// GroupHandlers handles server group HTTP requests
+type GroupHandlers struct {
+	service *services.GroupService
+}
+
+// NewGroupHandlers creates a new group handlers instance
+func NewGroupHandlers(service *services.GroupService) *GroupHandlers {
+	return &GroupHandlers{
+		service: service,
+	}
+}
+
+// GetGroups handles GET /api/groups
+func (h *GroupHandlers) GetGroups(c *gin.Context) {
+	groups, err := h.service.GetGroups()
+	if err != nil {
+		logger.Error("Failed to get groups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetGroup handles GET /api/groups/:id
+func (h *GroupHandlers) GetGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid group ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	group, err := h.service.GetGroupByID(id)
+	if err != nil {
+		logger.Error("Failed to get group: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get group"})
+		return
+	}
+
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// CreateGroup handles POST /api/groups
+func (h *GroupHandlers) CreateGroup(c *gin.Context) {
+	var req models.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	group, err := h.service.CreateGroup(req)
+	if err != nil {
+		logger.Error("Failed to create group: %v", err)
+		if errors.Is(err, services.ErrUnknownGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup handles PUT /api/groups/:id
+func (h *GroupHandlers) UpdateGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid group ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	group, err := h.service.UpdateGroup(id, req)
+	if err != nil {
+		logger.Error("Failed to update group: %v", err)
+		if errors.Is(err, services.ErrUnknownGroup) || errors.Is(err, services.ErrGroupCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup handles DELETE /api/groups/:id
+func (h *GroupHandlers) DeleteGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid group ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	err = h.service.DeleteGroup(id)
+	if err != nil {
+		logger.Error("Failed to delete group: %v", err)
+		if errors.Is(err, services.ErrGroupNotEmpty) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Group still contains servers or subgroups"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
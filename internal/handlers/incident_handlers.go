@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// IncidentHandlers handles incident HTTP requests
+type IncidentHandlers struct {
+	service *services.IncidentService
+}
+
+// NewIncidentHandlers creates a new incident handlers instance
+func NewIncidentHandlers(service *services.IncidentService) *IncidentHandlers {
+	return &IncidentHandlers{
+		service: service,
+	}
+}
+
+// GetIncidents handles GET /api/incidents
+func (h *IncidentHandlers) GetIncidents(c *gin.Context) {
+	filter, err := incidentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	incidents, err := h.service.GetIncidents(filter)
+	if err != nil {
+		logger.Error("Failed to get incidents: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get incidents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incidents)
+}
+
+// GetIncident handles GET /api/incidents/:id
+func (h *IncidentHandlers) GetIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	incident, err := h.service.GetIncidentByID(id)
+	if err != nil {
+		logger.Error("Failed to get incident: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get incident"})
+		return
+	}
+
+	if incident == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+// CreateIncident handles POST /api/incidents
+func (h *IncidentHandlers) CreateIncident(c *gin.Context) {
+	var req models.CreateIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	incident, err := h.service.CreateIncident(req)
+	if err != nil {
+		logger.Error("Failed to create incident: %v", err)
+		if errors.Is(err, services.ErrUnknownServer) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create incident"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, incident)
+}
+
+// UpdateIncident handles PUT /api/incidents/:id
+func (h *IncidentHandlers) UpdateIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	var req models.UpdateIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	incident, err := h.service.UpdateIncident(id, req)
+	if err != nil {
+		logger.Error("Failed to update incident: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incident"})
+		return
+	}
+
+	if incident == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+// DeleteIncident handles DELETE /api/incidents/:id
+func (h *IncidentHandlers) DeleteIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	if err := h.service.DeleteIncident(id); err != nil {
+		logger.Error("Failed to delete incident: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete incident"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddIncidentNote handles POST /api/incidents/:id/notes
+func (h *IncidentHandlers) AddIncidentNote(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	var req models.AddIncidentNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	event, err := h.service.AddNote(id, req)
+	if err != nil {
+		logger.Error("Failed to add incident note: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add incident note"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// AcknowledgeIncident handles POST /api/incidents/:id/acknowledge
+func (h *IncidentHandlers) AcknowledgeIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	incident, err := h.service.Acknowledge(id, currentUser(c))
+	if err != nil {
+		logger.Error("Failed to acknowledge incident: %v", err)
+		if errors.Is(err, services.ErrIncidentResolved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge incident"})
+		return
+	}
+
+	if incident == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+// UnacknowledgeIncident handles POST /api/incidents/:id/unacknowledge
+func (h *IncidentHandlers) UnacknowledgeIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	incident, err := h.service.Unacknowledge(id, currentUser(c))
+	if err != nil {
+		logger.Error("Failed to unacknowledge incident: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unacknowledge incident"})
+		return
+	}
+
+	if incident == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+// AssignIncident handles POST /api/incidents/:id/assign
+func (h *IncidentHandlers) AssignIncident(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid incident ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+
+	var req models.AssignIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	incident, err := h.service.Assign(id, req, currentUser(c))
+	if err != nil {
+		logger.Error("Failed to assign incident: %v", err)
+		if errors.Is(err, services.ErrUnknownUser) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign incident"})
+		return
+	}
+
+	if incident == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+// incidentFilter parses the incident filter query parameters
+func incidentFilter(c *gin.Context) (models.IncidentFilter, error) {
+	filter := models.IncidentFilter{
+		Statuses: queryList(c, "status"),
+		Limit:    100, // Default limit
+	}
+
+	for _, status := range filter.Statuses {
+		switch status {
+		case "open", models.IncidentInvestigating, models.IncidentIdentified, models.IncidentMonitoring, models.IncidentResolved:
+		default:
+			return filter, fmt.Errorf("invalid status %q", status)
+		}
+	}
+
+	if serverStr := c.Query("server"); serverStr != "" {
+		serverID, err := strconv.Atoi(serverStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid server ID %q", serverStr)
+		}
+		filter.ServerID = &serverID
+	}
+
+	if groupStr := c.Query("group"); groupStr != "" {
+		groupID, err := strconv.Atoi(groupStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid group ID %q", groupStr)
+		}
+		filter.GroupID = &groupID
+	}
+
+	if acknowledgedStr := c.Query("acknowledged"); acknowledgedStr != "" {
+		acknowledged, err := strconv.ParseBool(acknowledgedStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid acknowledged flag %q", acknowledgedStr)
+		}
+		filter.Acknowledged = &acknowledged
+	}
+	filter.Assignee = c.Query("assignee")
+
+	var err error
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return filter, err
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			filter.Limit = parsedLimit
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// MaintenanceHandlers handles maintenance window HTTP requests
+type MaintenanceHandlers struct {
+	service *services.MaintenanceService
+}
+
+// NewMaintenanceHandlers creates a new maintenance handlers instance
+func NewMaintenanceHandlers(service *services.MaintenanceService) *MaintenanceHandlers {
+	return &MaintenanceHandlers{
+		service: service,
+	}
+}
+
+// GetWindows handles GET /api/maintenance
+func (h *MaintenanceHandlers) GetWindows(c *gin.Context) {
+	windows, err := h.service.GetWindows()
+	if err != nil {
+		logger.Error("Failed to get maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get maintenance windows"})
+		return
+	}
+
+	if c.Query("active") == "true" {
+		active := []models.MaintenanceWindow{}
+		for _, window := range windows {
+			if window.Active {
+				active = append(active, window)
+			}
+		}
+		windows = active
+	}
+
+	c.JSON(http.StatusOK, windows)
+}
+
+// GetWindow handles GET /api/maintenance/:id
+func (h *MaintenanceHandlers) GetWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid maintenance window ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window ID"})
+		return
+	}
+
+	window, err := h.service.GetWindowByID(id)
+	if err != nil {
+		logger.Error("Failed to get maintenance window: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get maintenance window"})
+		return
+	}
+
+	if window == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, window)
+}
+
+// CreateWindow handles POST /api/maintenance
+func (h *MaintenanceHandlers) CreateWindow(c *gin.Context) {
+	var req models.CreateMaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	window, err := h.service.CreateWindow(req)
+	if err != nil {
+		logger.Error("Failed to create maintenance window: %v", err)
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrNoMaintenanceTargets) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create maintenance window"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, window)
+}
+
+// UpdateWindow handles PUT /api/maintenance/:id
+func (h *MaintenanceHandlers) UpdateWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid maintenance window ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window ID"})
+		return
+	}
+
+	var req models.UpdateMaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	window, err := h.service.UpdateWindow(id, req)
+	if err != nil {
+		logger.Error("Failed to update maintenance window: %v", err)
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrNoMaintenanceTargets) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update maintenance window"})
+		return
+	}
+
+	if window == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, window)
+}
+
+// DeleteWindow handles DELETE /api/maintenance/:id
+func (h *MaintenanceHandlers) DeleteWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid maintenance window ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window ID"})
+		return
+	}
+
+	if err := h.service.DeleteWindow(id); err != nil {
+		logger.Error("Failed to delete maintenance window: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete maintenance window"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// NotificationHandlers handles notification channel HTTP requests
+type NotificationHandlers struct {
+	service *services.NotificationService
+}
+
+// NewNotificationHandlers creates a new notification handlers instance
+func NewNotificationHandlers(service *services.NotificationService) *NotificationHandlers {
+	return &NotificationHandlers{
+		service: service,
+	}
+}
+
+// GetChannels handles GET /api/notification-channels
+func (h *NotificationHandlers) GetChannels(c *gin.Context) {
+	channels, err := h.service.GetChannels()
+	if err != nil {
+		logger.Error("Failed to get notification channels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notification channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+// GetChannel handles GET /api/notification-channels/:id
+func (h *NotificationHandlers) GetChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid channel ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	channel, err := h.service.GetChannelByID(id)
+	if err != nil {
+		logger.Error("Failed to get notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notification channel"})
+		return
+	}
+
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// CreateChannel handles POST /api/notification-channels
+func (h *NotificationHandlers) CreateChannel(c *gin.Context) {
+	var req models.CreateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	channel, err := h.service.CreateChannel(req)
+	if err != nil {
+		logger.Error("Failed to create notification channel: %v", err)
+		if errors.Is(err, services.ErrInvalidChannel) || errors.Is(err, services.ErrUnknownServer) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification channel"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, channel)
+}
+
+// UpdateChannel handles PUT /api/notification-channels/:id
+func (h *NotificationHandlers) UpdateChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid channel ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	var req models.UpdateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	channel, err := h.service.UpdateChannel(id, req)
+	if err != nil {
+		logger.Error("Failed to update notification channel: %v", err)
+		if errors.Is(err, services.ErrInvalidChannel) || errors.Is(err, services.ErrUnknownServer) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification channel"})
+		return
+	}
+
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// DeleteChannel handles DELETE /api/notification-channels/:id
+func (h *NotificationHandlers) DeleteChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid channel ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	if err := h.service.DeleteChannel(id); err != nil {
+		logger.Error("Failed to delete notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification channel"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// TestChannel handles POST /api/notification-channels/:id/test. A failed test responds with
+// 502 Bad Gateway along with the delivery holding the error.
+func (h *NotificationHandlers) TestChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid channel ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	delivery, err := h.service.SendTest(id)
+	if err != nil {
+		logger.Error("Failed to send test notification: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test notification"})
+		return
+	}
+
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if delivery.Status != models.DeliveryDelivered {
+		c.JSON(http.StatusBadGateway, gin.H{"error": delivery.LastError, "delivery": delivery})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetChannelDeliveries handles GET /api/notification-channels/:id/deliveries
+func (h *NotificationHandlers) GetChannelDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid channel ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	filter, err := deliveryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.ChannelID = &id
+
+	deliveries, err := h.service.GetDeliveries(filter)
+	if err != nil {
+		logger.Error("Failed to get notification deliveries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notification deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDeliveries handles GET /api/notification-deliveries
+func (h *NotificationHandlers) GetDeliveries(c *gin.Context) {
+	filter, err := deliveryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if channelStr := c.Query("channel"); channelStr != "" {
+		channelID, err := strconv.Atoi(channelStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid channel ID %q", channelStr)})
+			return
+		}
+		filter.ChannelID = &channelID
+	}
+
+	deliveries, err := h.service.GetDeliveries(filter)
+	if err != nil {
+		logger.Error("Failed to get notification deliveries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notification deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// deliveryFilter parses the delivery log query parameters
+func deliveryFilter(c *gin.Context) (models.NotificationDeliveryFilter, error) {
+	filter := models.NotificationDeliveryFilter{
+		Status: c.Query("status"),
+		Limit:  100, // Default limit
+	}
+
+	switch filter.Status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		return filter, fmt.Errorf("invalid status %q", filter.Status)
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			filter.Limit = parsedLimit
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// ReportHandlers handles report and report schedule HTTP requests
+type ReportHandlers struct {
+	service *services.ReportService
+}
+
+// NewReportHandlers creates a new report handlers instance
+func NewReportHandlers(service *services.ReportService) *ReportHandlers {
+	return &ReportHandlers{
+		service: service,
+	}
+}
+
+// GetReports handles GET /api/reports
+func (h *ReportHandlers) GetReports(c *gin.Context) {
+	reports, err := h.service.GetReports()
+	if err != nil {
+		logger.Error("Failed to get reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetReport handles GET /api/reports/:id, returning the rendered HTML or with ?format=csv
+// the CSV export as an attachment
+func (h *ReportHandlers) GetReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected html or csv"})
+		return
+	}
+
+	report, err := h.service.GetReportByID(id)
+	if err != nil {
+		logger.Error("Failed to get report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report"})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", services.ReportFilename(*report)))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(report.CSV))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+}
+
+// CreateReport handles POST /api/reports
+func (h *ReportHandlers) CreateReport(c *gin.Context) {
+	var req models.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	report, err := h.service.CreateReport(req)
+	if err != nil {
+		logger.Error("Failed to create report: %v", err)
+		if errors.Is(err, services.ErrInvalidReport) || errors.Is(err, services.ErrUnknownGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// DeleteReport handles DELETE /api/reports/:id
+func (h *ReportHandlers) DeleteReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	if err := h.service.DeleteReport(id); err != nil {
+		logger.Error("Failed to delete report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSchedules handles GET /api/report-schedules
+func (h *ReportHandlers) GetSchedules(c *gin.Context) {
+	schedules, err := h.service.GetSchedules()
+	if err != nil {
+		logger.Error("Failed to get report schedules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetSchedule handles GET /api/report-schedules/:id
+func (h *ReportHandlers) GetSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report schedule ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report schedule ID"})
+		return
+	}
+
+	schedule, err := h.service.GetScheduleByID(id)
+	if err != nil {
+		logger.Error("Failed to get report schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report schedule"})
+		return
+	}
+
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// CreateSchedule handles POST /api/report-schedules
+func (h *ReportHandlers) CreateSchedule(c *gin.Context) {
+	var req models.CreateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	schedule, err := h.service.CreateSchedule(req)
+	if err != nil {
+		logger.Error("Failed to create report schedule: %v", err)
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrInvalidReport) || errors.Is(err, services.ErrUnknownGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule handles PUT /api/report-schedules/:id
+func (h *ReportHandlers) UpdateSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report schedule ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report schedule ID"})
+		return
+	}
+
+	var req models.UpdateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	schedule, err := h.service.UpdateSchedule(id, req)
+	if err != nil {
+		logger.Error("Failed to update report schedule: %v", err)
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrInvalidReport) || errors.Is(err, services.ErrUnknownGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report schedule"})
+		return
+	}
+
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule handles DELETE /api/report-schedules/:id
+func (h *ReportHandlers) DeleteSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report schedule ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report schedule ID"})
+		return
+	}
+
+	if err := h.service.DeleteSchedule(id); err != nil {
+		logger.Error("Failed to delete report schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report schedule"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunSchedule handles POST /api/report-schedules/:id/run, generating and emailing the report
+// immediately. Delivery failures are reported in the deliveryError of the report.
+func (h *ReportHandlers) RunSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid report schedule ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report schedule ID"})
+		return
+	}
+
+	report, err := h.service.RunSchedule(id)
+	if err != nil {
+		logger.Error("Failed to run report schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run report schedule"})
+		return
+	}
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// SecurityHandlers handles security audit HTTP requests
+type SecurityHandlers struct {
+	service *services.SecurityService
+}
+
+// NewSecurityHandlers creates a new security handlers instance
+func NewSecurityHandlers(service *services.SecurityService) *SecurityHandlers {
+	return &SecurityHandlers{
+		service: service,
+	}
+}
+
+// GetServerSecurity handles GET /api/servers/:id/security
+func (h *SecurityHandlers) GetServerSecurity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	limit := 20 // Default limit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	overview, err := h.service.GetOverview(id, limit)
+	if err != nil {
+		logger.Error("Failed to get security overview: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get security report"})
+		return
+	}
+
+	if overview == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
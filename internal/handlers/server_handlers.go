@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/waltertaya/server_check_bd/internal/logger"
@@ -10,17 +14,26 @@ import (
 	"github.com/waltertaya/server_check_bd/internal/services"
 )
 
+// maxPageSize limits how many servers a single page of the server list can return
+const maxPageSize = 500
+
 // ServerHandlers handles server-related HTTP requests
 type ServerHandlers struct {
-	service *services.ServerService
-	checker *services.HealthChecker
+	service     *services.ServerService
+	checker     *services.HealthChecker
+	audit       *services.AuditService
+	maintenance *services.MaintenanceService
+	history     *services.HistoryService
 }
 
 // NewServerHandlers creates a new server handlers instance
-func NewServerHandlers(service *services.ServerService, checker *services.HealthChecker) *ServerHandlers {
+func NewServerHandlers(service *services.ServerService, checker *services.HealthChecker, audit *services.AuditService, maintenance *services.MaintenanceService, history *services.HistoryService) *ServerHandlers {
 	return &ServerHandlers{
-		service: service,
-		checker: checker,
+		service:     service,
+		checker:     checker,
+		audit:       audit,
+		maintenance: maintenance,
+		history:     history,
 	}
 }
 
@@ -45,7 +58,20 @@ func (h *ServerHandlers) GetServer(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, server)
+	inMaintenance, err := h.maintenance.InMaintenance(server.ID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to check maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server"})
+		return
+	}
+	server.InMaintenance = inMaintenance
+
+	servers := []models.Server{*server}
+	if err := h.include(c, servers); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, servers[0])
 }
 
 // CreateServer handles POST /api/servers
@@ -60,13 +86,45 @@ func (h *ServerHandlers) CreateServer(c *gin.Context) {
 	server, err := h.service.CreateServer(req)
 	if err != nil {
 		logger.Error("Failed to create server: %v", err)
+		if errors.Is(err, services.ErrDependencyCycle) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrUnknownGroup) ||
+			errors.Is(err, services.ErrUnknownCertificate) || errors.Is(err, services.ErrInvalidNetwork) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create server"})
 		return
 	}
 
+	if server.InsecureSkipVerify {
+		h.auditSkipVerify(c, server.ID, true)
+	}
+
 	c.JSON(http.StatusCreated, server)
 }
 
+// TestServer handles POST /api/servers/test
+func (h *ServerHandlers) TestServer(c *gin.Context) {
+	var req models.CreateServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	server := services.NewServerFromRequest(req)
+	if err := h.service.ValidateServer(server); err != nil {
+		logger.Error("Invalid server to test: %v", err)
+		if errors.Is(err, services.ErrDependencyCycle) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrUnknownGroup) ||
+			errors.Is(err, services.ErrUnknownCertificate) || errors.Is(err, services.ErrInvalidNetwork) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to test server"})
+		return
+	}
+	c.JSON(http.StatusOK, h.checker.TestServer(*server))
+}
+
 // UpdateServer handles PUT /api/servers/:id
 func (h *ServerHandlers) UpdateServer(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -83,9 +141,23 @@ func (h *ServerHandlers) UpdateServer(c *gin.Context) {
 		return
 	}
 
+	// Remember the verification setting so changes to it can be audited
+	skipVerify := false
+	if req.InsecureSkipVerify != nil {
+		previous, err := h.service.GetServerByID(id)
+		if err == nil && previous != nil {
+			skipVerify = previous.InsecureSkipVerify
+		}
+	}
+
 	server, err := h.service.UpdateServer(id, req)
 	if err != nil {
 		logger.Error("Failed to update server: %v", err)
+		if errors.Is(err, services.ErrDependencyCycle) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrUnknownGroup) ||
+			errors.Is(err, services.ErrUnknownCertificate) || errors.Is(err, services.ErrInvalidNetwork) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update server"})
 		return
 	}
@@ -95,6 +167,10 @@ func (h *ServerHandlers) UpdateServer(c *gin.Context) {
 		return
 	}
 
+	if req.InsecureSkipVerify != nil && server.InsecureSkipVerify != skipVerify {
+		h.auditSkipVerify(c, server.ID, server.InsecureSkipVerify)
+	}
+
 	c.JSON(http.StatusOK, server)
 }
 
@@ -114,21 +190,363 @@ func (h *ServerHandlers) DeleteServer(c *gin.Context) {
 		return
 	}
 
+	h.checker.RemoveServer(id)
+
 	c.Status(http.StatusNoContent)
 }
 
-// GetServers handles GET /api/servers
+// PauseServer handles POST /api/servers/:id/pause
+func (h *ServerHandlers) PauseServer(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+// ResumeServer handles POST /api/servers/:id/resume
+func (h *ServerHandlers) ResumeServer(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+// setEnabled pauses or resumes monitoring of the server in the request
+func (h *ServerHandlers) setEnabled(c *gin.Context, enabled bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	server, err := h.service.SetServerEnabled(id, enabled)
+	if err != nil {
+		logger.Error("Failed to update server: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update server"})
+		return
+	}
+
+	if server == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, server)
+}
+
+// CheckServer handles POST /api/servers/:id/check
+func (h *ServerHandlers) CheckServer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	server, err := h.service.GetServerByID(id)
+	if err != nil {
+		logger.Error("Failed to get server: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server"})
+		return
+	}
+
+	if server == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	statuses, err := h.checker.CheckNow(*server)
+	if errors.Is(err, services.ErrCheckInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A check of the server is already in progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetServers handles GET /api/servers. The body stays a plain list, the total number of
+// matching servers and the cursor of the next page are returned in the X-Total-Count and
+// X-Next-Cursor headers.
 func (h *ServerHandlers) GetServers(c *gin.Context) {
-	servers, err := h.service.GetServers()
+	filter, err := serverFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := models.ServerQuery{
+		Filter: filter,
+		Sort:   c.DefaultQuery("sort", models.SortCreatedAt),
+		Cursor: c.Query("cursor"),
+	}
+	switch query.Sort {
+	case models.SortName, models.SortState, models.SortLastChecked, models.SortResponseTime, models.SortCreatedAt:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+		return
+	}
+
+	// Newest first by default, other fields sort ascending unless asked otherwise
+	switch c.Query("order") {
+	case "":
+		query.Descending = query.Sort == models.SortCreatedAt
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort order"})
+		return
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Limit must be between 1 and %d", maxPageSize)})
+			return
+		}
+		query.Limit = limit
+	}
+
+	page, err := h.service.ListServers(query)
 	if err != nil {
 		logger.Error("Failed to get servers: %v", err)
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get servers"})
 		return
 	}
+	servers := page.Servers
 
+	inMaintenance, err := h.maintenance.ActiveServerIDs(time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to check maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get servers"})
+		return
+	}
+	for i := range servers {
+		servers[i].InMaintenance = inMaintenance[servers[i].ID]
+	}
+
+	if err := h.include(c, servers); err != nil {
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
 	c.JSON(http.StatusOK, servers)
 }
 
+// GetHistory handles GET /api/history
+func (h *ServerHandlers) GetHistory(c *gin.Context) {
+	filter, err := serverFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 100 // Default limit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	history, err := h.service.GetHistory(filter, limit)
+	if err != nil {
+		logger.Error("Failed to get history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetTags handles GET /api/tags
+func (h *ServerHandlers) GetTags(c *gin.Context) {
+	tags, err := h.service.GetTagSummaries()
+	if err != nil {
+		logger.Error("Failed to get tags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// SetServerTag handles PUT /api/servers/:id/tags/:key
+func (h *ServerHandlers) SetServerTag(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	key := c.Param("key")
+	if strings.Contains(key, ":") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag keys cannot contain ':'"})
+		return
+	}
+
+	var req models.SetTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	server, err := h.service.GetServerByID(id)
+	if err != nil {
+		logger.Error("Failed to get server: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server"})
+		return
+	}
+	if server == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	if err := h.service.SetTag(id, key, req.Value); err != nil {
+		logger.Error("Failed to set tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set tag"})
+		return
+	}
+
+	server.Tags[key] = req.Value
+	c.JSON(http.StatusOK, server.Tags)
+}
+
+// DeleteServerTag handles DELETE /api/servers/:id/tags/:key
+func (h *ServerHandlers) DeleteServerTag(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	if err := h.service.DeleteTag(id, c.Param("key")); err != nil {
+		logger.Error("Failed to delete tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// serverFilter reads the filters of a server list request. Tags are given as repeated
+// "tag=key:value" parameters, a bare key matches any value. States and types can be
+// repeated or comma separated.
+func serverFilter(c *gin.Context) (models.ServerFilter, error) {
+	filter := models.ServerFilter{
+		Search: c.Query("q"),
+		States: queryList(c, "state"),
+		Types:  queryList(c, "type"),
+	}
+
+	for _, state := range filter.States {
+		switch state {
+		case models.StateUp, models.StateDown, models.StateDegraded, models.StateMaintenance, models.StateUnreachable:
+		default:
+			return filter, fmt.Errorf("invalid state %q", state)
+		}
+	}
+	for _, serverType := range filter.Types {
+		switch serverType {
+		case models.TypeHTTP, models.TypeCrawler:
+		default:
+			return filter, fmt.Errorf("invalid type %q", serverType)
+		}
+	}
+
+	if enabledStr := c.Query("enabled"); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid enabled flag %q", enabledStr)
+		}
+		filter.Enabled = &enabled
+	}
+
+	for _, tag := range c.QueryArray("tag") {
+		selector, err := services.ParseTagSelector(tag)
+		if err != nil {
+			return filter, err
+		}
+		filter.Tags = append(filter.Tags, selector)
+	}
+
+	if groupStr := c.Query("group"); groupStr != "" {
+		groupID, err := strconv.Atoi(groupStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid group ID %q", groupStr)
+		}
+		filter.GroupID = &groupID
+	}
+	return filter, nil
+}
+
+// include embeds the latest status and uptime summary of servers when requested with
+// include=status,uptime. On failure the error response has already been written.
+func (h *ServerHandlers) include(c *gin.Context, servers []models.Server) error {
+	for _, include := range queryList(c, "include") {
+		var err error
+		switch include {
+		case "status":
+			err = h.service.AttachStatus(servers)
+		case "uptime":
+			err = h.service.AttachUptime(servers, time.Now().UTC())
+		default:
+			err = fmt.Errorf("invalid include %q", include)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return err
+		}
+		if err != nil {
+			logger.Error("Failed to include %s: %v", include, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get servers"})
+			return err
+		}
+	}
+	return nil
+}
+
+// queryTime parses an optional RFC 3339 time query parameter
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s time %q, expected RFC 3339", name, value)
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// queryList returns the values of a repeatable query parameter, splitting comma separated values
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range c.QueryArray(name) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+// GetDependencyGraph handles GET /api/dependencies
+func (h *ServerHandlers) GetDependencyGraph(c *gin.Context) {
+	graph, err := h.service.GetDependencyGraph()
+	if err != nil {
+		logger.Error("Failed to get dependency graph: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dependency graph"})
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
 // GetServerHistory handles GET /api/servers/:id/history
 func (h *ServerHandlers) GetServerHistory(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -138,19 +556,104 @@ func (h *ServerHandlers) GetServerHistory(c *gin.Context) {
 		return
 	}
 
-	limit := 100 // Default limit
+	query := models.HistoryQuery{
+		Error:  c.Query("error"),
+		Family: c.Query("family"),
+		Cursor: c.Query("cursor"),
+		Limit:  100, // Default limit
+	}
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+			query.Limit = parsedLimit
+		}
+	}
+
+	switch query.Family {
+	case "", models.FamilyAuto, models.FamilyIPv4, models.FamilyIPv6:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address family"})
+		return
+	}
+
+	if query.From, err = queryTime(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.To, err = queryTime(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if upStr := c.Query("up"); upStr != "" {
+		up, err := strconv.ParseBool(upStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid up flag"})
+			return
+		}
+		query.Up = &up
+	}
+
+	if bucketStr := c.Query("bucket"); bucketStr != "" {
+		bucket, err := services.ParseBucket(bucketStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Aggregations cover the last day unless a range is given
+		if query.To == nil {
+			now := time.Now().UTC()
+			query.To = &now
+		}
+		if query.From == nil {
+			from := query.To.Add(-24 * time.Hour)
+			query.From = &from
 		}
+
+		buckets, err := h.history.AggregateServerHistory(id, query, bucket)
+		if err != nil {
+			logger.Error("Failed to aggregate server history: %v", err)
+			if errors.Is(err, services.ErrTooManyBuckets) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, buckets)
+		return
 	}
 
-	history, err := h.service.GetServerHistory(id, limit)
+	page, err := h.history.GetServerHistory(id, query)
 	if err != nil {
 		logger.Error("Failed to get server history: %v", err)
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server history"})
 		return
 	}
 
-	c.JSON(http.StatusOK, history)
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.History)
+}
+
+// auditSkipVerify records a change to the TLS verification setting of a server
+func (h *ServerHandlers) auditSkipVerify(c *gin.Context, serverID int, enabled bool) {
+	action := "tls.skip_verify.disabled"
+	if enabled {
+		action = "tls.skip_verify.enabled"
+	}
+
+	h.audit.Record(models.AuditEntry{
+		Action:     action,
+		EntityType: "server",
+		EntityID:   serverID,
+		Actor:      c.ClientIP(),
+		Details:    fmt.Sprintf("TLS certificate verification skip set to %t", enabled),
+	})
 }
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/services"
+)
+
+// SLOHandlers handles SLO HTTP requests
+type SLOHandlers struct {
+	service *services.SLOService
+	alerter *services.SLOAlerter
+}
+
+// NewSLOHandlers creates a new SLO handlers instance
+func NewSLOHandlers(service *services.SLOService, alerter *services.SLOAlerter) *SLOHandlers {
+	return &SLOHandlers{
+		service: service,
+		alerter: alerter,
+	}
+}
+
+// GetSLOs handles GET /api/slos
+func (h *SLOHandlers) GetSLOs(c *gin.Context) {
+	slos, err := h.service.GetSLOs()
+	if err != nil {
+		logger.Error("Failed to get SLOs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLOs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slos)
+}
+
+// GetSLO handles GET /api/slos/:id
+func (h *SLOHandlers) GetSLO(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid SLO ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SLO ID"})
+		return
+	}
+
+	slo, err := h.service.GetSLOByID(id)
+	if err != nil {
+		logger.Error("Failed to get SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLO"})
+		return
+	}
+
+	if slo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slo)
+}
+
+// CreateSLO handles POST /api/slos
+func (h *SLOHandlers) CreateSLO(c *gin.Context) {
+	var req models.CreateSLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	slo, err := h.service.CreateSLO(req)
+	if err != nil {
+		logger.Error("Failed to create SLO: %v", err)
+		if errors.Is(err, services.ErrInvalidSLO) || errors.Is(err, services.ErrUnknownServer) || errors.Is(err, services.ErrUnknownGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SLO"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, slo)
+}
+
+// UpdateSLO handles PUT /api/slos/:id
+func (h *SLOHandlers) UpdateSLO(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid SLO ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SLO ID"})
+		return
+	}
+
+	var req models.UpdateSLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	slo, err := h.service.UpdateSLO(id, req)
+	if err != nil {
+		logger.Error("Failed to update SLO: %v", err)
+		if errors.Is(err, services.ErrInvalidSLO) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update SLO"})
+		return
+	}
+
+	if slo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slo)
+}
+
+// DeleteSLO handles DELETE /api/slos/:id
+func (h *SLOHandlers) DeleteSLO(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid SLO ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SLO ID"})
+		return
+	}
+
+	if err := h.service.DeleteSLO(id); err != nil {
+		logger.Error("Failed to delete SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SLO"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetAlerts handles GET /api/alerts
+func (h *SLOHandlers) GetAlerts(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != models.AlertFiring && state != models.AlertResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state, expected firing or resolved"})
+		return
+	}
+
+	alerts, err := h.alerter.GetAlerts(nil, state)
+	if err != nil {
+		logger.Error("Failed to get SLO alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLO alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetSLOAlerts handles GET /api/slos/:id/alerts
+func (h *SLOHandlers) GetSLOAlerts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid SLO ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SLO ID"})
+		return
+	}
+
+	state := c.Query("state")
+	if state != "" && state != models.AlertFiring && state != models.AlertResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state, expected firing or resolved"})
+		return
+	}
+
+	alerts, err := h.alerter.GetAlerts(&id, state)
+	if err != nil {
+		logger.Error("Failed to get SLO alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLO alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetServerSLO handles GET /api/servers/:id/slo
+func (h *SLOHandlers) GetServerSLO(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid server ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	reports, err := h.service.GetServerReports(id, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to get SLO reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLO reports"})
+		return
+	}
+
+	if reports == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetGroupSLO handles GET /api/groups/:id/slo
+func (h *SLOHandlers) GetGroupSLO(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Error("Invalid group ID: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	reports, err := h.service.GetGroupReports(id, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to get SLO reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get SLO reports"})
+		return
+	}
+
+	if reports == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ReliabilityStats summarises the outages and incidents of a server or group over a time
+// range. Durations are in seconds and nil when there is nothing to average.
+type ReliabilityStats struct {
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Outages       int       `json:"outages"` // From check history, checks during maintenance are ignored
+	Downtime      float64   `json:"downtime"`
+	LongestOutage *float64  `json:"longestOutage"`
+	StateChanges  int       `json:"stateChanges"` // Transitions between up and down
+	FlapsPerDay   float64   `json:"flapsPerDay"`
+	Incidents     int       `json:"incidents"`
+	MTTD          *float64  `json:"mttd"` // From the last successful check to the incident opening, automatic incidents only
+	MTTA          *float64  `json:"mtta"` // From the incident opening to its acknowledgement
+	MTTR          *float64  `json:"mttr"` // From the incident opening to its resolution
+}
+
+// ReliabilityReport holds the reliability statistics over a time range, optionally broken
+// down by calendar week or month for trend charts
+type ReliabilityReport struct {
+	Summary   ReliabilityStats   `json:"summary"`
+	Breakdown []ReliabilityStats `json:"breakdown,omitempty"`
+}
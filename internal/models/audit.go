@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// AuditEntry represents a recorded security-sensitive action
+type AuditEntry struct {
+	ID         int       `db:"id" json:"id"`
+	Action     string    `db:"action" json:"action"`
+	EntityType string    `db:"entity_type" json:"entityType"`
+	EntityID   int       `db:"entity_id" json:"entityId"`
+	Actor      string    `db:"actor" json:"actor"`
+	Details    string    `db:"details" json:"details"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
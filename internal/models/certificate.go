@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Certificate types
+const (
+	CertificateClient = "client"
+	CertificateCA     = "ca"
+)
+
+// Certificate represents an uploaded client certificate or CA bundle
+type Certificate struct {
+	ID          int       `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Type        string    `db:"type" json:"type"`
+	Subject     string    `db:"subject" json:"subject"`
+	Issuer      string    `db:"issuer" json:"issuer"`
+	Fingerprint string    `db:"fingerprint" json:"fingerprint"`
+	NotAfter    time.Time `db:"not_after" json:"notAfter"`
+	CertPEM     string    `db:"certificate" json:"-"` // Encrypted at rest
+	KeyPEM      *string   `db:"private_key" json:"-"` // Encrypted at rest
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// UploadCertificateRequest represents the request to upload a certificate
+type UploadCertificateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Type        string `json:"type" binding:"required,oneof=client ca"`
+	Certificate string `json:"certificate" binding:"required"`
+	PrivateKey  string `json:"privateKey"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// CrawlReport represents the outcome of a single crawler monitor run
+type CrawlReport struct {
+	ID           int          `db:"id" json:"id"`
+	ServerID     int          `db:"server_id" json:"serverId"`
+	PagesCrawled int          `db:"pages_crawled" json:"pagesCrawled"`
+	LinksChecked int          `db:"links_checked" json:"linksChecked"`
+	BrokenCount  int          `db:"broken_count" json:"brokenCount"`
+	Duration     int          `db:"duration" json:"duration"`
+	Error        *string      `db:"error" json:"error"`
+	StartedAt    time.Time    `db:"started_at" json:"startedAt"`
+	BrokenLinks  []BrokenLink `db:"-" json:"brokenLinks"`
+}
+
+// BrokenLink represents a link that returned an error status or timed out during a crawl
+type BrokenLink struct {
+	ID         int     `db:"id" json:"id"`
+	ReportID   int     `db:"report_id" json:"reportId"`
+	URL        string  `db:"url" json:"url"`
+	Referrer   string  `db:"referrer" json:"referrer"`
+	StatusCode *int    `db:"status_code" json:"statusCode"`
+	Error      *string `db:"error" json:"error"`
+}
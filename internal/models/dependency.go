@@ -0,0 +1,21 @@
+package models
+
+// DependencyEdge links a server to a parent it depends on
+type DependencyEdge struct {
+	ServerID int `db:"server_id" json:"serverId"`
+	ParentID int `db:"parent_id" json:"parentId"`
+}
+
+// DependencyNode represents a server in the dependency graph with its latest state
+type DependencyNode struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	RootCauseID *int   `json:"rootCauseId,omitempty"`
+}
+
+// DependencyGraph represents the servers and the dependencies between them
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
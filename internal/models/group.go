@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Group represents a folder of monitors, groups can be nested under a parent group
+type Group struct {
+	ID          int       `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	ParentID    *int      `db:"parent_id" json:"parentId"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updatedAt"`
+	State       string    `db:"-" json:"state"`       // Worst latest state of the servers in the group and its subgroups
+	ServerCount int       `db:"-" json:"serverCount"` // Servers in the group and its subgroups
+}
+
+// CreateGroupRequest represents the request to create a group
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	ParentID    *int   `json:"parentId"`
+}
+
+// UpdateGroupRequest represents the request to update a group
+type UpdateGroupRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	ParentID    *int    `json:"parentId" binding:"omitempty,min=0"` // 0 moves the group to the top level
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// HistoryQuery restricts and paginates the check history of a server
+type HistoryQuery struct {
+	From   *time.Time
+	To     *time.Time
+	Up     *bool  // Successful or failed checks only
+	Error  string // Substring of the error message
+	Family string
+	Cursor string // Opaque cursor from a previous page
+	Limit  int
+}
+
+// HistoryPage represents a page of check history, newest first
+type HistoryPage struct {
+	History    []ServerHistory
+	NextCursor string // Empty on the last page
+}
+
+// HistoryBucket aggregates the checks of a server over a fixed time interval. Latencies are
+// in milliseconds and nil when no check in the bucket recorded a response time.
+type HistoryBucket struct {
+	Start        time.Time `json:"start"`
+	Checks       int       `json:"checks"`
+	Successes    int       `json:"successes"`
+	Maintenance  int       `json:"maintenance"`
+	SuccessRatio *float64  `json:"successRatio"` // Excludes checks during maintenance
+	Min          *int      `json:"min"`
+	Avg          *float64  `json:"avg"`
+	Max          *int      `json:"max"`
+	P50          *int      `json:"p50"`
+	P95          *int      `json:"p95"`
+	P99          *int      `json:"p99"`
+}
+
+// Rollup resolutions
+const (
+	ResolutionMinute = "1m"
+	ResolutionHour   = "1h"
+	ResolutionDay    = "1d"
+)
+
+// HistoryRollup aggregates the raw checks of a server over a fixed interval. Rollups are
+// written by the retention job before raw history is deleted.
+type HistoryRollup struct {
+	ServerID     int       `db:"server_id" json:"serverId"`
+	Resolution   string    `db:"resolution" json:"resolution"`
+	BucketStart  time.Time `db:"bucket_start" json:"bucketStart"`
+	Checks       int       `db:"checks" json:"checks"`
+	Failures     int       `db:"failures" json:"failures"` // Excludes checks during maintenance
+	Maintenance  int       `db:"maintenance" json:"maintenance"`
+	LatencyCount int       `db:"latency_count" json:"latencyCount"` // Checks that recorded a response time
+	LatencySum   int64     `db:"latency_sum" json:"latencySum"`
+	Min          *int      `db:"min" json:"min"`
+	Max          *int      `db:"max" json:"max"`
+	P50          *int      `db:"p50" json:"p50"`
+	P95          *int      `db:"p95" json:"p95"`
+	P99          *int      `db:"p99" json:"p99"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Incident statuses
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentResolved      = "resolved"
+)
+
+// Incident timeline event types
+const (
+	EventOpened         = "opened"
+	EventStatus         = "status"
+	EventNote           = "note"
+	EventResolved       = "resolved"
+	EventAcknowledged   = "acknowledged"
+	EventUnacknowledged = "unacknowledged"
+	EventAssigned       = "assigned"
+)
+
+// Incident represents an outage of a server, opened automatically when its checks start
+// failing or manually. Automatic incidents collect the failed checks until the server recovers.
+// Open incidents are notified again until someone acknowledges them.
+type Incident struct {
+	ID                  int        `db:"id" json:"id"`
+	ServerID            int        `db:"server_id" json:"serverId"`
+	Title               string     `db:"title" json:"title"`
+	Status              string     `db:"status" json:"status"`
+	Automatic           bool       `db:"automatic" json:"automatic"`
+	StartedAt           time.Time  `db:"started_at" json:"startedAt"`
+	ResolvedAt          *time.Time `db:"resolved_at" json:"resolvedAt"`
+	Failures            int        `db:"failures" json:"failures"` // Failed checks while open
+	LastFailureAt       *time.Time `db:"last_failure_at" json:"lastFailureAt"`
+	LastError           string     `db:"last_error" json:"lastError"`
+	AcknowledgedBy      *int       `db:"acknowledged_by" json:"acknowledgedBy"` // User ID
+	AcknowledgedByEmail *string    `db:"acknowledged_by_email" json:"acknowledgedByEmail"`
+	AcknowledgedAt      *time.Time `db:"acknowledged_at" json:"acknowledgedAt"`
+	AssigneeID          *int       `db:"assignee_id" json:"assigneeId"`
+	AssigneeEmail       *string    `db:"assignee_email" json:"assigneeEmail"`
+	LastNotifiedAt      *time.Time `db:"last_notified_at" json:"lastNotifiedAt"`
+	CreatedAt           time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt           time.Time  `db:"updated_at" json:"updatedAt"`
+
+	// Duration is the time in seconds until the incident was resolved, or until now if it is open
+	Duration float64         `db:"-" json:"duration"`
+	Timeline []IncidentEvent `db:"-" json:"timeline,omitempty"`
+}
+
+// IncidentEvent is an entry in the timeline of an incident, a status change or a note
+type IncidentEvent struct {
+	ID         int       `db:"id" json:"id"`
+	IncidentID int       `db:"incident_id" json:"incidentId"`
+	Type       string    `db:"type" json:"type"`
+	Status     string    `db:"status" json:"status"` // Status after the event
+	Message    string    `db:"message" json:"message"`
+	Actor      string    `db:"actor" json:"actor"` // Email of the user, empty for automatic and unauthenticated changes
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
+
+// CreateIncidentRequest represents the request to open an incident manually
+type CreateIncidentRequest struct {
+	ServerID int    `json:"serverId" binding:"required"`
+	Title    string `json:"title" binding:"required"`
+	Status   string `json:"status" binding:"omitempty,oneof=investigating identified monitoring"`
+	Message  string `json:"message"`
+}
+
+// UpdateIncidentRequest represents the request to update an incident, the message is added
+// to the timeline with the status change
+type UpdateIncidentRequest struct {
+	Title   *string `json:"title"`
+	Status  *string `json:"status" binding:"omitempty,oneof=investigating identified monitoring resolved"`
+	Message string  `json:"message"`
+}
+
+// AddIncidentNoteRequest represents the request to add a note to the timeline of an incident
+type AddIncidentNoteRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// AssignIncidentRequest represents the request to assign an incident to a user, an empty
+// email unassigns it
+type AssignIncidentRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// IncidentFilter restricts which incidents are listed
+type IncidentFilter struct {
+	ServerID     *int
+	GroupID      *int     // Includes servers in subgroups
+	Statuses     []string // Any of, "open" matches every status but resolved
+	Acknowledged *bool
+	Assignee     string // Email of the assigned user
+	From         *time.Time
+	To           *time.Time // Incidents overlapping the range between From and To
+	Limit        int
+}
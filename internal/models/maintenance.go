@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// MaintenanceWindow represents a planned period during which checks of its servers are
+// recorded with the MAINTENANCE state instead of raising alerts
+type MaintenanceWindow struct {
+	ID          int        `db:"id" json:"id"`
+	Name        string     `db:"name" json:"name"`
+	Description string     `db:"description" json:"description"`
+	StartsAt    time.Time  `db:"starts_at" json:"startsAt"`
+	Duration    int        `db:"duration" json:"duration"` // Minutes
+	Recurrence  string     `db:"recurrence" json:"recurrence"`
+	Timezone    string     `db:"timezone" json:"timezone"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updatedAt"`
+	ServerIDs   []int      `db:"-" json:"serverIds"`
+	Tags        []string   `db:"-" json:"tags"` // Tag selectors, "key:value" or "key" for any value
+	Active      bool       `db:"-" json:"active"`
+	NextStart   *time.Time `db:"-" json:"nextStart"`
+}
+
+// CreateMaintenanceWindowRequest represents the request to create a maintenance window
+type CreateMaintenanceWindowRequest struct {
+	Name        string    `json:"name" binding:"required"`
+	Description string    `json:"description"`
+	StartsAt    time.Time `json:"startsAt" binding:"required"`
+	Duration    int       `json:"duration" binding:"required,min=1"`
+	Recurrence  string    `json:"recurrence"`
+	Timezone    string    `json:"timezone"`
+	ServerIDs   []int     `json:"serverIds"`
+	Tags        []string  `json:"tags"`
+}
+
+// UpdateMaintenanceWindowRequest represents the request to update a maintenance window
+type UpdateMaintenanceWindowRequest struct {
+	Name        *string    `json:"name"`
+	Description *string    `json:"description"`
+	StartsAt    *time.Time `json:"startsAt"`
+	Duration    *int       `json:"duration" binding:"omitempty,min=1"`
+	Recurrence  *string    `json:"recurrence"`
+	Timezone    *string    `json:"timezone"`
+	ServerIDs   []int      `json:"serverIds"`
+	Tags        []string   `json:"tags"`
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification channel types
+const (
+	ChannelLog        = "log"
+	ChannelEmail      = "email"
+	ChannelSlack      = "slack"
+	ChannelTeams      = "teams"
+	ChannelDiscord    = "discord"
+	ChannelMattermost = "mattermost"
+	ChannelWebhook    = "webhook"
+)
+
+// Notification events
+const (
+	NotifyMonitorDown         = "monitor.down"
+	NotifyMonitorUp           = "monitor.up"
+	NotifyIncidentOpened      = "incident.opened"
+	NotifyIncidentReminder    = "incident.reminder"
+	NotifyIncidentResolved    = "incident.resolved"
+	NotifySLOAlertFired       = "slo.alert.fired"
+	NotifySLOAlertResolved    = "slo.alert.resolved"
+	NotifyCertificateExpiring = "certificate.expiring"
+	NotifyTest                = "test"
+)
+
+// NotificationEvents are the events channels can subscribe to
+var NotificationEvents = []string{
+	NotifyMonitorDown,
+	NotifyMonitorUp,
+	NotifyIncidentOpened,
+	NotifyIncidentReminder,
+	NotifyIncidentResolved,
+	NotifySLOAlertFired,
+	NotifySLOAlertResolved,
+	NotifyCertificateExpiring,
+}
+
+// DefaultNotificationEvents are the events of channels without a subscription. Monitor state
+// changes are left out as they duplicate incidents, automation can subscribe to them.
+var DefaultNotificationEvents = []string{
+	NotifyIncidentOpened,
+	NotifyIncidentReminder,
+	NotifyIncidentResolved,
+	NotifySLOAlertFired,
+	NotifySLOAlertResolved,
+	NotifyCertificateExpiring,
+}
+
+// Notification levels
+const (
+	LevelCritical = "critical"
+	LevelWarning  = "warning"
+	LevelInfo     = "info"
+)
+
+// Notification delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Notification represents an event sent to notification channels. Channel templates have
+// access to all of its fields.
+type Notification struct {
+	ID          string                   `json:"id"` // Unique per event, shared by the deliveries to every channel
+	Event       string                   `json:"event"`
+	Level       string                   `json:"level"`
+	Title       string                   `json:"title"`
+	Message     string                   `json:"message"`
+	Server      *NotificationServer      `json:"server,omitempty"`
+	Incident    *NotificationIncident    `json:"incident,omitempty"`
+	SLO         *NotificationSLO         `json:"slo,omitempty"`
+	Certificate *NotificationCertificate `json:"certificate,omitempty"`
+	At          time.Time                `json:"at"`
+}
+
+// NotificationServer describes the server a notification is about
+type NotificationServer struct {
+	ID           int           `db:"id" json:"id"`
+	Name         string        `db:"name" json:"name"`
+	URL          string        `db:"url" json:"url"`
+	State        string        `db:"last_state" json:"state"`                          // Latest state at the time of the notification
+	ResponseTime *int          `db:"last_response_time" json:"responseTime,omitempty"` // Milliseconds
+	StatusCode   *int          `db:"-" json:"statusCode,omitempty"`                    // Set for notifications about a check
+	Error        *string       `db:"-" json:"error,omitempty"`                         // Set for notifications about a check
+	Timings      *CheckTimings `db:"-" json:"timings,omitempty"`                       // Set for notifications about a check
+}
+
+// NotificationIncident describes the incident a notification is about
+type NotificationIncident struct {
+	ID         int        `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Failures   int        `json:"failures"`
+	LastError  string     `json:"lastError,omitempty"`
+	Duration   float64    `json:"duration"` // Seconds from the start of the incident to the notification
+}
+
+// NotificationSLO describes the SLO a burn rate alert notification is about
+type NotificationSLO struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Severity      string  `json:"severity"`
+	LongWindow    int     `json:"longWindow"`   // Minutes
+	ShortWindow   int     `json:"shortWindow"`  // Minutes
+	Threshold     float64 `json:"threshold"`    // Burn rate both windows must exceed to fire
+	LongBurnRate  float64 `json:"longBurnRate"` // Burn rates at the time of the notification
+	ShortBurnRate float64 `json:"shortBurnRate"`
+}
+
+// NotificationCertificate describes the TLS certificate a certificate expiry notification is about
+type NotificationCertificate struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"notAfter"`
+	DaysLeft int       `json:"daysLeft"`
+}
+
+// NotificationChannel represents a destination for notifications. A channel receives the
+// notifications of the servers it is bound to, either directly or by tag.
+type NotificationChannel struct {
+	ID              int             `db:"id" json:"id"`
+	Type            string          `db:"type" json:"type"`
+	Name            string          `db:"name" json:"name"`
+	EncryptedConfig string          `db:"config" json:"-"`
+	Config          json.RawMessage `db:"-" json:"config"` // Type specific settings, encrypted at rest and with secrets masked in responses
+	Enabled         bool            `db:"enabled" json:"enabled"`
+	CreatedAt       time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time       `db:"updated_at" json:"updatedAt"`
+	ServerIDs       []int           `db:"-" json:"serverIds"`
+	Tags            []string        `db:"-" json:"tags"`   // Tag selectors, "key:value" or "key" for any value
+	Events          []string        `db:"-" json:"events"` // Subscribed events, the default events when empty
+}
+
+// CreateNotificationChannelRequest represents the request to create a notification channel
+type CreateNotificationChannelRequest struct {
+	Type      string          `json:"type" binding:"required"`
+	Name      string          `json:"name" binding:"required"`
+	Config    json.RawMessage `json:"config"`
+	Enabled   *bool           `json:"enabled"` // Defaults to true
+	ServerIDs []int           `json:"serverIds"`
+	Tags      []string        `json:"tags"`
+	Events    []string        `json:"events"`
+}
+
+// UpdateNotificationChannelRequest represents the request to update a notification channel
+type UpdateNotificationChannelRequest struct {
+	Name      *string         `json:"name"`
+	Config    json.RawMessage `json:"config"` // Replaces the settings when present, masked secrets are kept
+	Enabled   *bool           `json:"enabled"`
+	ServerIDs []int           `json:"serverIds"` // Replaces the server bindings when present
+	Tags      []string        `json:"tags"`      // Replaces the tag bindings when present
+	Events    []string        `json:"events"`    // Replaces the subscribed events when present
+}
+
+// NotificationDelivery represents a notification queued for or sent to a channel
+type NotificationDelivery struct {
+	ID             int        `db:"id" json:"id"`
+	ChannelID      int        `db:"channel_id" json:"channelId"`
+	Event          string     `db:"event" json:"event"`
+	Title          string     `db:"title" json:"title"`
+	Payload        string     `db:"payload" json:"-"` // The notification as JSON
+	Status         string     `db:"status" json:"status"`
+	Attempts       int        `db:"attempts" json:"attempts"`
+	NextAttemptAt  *time.Time `db:"next_attempt_at" json:"nextAttemptAt"`
+	LastError      string     `db:"last_error" json:"lastError"`
+	ResponseStatus *int       `db:"response_status" json:"responseStatus"` // HTTP status of the last attempt, for channels delivering over HTTP
+	CreatedAt      time.Time  `db:"created_at" json:"createdAt"`
+	DeliveredAt    *time.Time `db:"delivered_at" json:"deliveredAt"`
+}
+
+// NotificationDeliveryFilter restricts which deliveries are listed
+type NotificationDeliveryFilter struct {
+	ChannelID *int
+	Status    string
+	Limit     int
+}
@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// Report periods
+const (
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+// ReportSchedule represents a recurring availability report emailed to its recipients. The
+// report covers the servers of a group or matching a tag selector, or all servers if neither
+// is set, over the last complete calendar week or month.
+type ReportSchedule struct {
+	ID         int        `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Period     string     `db:"period" json:"period"`
+	Schedule   string     `db:"schedule" json:"schedule"` // Cron expression in UTC
+	GroupID    *int       `db:"group_id" json:"groupId"`
+	Tag        string     `db:"tag" json:"tag"` // Tag selector, "key:value" or "key" for any value
+	LastRunAt  *time.Time `db:"last_run_at" json:"lastRunAt"`
+	NextRunAt  *time.Time `db:"next_run_at" json:"nextRunAt"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updatedAt"`
+	Recipients []string   `db:"-" json:"recipients"`
+}
+
+// CreateReportScheduleRequest represents the request to create a report schedule
+type CreateReportScheduleRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Period     string   `json:"period" binding:"required,oneof=weekly monthly"`
+	Schedule   string   `json:"schedule"` // Defaults to 08:00 UTC on Mondays or on the first of the month
+	GroupID    *int     `json:"groupId"`
+	Tag        string   `json:"tag"`
+	Recipients []string `json:"recipients" binding:"required,min=1,dive,email"`
+}
+
+// UpdateReportScheduleRequest represents the request to update a report schedule
+type UpdateReportScheduleRequest struct {
+	Name       *string  `json:"name"`
+	Period     *string  `json:"period" binding:"omitempty,oneof=weekly monthly"`
+	Schedule   *string  `json:"schedule"`
+	GroupID    *int     `json:"groupId" binding:"omitempty,min=0"` // 0 reports on all servers
+	Tag        *string  `json:"tag"`
+	Recipients []string `json:"recipients" binding:"omitempty,min=1,dive,email"` // Replaces the recipients when present
+}
+
+// Report represents a generated availability report. The rendered HTML and CSV are
+// downloaded separately.
+type Report struct {
+	ID            int        `db:"id" json:"id"`
+	ScheduleID    *int       `db:"schedule_id" json:"scheduleId"`
+	Name          string     `db:"name" json:"name"`
+	Period        string     `db:"period" json:"period"`
+	From          time.Time  `db:"period_start" json:"from"`
+	To            time.Time  `db:"period_end" json:"to"`
+	GroupID       *int       `db:"group_id" json:"groupId"`
+	Tag           string     `db:"tag" json:"tag"`
+	HTML          string     `db:"html" json:"-"`
+	CSV           string     `db:"csv" json:"-"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"deliveredAt"`
+	DeliveryError string     `db:"delivery_error" json:"deliveryError"`
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// CreateReportRequest represents the request to generate a report for the last complete period
+type CreateReportRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Period  string `json:"period" binding:"required,oneof=weekly monthly"`
+	GroupID *int   `json:"groupId"`
+	Tag     string `json:"tag"`
+}
+
+// ReportServer summarises the availability of a server over a report period. Uptime
+// excludes checks during maintenance, durations are in seconds.
+type ReportServer struct {
+	ServerID       int
+	Name           string
+	URL            string
+	Checks         int
+	Uptime         *float64
+	PreviousUptime *float64 // Uptime over the preceding period of the same length
+	UptimeChange   *float64 // Percentage points
+	Incidents      int
+	Downtime       float64
+	MTTR           *float64 // Mean time to recovery of resolved incidents
+	Avg            *float64 // Milliseconds
+	P50            *int
+	P95            *int
+	P99            *int
+}
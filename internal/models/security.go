@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Security finding statuses
+const (
+	FindingPass = "pass"
+	FindingWarn = "warn"
+	FindingFail = "fail"
+)
+
+// SecurityFinding represents the result of a single security audit check
+type SecurityFinding struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// SecurityReport represents the outcome of a security headers and TLS audit
+type SecurityReport struct {
+	ID           int               `db:"id" json:"id"`
+	ServerID     int               `db:"server_id" json:"serverId"`
+	Grade        string            `db:"grade" json:"grade"`
+	Score        int               `db:"score" json:"score"`
+	TLSVersion   string            `db:"tls_version" json:"tlsVersion"`
+	CipherSuite  string            `db:"cipher_suite" json:"cipherSuite"`
+	AcceptsTLS10 bool              `db:"accepts_tls10" json:"acceptsTls10"`
+	AcceptsTLS11 bool              `db:"accepts_tls11" json:"acceptsTls11"`
+	FindingsJSON string            `db:"findings" json:"-"`
+	Findings     []SecurityFinding `db:"-" json:"findings"`
+	Error        *string           `db:"error" json:"error"`
+	CheckedAt    time.Time         `db:"checked_at" json:"checkedAt"`
+}
+
+// SecurityChange describes a difference between two consecutive security reports
+type SecurityChange struct {
+	Check     string    `json:"check"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// SecurityOverview is the response for the security endpoint of a server
+type SecurityOverview struct {
+	Latest  *SecurityReport  `json:"latest"`
+	History []SecurityReport `json:"history"`
+	Changes []SecurityChange `json:"changes"`
+}
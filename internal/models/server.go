@@ -2,61 +2,180 @@ package models
 
 import "time"
 
+// Monitor types
+const (
+	TypeHTTP    = "http"
+	TypeCrawler = "crawler"
+)
+
+// Connection modes
+const (
+	ConnectionKeepAlive = "keepalive"
+	ConnectionFresh     = "fresh"
+)
+
+// Address families
+const (
+	FamilyAuto = "auto"
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyBoth = "both"
+)
+
+// Server states
+const (
+	StateUp          = "UP"
+	StateDown        = "DOWN"
+	StateDegraded    = "DEGRADED"
+	StateMaintenance = "MAINTENANCE"
+	StateUnreachable = "UNREACHABLE"
+)
+
 // Server represents a server to be monitored
 type Server struct {
-	ID             int       `db:"id" json:"id"`
-	Name           string    `db:"name" json:"name"`
-	Description    string    `db:"description" json:"description"`
-	URL            string    `db:"url" json:"url"`
-	Method         string    `db:"method" json:"method"`
-	Interval       int       `db:"interval" json:"interval"`
-	Timeout        int       `db:"timeout" json:"timeout"`
-	ExpectedStatus int       `db:"expected_status" json:"expectedStatus"`
-	CreatedAt      time.Time `db:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time `db:"updated_at" json:"updatedAt"`
+	ID                   int               `db:"id" json:"id"`
+	Name                 string            `db:"name" json:"name"`
+	Description          string            `db:"description" json:"description"`
+	Type                 string            `db:"type" json:"type"`
+	URL                  string            `db:"url" json:"url"`
+	Method               string            `db:"method" json:"method"`
+	Interval             int               `db:"interval" json:"interval"`
+	Timeout              int               `db:"timeout" json:"timeout"`
+	ExpectedStatus       int               `db:"expected_status" json:"expectedStatus"`
+	SecurityAudit        bool              `db:"security_audit" json:"securityAudit"`
+	AuditInterval        int               `db:"audit_interval" json:"auditInterval"` // Minimum milliseconds between security audits, independent of the check interval; 0 uses config.SecurityAuditInterval
+	CrawlDepth           int               `db:"crawl_depth" json:"crawlDepth"`
+	CrawlMaxPages        int               `db:"crawl_max_pages" json:"crawlMaxPages"`
+	ProxyURL             string            `db:"proxy_url" json:"proxyUrl"`
+	ResolverAddress      string            `db:"resolver_address" json:"resolverAddress"`
+	ResolveIP            string            `db:"resolve_ip" json:"resolveIp"`
+	SourceAddress        string            `db:"source_address" json:"sourceAddress"`
+	ClientCertID         *int              `db:"client_cert_id" json:"clientCertId"`
+	CABundleID           *int              `db:"ca_bundle_id" json:"caBundleId"`
+	InsecureSkipVerify   bool              `db:"insecure_skip_verify" json:"insecureSkipVerify"`
+	ConnectionMode       string            `db:"connection_mode" json:"connectionMode"`
+	AddressFamily        string            `db:"address_family" json:"addressFamily"`
+	Enabled              bool              `db:"enabled" json:"enabled"`
+	InMaintenance        bool              `db:"-" json:"inMaintenance"`
+	ParentIDs            []int             `db:"-" json:"parentIds"`
+	GroupID              *int              `db:"group_id" json:"groupId"`
+	Tags                 map[string]string `db:"-" json:"tags"`
+	LastState            string            `db:"last_state" json:"lastState"`
+	LastCheckedAt        *time.Time        `db:"last_checked_at" json:"lastCheckedAt"`
+	LastResponseTime     *int              `db:"last_response_time" json:"lastResponseTime"`
+	CertificateExpiresAt *time.Time        `db:"certificate_expires_at" json:"certificateExpiresAt"` // Expiry of the TLS certificate last served
+	Status               *ServerStatus     `db:"-" json:"status,omitempty"`                          // Included on request
+	Uptime               *UptimeSummary    `db:"-" json:"uptime,omitempty"`                          // Included on request
+	CreatedAt            time.Time         `db:"created_at" json:"createdAt"`
+	UpdatedAt            time.Time         `db:"updated_at" json:"updatedAt"`
 }
 
 // ServerStatus represents the current status of a server
 type ServerStatus struct {
-	IsUp         bool      `db:"is_up" json:"isUp"`
-	StatusCode   *int      `db:"status_code" json:"statusCode"`
-	ResponseTime *int      `db:"response_time" json:"responseTime"`
-	ResponseBody *string   `db:"response_body" json:"responseBody"`
-	Error        *string   `db:"error" json:"error"`
-	LastChecked  time.Time `db:"checked_at" json:"lastChecked"`
-	State        string    `json:"state"`
+	IsUp          bool              `db:"is_up" json:"isUp"`
+	StatusCode    *int              `db:"status_code" json:"statusCode"`
+	ResponseTime  *int              `db:"response_time" json:"responseTime"`
+	ResponseBody  *string           `db:"response_body" json:"responseBody"`
+	Error         *string           `db:"error" json:"error"`
+	LastChecked   time.Time         `db:"checked_at" json:"lastChecked"`
+	State         string            `db:"state" json:"state"`
+	AddressFamily string            `db:"address_family" json:"addressFamily"`
+	RootCauseID   *int              `db:"root_cause_id" json:"rootCauseId,omitempty"`
+	Timings       *CheckTimings     `db:"-" json:"timings,omitempty"`
+	Assertions    []AssertionResult `db:"-" json:"assertions,omitempty"`
+	Certificate   *PeerCertificate  `db:"-" json:"certificate,omitempty"` // Served over HTTPS
+}
+
+// PeerCertificate describes the TLS certificate a server presented during a check
+type PeerCertificate struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"notAfter"`
+}
+
+// CheckTimings breaks down how long each phase of an HTTP check took, in milliseconds
+type CheckTimings struct {
+	DNS       int  `json:"dns"`
+	Connect   int  `json:"connect"`
+	TLS       int  `json:"tls"`
+	FirstByte int  `json:"firstByte"`
+	Total     int  `json:"total"`
+	Reused    bool `json:"reused"`
+}
+
+// AssertionResult represents the outcome of a single check assertion
+type AssertionResult struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
 }
 
 // ServerHistory represents a historical status record
 type ServerHistory struct {
-	ID           int       `db:"id" json:"id"`
-	ServerID     int       `db:"server_id" json:"serverId"`
-	IsUp         bool      `db:"is_up" json:"isUp"`
-	StatusCode   *int      `db:"status_code" json:"statusCode"`
-	ResponseTime *int      `db:"response_time" json:"responseTime"`
-	ResponseBody *string   `db:"response_body" json:"responseBody"`
-	Error        *string   `db:"error" json:"error"`
-	CheckedAt    time.Time `db:"checked_at" json:"checkedAt"`
-	State        string    `json:"state"`
+	ID            int       `db:"id" json:"id"`
+	ServerID      int       `db:"server_id" json:"serverId"`
+	IsUp          bool      `db:"is_up" json:"isUp"`
+	StatusCode    *int      `db:"status_code" json:"statusCode"`
+	ResponseTime  *int      `db:"response_time" json:"responseTime"`
+	ResponseBody  *string   `db:"response_body" json:"responseBody"`
+	Error         *string   `db:"error" json:"error"`
+	CheckedAt     time.Time `db:"checked_at" json:"checkedAt"`
+	State         string    `db:"state" json:"state"`
+	AddressFamily string    `db:"address_family" json:"addressFamily"`
+	RootCauseID   *int      `db:"root_cause_id" json:"rootCauseId,omitempty"`
 }
 
 // CreateServerRequest represents the request to create a new server
 type CreateServerRequest struct {
-	Name           string  `json:"name" binding:"required"`
-	URL            string  `json:"url" binding:"required,url"`
-	Description    *string `json:"description,omitempty"`
-	Method         string  `json:"method" binding:"required,oneof=GET POST HEAD"`
-	ExpectedStatus int     `json:"expectedStatus" binding:"required,min=100,max=599"`
-	Timeout        int     `json:"timeout" binding:"required,min=1000"`
-	Interval       int     `json:"interval" binding:"required,min=5000"`
+	Name               string            `json:"name" binding:"required"`
+	URL                string            `json:"url" binding:"required,url"`
+	Description        *string           `json:"description,omitempty"`
+	Type               string            `json:"type" binding:"omitempty,oneof=http crawler"`
+	Method             string            `json:"method" binding:"required,oneof=GET POST HEAD"`
+	ExpectedStatus     int               `json:"expectedStatus" binding:"required,min=100,max=599"`
+	Timeout            int               `json:"timeout" binding:"required,min=1000"`
+	Interval           int               `json:"interval" binding:"required,min=5000"`
+	SecurityAudit      bool              `json:"securityAudit"`
+	AuditInterval      int               `json:"auditInterval" binding:"omitempty,min=60000"` // Defaults to an hour
+	CrawlDepth         *int              `json:"crawlDepth" binding:"omitempty,min=0,max=10"` // Defaults to 2 for crawlers, 0 only crawls the start page
+	CrawlMaxPages      int               `json:"crawlMaxPages" binding:"omitempty,min=1,max=1000"`
+	ProxyURL           string            `json:"proxyUrl" binding:"omitempty,eq=direct|url"`
+	ResolverAddress    string            `json:"resolverAddress" binding:"omitempty,ip|hostname_port"`
+	ResolveIP          string            `json:"resolveIp" binding:"omitempty,ip"`
+	SourceAddress      string            `json:"sourceAddress"`
+	ClientCertID       *int              `json:"clientCertId"`
+	CABundleID         *int              `json:"caBundleId"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify"`
+	ConnectionMode     string            `json:"connectionMode" binding:"omitempty,oneof=keepalive fresh"`
+	AddressFamily      string            `json:"addressFamily" binding:"omitempty,oneof=auto ipv4 ipv6 both"`
+	ParentIDs          []int             `json:"parentIds"`
+	GroupID            *int              `json:"groupId"`
+	Tags               map[string]string `json:"tags" binding:"omitempty,dive,keys,required,excludes=:,endkeys"`
 }
 
 // UpdateServerRequest represents the request to update a server
 type UpdateServerRequest struct {
-	Name           *string `json:"name"`
-	URL            *string `json:"url" binding:"omitempty,url"`
-	Method         *string `json:"method" binding:"omitempty,oneof=GET POST HEAD"`
-	ExpectedStatus *int    `json:"expectedStatus" binding:"omitempty,min=100,max=599"`
-	Timeout        *int    `json:"timeout" binding:"omitempty,min=1000"`
-	Interval       *int    `json:"interval" binding:"omitempty,min=5000"`
+	Name               *string           `json:"name"`
+	URL                *string           `json:"url" binding:"omitempty,url"`
+	Method             *string           `json:"method" binding:"omitempty,oneof=GET POST HEAD"`
+	ExpectedStatus     *int              `json:"expectedStatus" binding:"omitempty,min=100,max=599"`
+	Timeout            *int              `json:"timeout" binding:"omitempty,min=1000"`
+	Interval           *int              `json:"interval" binding:"omitempty,min=5000"`
+	SecurityAudit      *bool             `json:"securityAudit"`
+	AuditInterval      *int              `json:"auditInterval" binding:"omitempty,min=60000"`
+	CrawlDepth         *int              `json:"crawlDepth" binding:"omitempty,min=0,max=10"`
+	CrawlMaxPages      *int              `json:"crawlMaxPages" binding:"omitempty,min=1,max=1000"`
+	ProxyURL           *string           `json:"proxyUrl" binding:"omitempty,eq=|eq=direct|url"`
+	ResolverAddress    *string           `json:"resolverAddress" binding:"omitempty,eq=|ip|hostname_port"`
+	ResolveIP          *string           `json:"resolveIp" binding:"omitempty,eq=|ip"`
+	SourceAddress      *string           `json:"sourceAddress"`
+	ClientCertID       *int              `json:"clientCertId" binding:"omitempty,min=0"`
+	CABundleID         *int              `json:"caBundleId" binding:"omitempty,min=0"`
+	InsecureSkipVerify *bool             `json:"insecureSkipVerify"`
+	ConnectionMode     *string           `json:"connectionMode" binding:"omitempty,oneof=keepalive fresh"`
+	AddressFamily      *string           `json:"addressFamily" binding:"omitempty,oneof=auto ipv4 ipv6 both"`
+	ParentIDs          []int             `json:"parentIds"`                                                      // Replaces the parents when present, [] clears them
+	GroupID            *int              `json:"groupId" binding:"omitempty,min=0"`                              // 0 removes the server from its group
+	Tags               map[string]string `json:"tags" binding:"omitempty,dive,keys,required,excludes=:,endkeys"` // Replaces the tags when present
 }
@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// SLO types
+const (
+	SLOAvailability = "availability"
+	SLOLatency      = "latency"
+)
+
+// Calendar periods of SLOs
+const (
+	PeriodWeek    = "week"
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+)
+
+// SLO represents a service level objective of a server, or of every server in a group.
+// Availability SLOs count successful checks as good events, latency SLOs count checks
+// responding within the threshold, so "p95 below 300ms" is a latency SLO with a target of
+// 95 and a threshold of 300. Checks during maintenance windows are not counted.
+type SLO struct {
+	ID             int       `db:"id" json:"id"`
+	Name           string    `db:"name" json:"name"`
+	Description    string    `db:"description" json:"description"`
+	ServerID       *int      `db:"server_id" json:"serverId"`
+	GroupID        *int      `db:"group_id" json:"groupId"`
+	Type           string    `db:"type" json:"type"`
+	Target         float64   `db:"target" json:"target"`                 // Percentage of good events
+	Threshold      *int      `db:"threshold" json:"threshold,omitempty"` // Milliseconds, latency SLOs only
+	WindowDays     int       `db:"window_days" json:"windowDays"`        // Length of the rolling window
+	CalendarPeriod string    `db:"calendar_period" json:"calendarPeriod"`
+	CreatedAt      time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// CreateSLORequest represents the request to create an SLO for either a server or a group
+type CreateSLORequest struct {
+	Name           string  `json:"name" binding:"required"`
+	Description    string  `json:"description"`
+	ServerID       *int    `json:"serverId"`
+	GroupID        *int    `json:"groupId"`
+	Type           string  `json:"type" binding:"required,oneof=availability latency"`
+	Target         float64 `json:"target" binding:"required,gt=0,lt=100"`
+	Threshold      *int    `json:"threshold" binding:"omitempty,min=1"`
+	WindowDays     int     `json:"windowDays" binding:"omitempty,min=1,max=365"`
+	CalendarPeriod string  `json:"calendarPeriod" binding:"omitempty,oneof=week month quarter"`
+}
+
+// UpdateSLORequest represents the request to update an SLO
+type UpdateSLORequest struct {
+	Name           *string  `json:"name"`
+	Description    *string  `json:"description"`
+	Target         *float64 `json:"target" binding:"omitempty,gt=0,lt=100"`
+	Threshold      *int     `json:"threshold" binding:"omitempty,min=1"`
+	WindowDays     *int     `json:"windowDays" binding:"omitempty,min=1,max=365"`
+	CalendarPeriod *string  `json:"calendarPeriod" binding:"omitempty,oneof=week month quarter"`
+}
+
+// SLOWindow represents how an SLO was attained over a time window. SLI, error budget and
+// burn rate are nil when no events were recorded in the window.
+type SLOWindow struct {
+	Start                time.Time `json:"start"`
+	End                  time.Time `json:"end"`
+	Events               int       `json:"events"`
+	BadEvents            int       `json:"badEvents"`
+	SLI                  *float64  `json:"sli"`                  // Percentage of good events
+	ErrorBudgetRemaining *float64  `json:"errorBudgetRemaining"` // Percentage of the error budget left, negative once exhausted
+	BurnRate             *float64  `json:"burnRate"`             // Rate the budget is consumed at, 1 uses it up exactly at the end of the window
+	Met                  bool      `json:"met"`
+}
+
+// SLOReport represents the attainment of an SLO over its rolling window and its current
+// calendar period
+type SLOReport struct {
+	SLO      SLO       `json:"slo"`
+	Rolling  SLOWindow `json:"rolling"`
+	Calendar SLOWindow `json:"calendar"`
+}
+
+// Burn rate alert severities
+const (
+	SeverityPage   = "page"   // Fast burn, needs attention now
+	SeverityTicket = "ticket" // Slow burn, needs attention soon
+)
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// SLOAlert represents a multi-window burn rate alert of an SLO. It fires when the error
+// budget burns faster than the threshold over both the long and the short window, and
+// resolves once either window drops back below it.
+type SLOAlert struct {
+	ID            int        `db:"id" json:"id"`
+	SLOID         int        `db:"slo_id" json:"sloId"`
+	Severity      string     `db:"severity" json:"severity"`
+	State         string     `db:"state" json:"state"`
+	LongWindow    int        `db:"long_window" json:"longWindow"`   // Minutes
+	ShortWindow   int        `db:"short_window" json:"shortWindow"` // Minutes
+	Threshold     float64    `db:"threshold" json:"threshold"`
+	LongBurnRate  float64    `db:"long_burn_rate" json:"longBurnRate"` // Burn rates when the alert fired
+	ShortBurnRate float64    `db:"short_burn_rate" json:"shortBurnRate"`
+	FiredAt       time.Time  `db:"fired_at" json:"firedAt"`
+	ResolvedAt    *time.Time `db:"resolved_at" json:"resolvedAt"`
+}
@@ -0,0 +1,61 @@
+package models
+
+// Tag represents a key/value label attached to a server
+type Tag struct {
+	ServerID int    `db:"server_id" json:"serverId"`
+	Key      string `db:"key" json:"key"`
+	Value    string `db:"value" json:"value"`
+}
+
+// TagSummary represents a tag in use and how many servers carry it
+type TagSummary struct {
+	Key     string `db:"key" json:"key"`
+	Value   string `db:"value" json:"value"`
+	Servers int    `db:"servers" json:"servers"`
+}
+
+// TagSelector matches servers by tag, an empty value matches any value of the key
+type TagSelector struct {
+	Key   string
+	Value string
+}
+
+// SetTagRequest represents the request to set the value of a server tag
+type SetTagRequest struct {
+	Value string `json:"value"`
+}
+
+// ServerFilter restricts which servers are listed
+type ServerFilter struct {
+	Search  string        // Every word must start a word of the name, URL or description
+	States  []string      // Latest state, any of
+	Types   []string      // Monitor type, any of
+	Enabled *bool         // Paused or active servers only
+	Tags    []TagSelector // Servers must match every selector
+	GroupID *int          // Includes servers in subgroups
+}
+
+// Server list sort fields
+const (
+	SortName         = "name"
+	SortState        = "state"
+	SortLastChecked  = "lastChecked"
+	SortResponseTime = "responseTime"
+	SortCreatedAt    = "createdAt"
+)
+
+// ServerQuery represents a filtered, sorted and paginated server list request
+type ServerQuery struct {
+	Filter     ServerFilter
+	Sort       string
+	Descending bool
+	Cursor     string // Opaque cursor from a previous page
+	Limit      int    // 0 returns all remaining servers
+}
+
+// ServerPage represents a page of the server list
+type ServerPage struct {
+	Servers    []Server
+	Total      int    // Servers matching the filter across all pages
+	NextCursor string // Empty on the last page
+}
@@ -0,0 +1,16 @@
+package models
+
+// UptimeStats summarises the checks of a server over a period. Checks during maintenance
+// windows are excluded. Uptime and latency are nil when there were no checks.
+type UptimeStats struct {
+	Checks         int      `json:"checks"`
+	Uptime         *float64 `json:"uptime"`         // Percentage of successful checks
+	AverageLatency *float64 `json:"averageLatency"` // Milliseconds
+}
+
+// UptimeSummary represents the uptime of a server over the last day, week and month
+type UptimeSummary struct {
+	Day   UptimeStats `json:"24h"`
+	Week  UptimeStats `json:"7d"`
+	Month UptimeStats `json:"30d"`
+}
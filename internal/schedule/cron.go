@@ -0,0 +1,178 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression (minute hour day-of-month month day-of-week)
+type Cron struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+// cronField describes the valid range of a cron field
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a standard five-field cron expression
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	c := &Cron{
+		anyDay:  fields[2] == "*",
+		anyWeek: fields[4] == "*",
+	}
+	for i, field := range fields {
+		values, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			switch i {
+			case 0:
+				c.minutes[v] = true
+			case 1:
+				c.hours[v] = true
+			case 2:
+				c.days[v] = true
+			case 3:
+				c.months[v] = true
+			case 4:
+				c.weekdays[v%7] = true // Both 0 and 7 mean Sunday
+			}
+		}
+	}
+	return c, nil
+}
+
+// parseCronField expands a single cron field into the values it matches
+func parseCronField(field string, spec cronField) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field %q", spec.name, part)
+			}
+			part = part[:idx]
+		}
+
+		low, high := spec.min, spec.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range in %s field %q", spec.name, part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %s field %q", spec.name, part)
+			}
+			low, high = value, value
+			if step > 1 {
+				high = spec.max
+			}
+		}
+
+		if low < spec.min || high > spec.max || low > high {
+			return nil, fmt.Errorf("%s field %q is out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+		for v := low; v <= high; v += step {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// dayMatches reports whether the date of t matches the day fields. As in standard cron,
+// when both day fields are restricted a day matches if either of them does.
+func (c *Cron) dayMatches(t time.Time) bool {
+	if !c.months[t.Month()] {
+		return false
+	}
+	day := c.days[t.Day()]
+	weekday := c.weekdays[t.Weekday()]
+	switch {
+	case c.anyDay && c.anyWeek:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeek:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Matches reports whether the minute containing t matches the expression
+func (c *Cron) Matches(t time.Time) bool {
+	return c.dayMatches(t) && c.hours[t.Hour()] && c.minutes[t.Minute()]
+}
+
+// Next returns the first matching minute strictly after t, searching at most a few years ahead
+func (c *Cron) Next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hours[t.Hour()]:
+			// Step in elapsed time, rebuilding the hour from the wall clock would land in the
+			// wrong instance of an hour repeated when daylight saving ends
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Previous returns the last matching minute at or before t, searching back no further than after
+func (c *Cron) Previous(t time.Time, after time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for !t.Before(after) {
+		switch {
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !c.hours[t.Hour()]:
+			t = t.Add(-time.Duration(t.Minute()+1) * time.Minute)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(-time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step", "*/15 * * * *", time.Date(2026, 10, 18, 10, 7, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 15, 0, 0, time.UTC)},
+		{"strictly after a match", "*/15 * * * *", time.Date(2026, 10, 18, 10, 15, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)},
+		{"step from a value", "5/20 * * * *", time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC), time.Date(2026, 10, 18, 10, 45, 0, 0, time.UTC)},
+		{"stepped range", "0 9-17/4 * * *", time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)},
+		{"stepped range wraps to the next day", "0 9-17/4 * * *", time.Date(2026, 10, 18, 17, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"list", "0 0 1,15 * *", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{"month range", "0 3 1 1-3 *", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{"day of month or day of week, weekday first", "30 4 1 * 1", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 4, 30, 0, 0, time.UTC)},
+		{"day of month or day of week, month day first", "30 4 1 * 1", time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 4, 30, 0, 0, time.UTC)},
+		{"day of week only", "0 12 * * 1-5", time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)},
+		{"7 is Sunday", "0 12 * * 7", time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := ParseCron(test.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", test.expr, err)
+			}
+			got, ok := cron.Next(test.from)
+			if !ok || !got.Equal(test.want) {
+				t.Errorf("Next(%v) = %v, %v, want %v", test.from, got, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestCronNextNeverMatching(t *testing.T) {
+	cron, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	if next, ok := cron.Next(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("Next = %v, want no match", next)
+	}
+}
+
+func TestCronPrevious(t *testing.T) {
+	cron, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	at := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	got, ok := cron.Previous(at, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Previous = %v, %v, want %v", got, ok, want)
+	}
+	if got, ok := cron.Previous(at, time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("Previous = %v, want none after the lower bound", got)
+	}
+	got, ok = cron.Previous(time.Date(2026, 10, 18, 2, 0, 30, 0, time.UTC), at)
+	if want := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Previous within the matching minute = %v, %v, want %v", got, ok, want)
+	}
+}
+
+func TestCronUsesTheLocationOfTheTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	cron, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+
+	tests := []struct {
+		from time.Time
+		want time.Time
+	}{
+		// 09:00 in Berlin is 07:00 UTC in summer and 08:00 UTC in winter
+		{time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC), time.Date(2026, 7, 2, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 24, 8, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, ok := cron.Next(test.from.In(berlin))
+		if !ok || !got.Equal(test.want) {
+			t.Errorf("Next(%v) = %v, %v, want %v", test.from, got.UTC(), ok, test.want)
+		}
+	}
+
+	// 02:00 to 03:00 repeats when daylight saving ends on 25 October 2026
+	nightly, err := ParseCron("0 1 * * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	repeated := time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC).In(berlin)
+	got, ok := nightly.Previous(repeated, repeated.AddDate(0, 0, -1))
+	if want := time.Date(2026, 10, 24, 23, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Previous in the repeated hour = %v, %v, want %v", got.UTC(), ok, want)
+	}
+	got, ok = nightly.Next(repeated)
+	if want := time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Next in the repeated hour = %v, %v, want %v", got.UTC(), ok, want)
+	}
+}
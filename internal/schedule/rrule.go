@@ -0,0 +1,185 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence produces the start times of a repeating event
+type Recurrence interface {
+	// Previous returns the last occurrence at or before t, searching back no further than after
+	Previous(t time.Time, after time.Time) (time.Time, bool)
+	// Next returns the first occurrence strictly after t
+	Next(t time.Time) (time.Time, bool)
+}
+
+// Parse parses either an RRULE (prefixed with "RRULE:" or containing "FREQ=") or a cron expression.
+// The start time anchors RRULE occurrences and provides their time of day.
+func Parse(expr string, start time.Time) (Recurrence, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(strings.ToUpper(expr), "RRULE:") || strings.Contains(strings.ToUpper(expr), "FREQ=") {
+		return ParseRRule(expr, start)
+	}
+	return ParseCron(expr)
+}
+
+// RRule is a parsed subset of an RFC 5545 recurrence rule supporting FREQ (DAILY, WEEKLY,
+// MONTHLY), INTERVAL, BYDAY, BYMONTHDAY and UNTIL
+type RRule struct {
+	freq       string
+	interval   int
+	byDay      map[time.Weekday]bool
+	byMonthDay map[int]bool
+	until      *time.Time
+	start      time.Time
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule parses a recurrence rule anchored at start
+func ParseRRule(expr string, start time.Time) (*RRule, error) {
+	expr = strings.TrimPrefix(strings.TrimSpace(expr), "RRULE:")
+	r := &RRule{
+		interval:   1,
+		byDay:      map[time.Weekday]bool{},
+		byMonthDay: map[int]bool{},
+		start:      start,
+	}
+
+	for _, part := range strings.Split(expr, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY":
+				r.freq = value
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid interval %q", value)
+			}
+			r.interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("invalid weekday %q", day)
+				}
+				r.byDay[weekday] = true
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay < 1 || monthDay > 31 {
+					return nil, fmt.Errorf("invalid month day %q", day)
+				}
+				r.byMonthDay[monthDay] = true
+			}
+		case "UNTIL":
+			until, err := parseRRuleTime(value, start.Location())
+			if err != nil {
+				return nil, err
+			}
+			r.until = &until
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if r.freq == "" {
+		return nil, fmt.Errorf("rule is missing FREQ")
+	}
+	if r.freq == "WEEKLY" && len(r.byDay) == 0 {
+		r.byDay[start.Weekday()] = true
+	}
+	if r.freq == "MONTHLY" && len(r.byMonthDay) == 0 {
+		r.byMonthDay[start.Day()] = true
+	}
+	return r, nil
+}
+
+// parseRRuleTime parses the date and date-time formats allowed in UNTIL
+func parseRRuleTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			if strings.HasSuffix(value, "Z") {
+				t, _ = time.Parse(layout, value)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL value %q", value)
+}
+
+// occurrenceOn returns the occurrence on the given day and whether the rule produces one
+func (r *RRule) occurrenceOn(day time.Time) (time.Time, bool) {
+	occurrence := time.Date(day.Year(), day.Month(), day.Day(), r.start.Hour(), r.start.Minute(), r.start.Second(), 0, r.start.Location())
+	if occurrence.Before(r.start) || (r.until != nil && occurrence.After(*r.until)) {
+		return time.Time{}, false
+	}
+
+	startDay := time.Date(r.start.Year(), r.start.Month(), r.start.Day(), 0, 0, 0, 0, time.UTC)
+	thisDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch r.freq {
+	case "DAILY":
+		days := int(thisDay.Sub(startDay).Hours() / 24)
+		return occurrence, days%r.interval == 0 && (len(r.byDay) == 0 || r.byDay[day.Weekday()])
+	case "WEEKLY":
+		startWeek := startDay.AddDate(0, 0, -int(startDay.Weekday()))
+		thisWeek := thisDay.AddDate(0, 0, -int(thisDay.Weekday()))
+		weeks := int(thisWeek.Sub(startWeek).Hours() / (24 * 7))
+		return occurrence, weeks%r.interval == 0 && r.byDay[day.Weekday()]
+	case "MONTHLY":
+		months := (day.Year()-r.start.Year())*12 + int(day.Month()-r.start.Month())
+		return occurrence, months%r.interval == 0 && r.byMonthDay[day.Day()]
+	}
+	return time.Time{}, false
+}
+
+// Previous returns the last occurrence at or before t, searching back no further than after
+func (r *RRule) Previous(t time.Time, after time.Time) (time.Time, bool) {
+	t = t.In(r.start.Location())
+	for day := t; !day.Before(after.AddDate(0, 0, -1)); day = day.AddDate(0, 0, -1) {
+		occurrence, ok := r.occurrenceOn(day)
+		if ok && !occurrence.After(t) && !occurrence.Before(after) {
+			return occurrence, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first occurrence strictly after t, searching at most a few years ahead
+func (r *RRule) Next(t time.Time) (time.Time, bool) {
+	t = t.In(r.start.Location())
+	limit := t.AddDate(5, 0, 0)
+	for day := t; day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if r.until != nil && day.After(r.until.AddDate(0, 0, 1)) {
+			break
+		}
+		occurrence, ok := r.occurrenceOn(day)
+		if ok && occurrence.After(t) {
+			return occurrence, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRuleRejectsInvalidRules(t *testing.T) {
+	start := time.Date(2026, 10, 5, 22, 0, 0, 0, time.UTC)
+	for _, expr := range []string{
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=3",
+		"FREQ",
+	} {
+		if _, err := ParseRRule(expr, start); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestParseChoosesTheSyntax(t *testing.T) {
+	start := time.Date(2026, 10, 5, 22, 0, 0, 0, time.UTC)
+	if recurrence, err := Parse("0 22 * * 1", start); err != nil {
+		t.Errorf("Parse cron: %v", err)
+	} else if _, ok := recurrence.(*Cron); !ok {
+		t.Errorf("Parse cron = %T, want *Cron", recurrence)
+	}
+	for _, expr := range []string{"RRULE:FREQ=DAILY", "freq=daily;interval=2"} {
+		if recurrence, err := Parse(expr, start); err != nil {
+			t.Errorf("Parse(%q): %v", expr, err)
+		} else if _, ok := recurrence.(*RRule); !ok {
+			t.Errorf("Parse(%q) = %T, want *RRule", expr, recurrence)
+		}
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	// A Monday at 22:00 anchors every rule
+	start := time.Date(2026, 10, 5, 22, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 22, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time // Zero when the rule has no further occurrence
+	}{
+		{"daily", "FREQ=DAILY", start, at(10, 6)},
+		{"first occurrence is the start", "FREQ=DAILY", start.Add(-time.Hour), start},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", start, at(10, 8)},
+		{"daily interval counts from the start", "FREQ=DAILY;INTERVAL=3", at(10, 9), at(10, 11)},
+		{"daily by day", "FREQ=DAILY;BYDAY=SA,SU", start, at(10, 10)},
+		{"weekly defaults to the start weekday", "FREQ=WEEKLY", at(10, 6), at(10, 12)},
+		{"weekly by day in the start week", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", at(10, 6), at(10, 8)},
+		{"weekly interval skips weeks", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", at(10, 9), at(10, 19)},
+		{"monthly defaults to the start day", "RRULE:FREQ=MONTHLY", start, at(11, 5)},
+		{"monthly by month day", "FREQ=MONTHLY;BYMONTHDAY=1,15", start, at(10, 15)},
+		{"monthly interval", "FREQ=MONTHLY;INTERVAL=2", start, at(12, 5)},
+		{"monthly skips months without the day", "FREQ=MONTHLY;BYMONTHDAY=31", at(10, 31), time.Date(2026, 12, 31, 22, 0, 0, 0, time.UTC)},
+		{"until includes its last occurrence", "FREQ=DAILY;UNTIL=20261007T235959Z", at(10, 6), at(10, 7)},
+		{"until ends the rule", "FREQ=DAILY;UNTIL=20261007T235959Z", at(10, 7), time.Time{}},
+		{"until before the start", "FREQ=DAILY;UNTIL=20261001T000000Z", start.Add(-time.Hour), time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.expr, start)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", test.expr, err)
+			}
+			got, ok := rule.Next(test.from)
+			if test.want.IsZero() {
+				if ok {
+					t.Errorf("Next(%v) = %v, want no occurrence", test.from, got)
+				}
+				return
+			}
+			if !ok || !got.Equal(test.want) {
+				t.Errorf("Next(%v) = %v, %v, want %v", test.from, got, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestRRulePrevious(t *testing.T) {
+	start := time.Date(2026, 10, 5, 22, 0, 0, 0, time.UTC)
+	rule, err := ParseRRule("FREQ=DAILY", start)
+	if err != nil {
+		t.Fatalf("ParseRRule: %v", err)
+	}
+	at := time.Date(2026, 10, 8, 3, 0, 0, 0, time.UTC)
+
+	got, ok := rule.Previous(at, time.Date(2026, 10, 7, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 7, 22, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Previous = %v, %v, want %v", got, ok, want)
+	}
+	if got, ok := rule.Previous(at, time.Date(2026, 10, 7, 23, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("Previous = %v, want none after the lower bound", got)
+	}
+	if got, ok := rule.Previous(start.Add(-time.Minute), start.AddDate(0, 0, -7)); ok {
+		t.Errorf("Previous = %v, want none before the start", got)
+	}
+}
+
+func TestRRuleKeepsLocalTimeAcrossDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// A Tuesday at 09:00 EDT, the clocks go back on 1 November 2026
+	start := time.Date(2026, 10, 20, 9, 0, 0, 0, newYork)
+	rule, err := ParseRRule("FREQ=WEEKLY", start)
+	if err != nil {
+		t.Fatalf("ParseRRule: %v", err)
+	}
+
+	got, ok := rule.Next(time.Date(2026, 10, 20, 13, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 27, 13, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Next before the change = %v, %v, want %v", got.UTC(), ok, want)
+	}
+	got, ok = rule.Next(time.Date(2026, 10, 27, 13, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 11, 3, 14, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Next after the change = %v, %v, want %v", got.UTC(), ok, want)
+	}
+
+	until, err := ParseRRule("FREQ=DAILY;UNTIL=20261021T090000", start)
+	if err != nil {
+		t.Fatalf("ParseRRule: %v", err)
+	}
+	got, ok = until.Next(start)
+	if want := time.Date(2026, 10, 21, 9, 0, 0, 0, newYork); !ok || !got.Equal(want) {
+		t.Errorf("Next with a local UNTIL = %v, %v, want %v", got, ok, want)
+	}
+	if got, ok := until.Next(got); ok {
+		t.Errorf("Next after a local UNTIL = %v, want none", got)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// AnalyticsService computes reliability statistics from check history and incidents
+type AnalyticsService struct {
+	db      *sqlx.DB
+	history *HistoryService
+}
+
+// NewAnalyticsService creates a new analytics service instance
+func NewAnalyticsService(db *sqlx.DB, history *HistoryService) *AnalyticsService {
+	return &AnalyticsService{
+		db:      db,
+		history: history,
+	}
+}
+
+// GetServerReliability computes the reliability statistics of a server between from and to,
+// broken down by calendar week or month if breakdown is set. It returns nil if the server
+// does not exist.
+func (s *AnalyticsService) GetServerReliability(id int, from, to time.Time, breakdown string) (*models.ReliabilityReport, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", id); err != nil {
+		logger.Error("Failed to get server %d: %v", id, err)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	return s.reliability([]int{id}, from, to, breakdown)
+}
+
+// GetGroupReliability computes the combined reliability statistics of the servers in a group
+// and its subgroups. It returns nil if the group does not exist.
+func (s *AnalyticsService) GetGroupReliability(id int, from, to time.Time, breakdown string) (*models.ReliabilityReport, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM server_groups WHERE id = ?", id); err != nil {
+		logger.Error("Failed to get group %d: %v", id, err)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	subtree, err := groupWithDescendants(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := sqlx.In("SELECT id FROM servers WHERE group_id IN (?) ORDER BY id", subtree)
+	if err != nil {
+		return nil, err
+	}
+	members := []int{}
+	if err := s.db.Select(&members, query, args...); err != nil {
+		logger.Error("Failed to get members of group %d: %v", id, err)
+		return nil, err
+	}
+	return s.reliability(members, from, to, breakdown)
+}
+
+// reliabilityStats accumulates the statistics of one time range
+type reliabilityStats struct {
+	stats                        models.ReliabilityStats
+	detect, acknowledge, resolve []float64
+}
+
+// finish computes the means and rates of the accumulated statistics
+func (r *reliabilityStats) finish() models.ReliabilityStats {
+	stats := r.stats
+	stats.MTTD = mean(r.detect)
+	stats.MTTA = mean(r.acknowledge)
+	stats.MTTR = mean(r.resolve)
+	if days := stats.To.Sub(stats.From).Hours() / 24; days > 0 {
+		stats.FlapsPerDay = float64(stats.StateChanges) / days
+	}
+	return stats
+}
+
+// reliability computes the statistics of a set of servers. Outages and incidents count
+// towards the breakdown period in which they start, state changes towards the period in
+// which they happen.
+func (s *AnalyticsService) reliability(serverIDs []int, from, to time.Time, breakdown string) (*models.ReliabilityReport, error) {
+	from, to = from.UTC(), to.UTC()
+	summary := &reliabilityStats{stats: models.ReliabilityStats{From: from, To: to}}
+	var periods []*reliabilityStats
+	if breakdown != "" {
+		for start := from; start.Before(to); {
+			_, end := calendarPeriod(start, breakdown)
+			if end.After(to) {
+				end = to
+			}
+			periods = append(periods, &reliabilityStats{stats: models.ReliabilityStats{From: start, To: end}})
+			start = end
+		}
+	}
+
+	// at returns the accumulators that an event at t counts towards
+	at := func(t time.Time) []*reliabilityStats {
+		for _, period := range periods {
+			if !t.Before(period.stats.From) && t.Before(period.stats.To) {
+				return []*reliabilityStats{summary, period}
+			}
+		}
+		return []*reliabilityStats{summary}
+	}
+
+	for _, id := range serverIDs {
+		outages, err := s.history.serverOutages(id, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, outage := range outages {
+			end := to
+			if outage.end != nil {
+				end = *outage.end
+				for _, r := range at(end) {
+					r.stats.StateChanges++
+				}
+			}
+			duration := end.Sub(outage.start).Seconds()
+			for _, r := range at(outage.start) {
+				r.stats.Outages++
+				r.stats.StateChanges++
+				r.stats.Downtime += duration
+				if r.stats.LongestOutage == nil || duration > *r.stats.LongestOutage {
+					longest := duration
+					r.stats.LongestOutage = &longest
+				}
+			}
+		}
+	}
+
+	incidents, err := s.incidents(serverIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, incident := range incidents {
+		var detect *float64
+		if incident.Automatic {
+			detect, err = s.detectionTime(incident)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, r := range at(incident.StartedAt) {
+			r.stats.Incidents++
+			if detect != nil {
+				r.detect = append(r.detect, *detect)
+			}
+			if incident.AcknowledgedAt != nil {
+				r.acknowledge = append(r.acknowledge, incident.AcknowledgedAt.Sub(incident.StartedAt).Seconds())
+			}
+			if incident.ResolvedAt != nil {
+				r.resolve = append(r.resolve, incident.ResolvedAt.Sub(incident.StartedAt).Seconds())
+			}
+		}
+	}
+
+	report := &models.ReliabilityReport{Summary: summary.finish()}
+	for _, period := range periods {
+		report.Breakdown = append(report.Breakdown, period.finish())
+	}
+	return report, nil
+}
+
+// incidents returns the incidents of a set of servers that started between from and to
+func (s *AnalyticsService) incidents(serverIDs []int, from, to time.Time) ([]models.Incident, error) {
+	incidents := []models.Incident{}
+	if len(serverIDs) == 0 {
+		return incidents, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM incidents
+		WHERE server_id IN (?) AND started_at >= ? AND started_at < ?
+		ORDER BY started_at
+	`, serverIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Select(&incidents, query, args...); err != nil {
+		logger.Error("Failed to get incidents: %v", err)
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// detectionTime returns the time between the last successful check before an automatic
+// incident and its opening, or nil if that check is no longer in the raw history
+func (s *AnalyticsService) detectionTime(incident models.Incident) (*float64, error) {
+	last := []time.Time{}
+	err := s.db.Select(&last, `
+		SELECT checked_at FROM status_history
+		WHERE server_id = ? AND is_up = 1 AND state != ? AND checked_at < ?
+		ORDER BY checked_at DESC LIMIT 1
+	`, incident.ServerID, models.StateMaintenance, incident.StartedAt)
+	if err != nil {
+		logger.Error("Failed to get last successful check of server %d: %v", incident.ServerID, err)
+		return nil, err
+	}
+	if len(last) == 0 {
+		return nil, nil
+	}
+
+	detect := incident.StartedAt.Sub(last[0]).Seconds()
+	return &detect, nil
+}
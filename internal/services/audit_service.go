@@ -0,0 +1,48 @@
+package services
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// AuditService records security-sensitive configuration changes
+type AuditService struct {
+	db *sqlx.DB
+}
+
+// NewAuditService creates a new audit service instance
+func NewAuditService(db *sqlx.DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// Record stores an audit log entry
+func (s *AuditService) Record(entry models.AuditEntry) error {
+	entry.CreatedAt = time.Now().UTC()
+	_, err := s.db.NamedExec(`
+		INSERT INTO audit_log (action, entity_type, entity_id, actor, details, created_at)
+		VALUES (:action, :entity_type, :entity_id, :actor, :details, :created_at)
+	`, entry)
+	if err != nil {
+		logger.Error("Failed to record audit entry %s: %v", entry.Action, err)
+		return err
+	}
+
+	logger.Warn("Audit: %s on %s %d by %s: %s", entry.Action, entry.EntityType, entry.EntityID, entry.Actor, entry.Details)
+	return nil
+}
+
+// GetEntries returns the most recent audit log entries
+func (s *AuditService) GetEntries(limit int) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+	err := s.db.Select(&entries, "SELECT * FROM audit_log ORDER BY created_at DESC LIMIT ?", limit)
+	if err != nil {
+		logger.Error("Failed to get audit log: %v", err)
+		return nil, err
+	}
+	return entries, nil
+}
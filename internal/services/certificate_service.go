@@ -0,0 +1,235 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/utils"
+)
+
+var (
+	// ErrInvalidCertificate is returned when uploaded PEM data cannot be parsed
+	ErrInvalidCertificate = errors.New("invalid certificate")
+
+	// ErrCertificateInUse is returned when deleting a certificate still referenced by a monitor
+	ErrCertificateInUse = errors.New("certificate is in use")
+
+	// ErrUnknownCertificate is returned when a monitor references a certificate that does not
+	// exist or is of the wrong type
+	ErrUnknownCertificate = errors.New("unknown certificate")
+)
+
+// CertificateService manages client certificates and CA bundles used by checks
+type CertificateService struct {
+	db *sqlx.DB
+}
+
+// NewCertificateService creates a new certificate service instance
+func NewCertificateService(db *sqlx.DB) *CertificateService {
+	return &CertificateService{
+		db: db,
+	}
+}
+
+// CreateCertificate validates, encrypts and stores an uploaded certificate
+func (s *CertificateService) CreateCertificate(req models.UploadCertificateRequest) (*models.Certificate, error) {
+	var leaf *x509.Certificate
+	switch req.Type {
+	case models.CertificateClient:
+		pair, err := tls.X509KeyPair([]byte(req.Certificate), []byte(req.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+		}
+		leaf, err = x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+		}
+	case models.CertificateCA:
+		certs, err := parseCertificates([]byte(req.Certificate))
+		if err != nil {
+			return nil, err
+		}
+		leaf = certs[0]
+	}
+
+	fingerprint := sha256.Sum256(leaf.Raw)
+	cert := &models.Certificate{
+		Name:        req.Name,
+		Type:        req.Type,
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		NotAfter:    leaf.NotAfter,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	encrypted, err := utils.Encrypt([]byte(req.Certificate))
+	if err != nil {
+		return nil, err
+	}
+	cert.CertPEM = encrypted
+
+	if req.Type == models.CertificateClient {
+		encryptedKey, err := utils.Encrypt([]byte(req.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		cert.KeyPEM = &encryptedKey
+	}
+
+	result, err := s.db.NamedExec(`
+		INSERT INTO certificates (name, type, subject, issuer, fingerprint, not_after, certificate, private_key, created_at)
+		VALUES (:name, :type, :subject, :issuer, :fingerprint, :not_after, :certificate, :private_key, :created_at)
+	`, cert)
+	if err != nil {
+		logger.Error("Failed to create certificate: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	cert.ID = int(id)
+	return cert, nil
+}
+
+// GetCertificates returns all stored certificates without their secret material
+func (s *CertificateService) GetCertificates() ([]models.Certificate, error) {
+	certs := []models.Certificate{}
+	err := s.db.Select(&certs, "SELECT * FROM certificates ORDER BY created_at DESC")
+	if err != nil {
+		logger.Error("Failed to get certificates: %v", err)
+		return nil, err
+	}
+	return certs, nil
+}
+
+// GetCertificateByID returns a certificate by its ID
+func (s *CertificateService) GetCertificateByID(id int) (*models.Certificate, error) {
+	var cert models.Certificate
+	err := s.db.Get(&cert, "SELECT * FROM certificates WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get certificate %d: %v", id, err)
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// DeleteCertificate deletes a certificate that is not referenced by any monitor
+func (s *CertificateService) DeleteCertificate(id int) error {
+	var references int
+	err := s.db.Get(&references, "SELECT COUNT(*) FROM servers WHERE client_cert_id = ? OR ca_bundle_id = ?", id, id)
+	if err != nil {
+		logger.Error("Failed to count references to certificate %d: %v", id, err)
+		return err
+	}
+	if references > 0 {
+		return ErrCertificateInUse
+	}
+
+	_, err = s.db.Exec("DELETE FROM certificates WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete certificate %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// TLSConfig builds the TLS configuration for checking a server
+func (s *CertificateService) TLSConfig(server models.Server) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: server.InsecureSkipVerify,
+	}
+
+	if server.ClientCertID != nil {
+		cert, err := s.loadCertificate(*server.ClientCertID, models.CertificateClient)
+		if err != nil {
+			return nil, err
+		}
+		certPEM, err := utils.Decrypt(cert.CertPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt client certificate: %w", err)
+		}
+		keyPEM, err := utils.Decrypt(*cert.KeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt client key: %w", err)
+		}
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	if server.CABundleID != nil {
+		bundle, err := s.loadCertificate(*server.CABundleID, models.CertificateCA)
+		if err != nil {
+			return nil, err
+		}
+		bundlePEM, err := utils.Decrypt(bundle.CertPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundlePEM) {
+			return nil, fmt.Errorf("CA bundle %d contains no certificates", bundle.ID)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertificate loads a certificate and ensures it has the expected type
+func (s *CertificateService) loadCertificate(id int, certType string) (*models.Certificate, error) {
+	cert, err := s.GetCertificateByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("certificate %d not found", id)
+	}
+	if cert.Type != certType {
+		return nil, fmt.Errorf("certificate %d is not a %s certificate", id, certType)
+	}
+	return cert, nil
+}
+
+// parseCertificates parses every certificate in a PEM bundle
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificates found in PEM data", ErrInvalidCertificate)
+	}
+	return certs, nil
+}
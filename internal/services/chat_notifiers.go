@@ -0,0 +1,568 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// defaultSlackAPIURL is the Slack Web API used by Slack channels with a bot token
+const defaultSlackAPIURL = "https://slack.com/api"
+
+// webhookClient sends chat and webhook notifications, requests are bounded by their context
+var webhookClient = &http.Client{}
+
+// httpDelivery posts notifications over HTTP and remembers the status of the last response
+type httpDelivery struct {
+	status *int
+}
+
+// ResponseStatus returns the HTTP status of the last response, nil if none was received
+func (d *httpDelivery) ResponseStatus() *int {
+	return d.status
+}
+
+// chatField is a labelled value shown in a chat message
+type chatField struct {
+	Name  string
+	Value string
+}
+
+// chatMessage is the platform independent content of a chat notification
+type chatMessage struct {
+	Title  string
+	Text   string
+	Color  string // Hex color of the level
+	Fields []chatField
+	Link   string // Dashboard page of the notification, empty without DASHBOARD_URL
+	Footer string
+	At     time.Time
+}
+
+// newChatMessage builds the chat message of a notification
+func newChatMessage(notification models.Notification) chatMessage {
+	message := chatMessage{
+		Title:  notification.Title,
+		Text:   notification.Message,
+		Color:  levelColor(notification.Level),
+		Link:   dashboardLink(notification),
+		Footer: notification.Event,
+		At:     notification.At,
+	}
+
+	if server := notification.Server; server != nil {
+		message.Fields = append(message.Fields, chatField{"Server", server.Name})
+		if server.State != "" {
+			message.Fields = append(message.Fields, chatField{"State", server.State})
+		}
+		if server.ResponseTime != nil {
+			message.Fields = append(message.Fields, chatField{"Response time", fmt.Sprintf("%d ms", *server.ResponseTime)})
+		}
+	}
+	if incident := notification.Incident; incident != nil {
+		if incident.LastError != "" && incident.LastError != notification.Message {
+			message.Fields = append(message.Fields, chatField{"Error", incident.LastError})
+		}
+		message.Fields = append(message.Fields, chatField{"Incident", fmt.Sprintf("#%d %s", incident.ID, incident.Status)})
+		label := "Open for"
+		if incident.ResolvedAt != nil {
+			label = "Resolved after"
+		}
+		duration := formatDuration(time.Duration(incident.Duration * float64(time.Second)))
+		message.Fields = append(message.Fields, chatField{label, duration})
+		if incident.Failures > 0 {
+			message.Fields = append(message.Fields, chatField{"Failed checks", strconv.Itoa(incident.Failures)})
+		}
+	}
+	if slo := notification.SLO; slo != nil {
+		message.Fields = append(message.Fields, chatField{"SLO", fmt.Sprintf("%s (%s)", slo.Name, slo.Severity)})
+	}
+	if certificate := notification.Certificate; certificate != nil {
+		message.Fields = append(message.Fields, chatField{"Certificate", certificate.Subject})
+		message.Fields = append(message.Fields, chatField{"Valid until", certificate.NotAfter.Format("2006-01-02 15:04 MST")})
+	}
+	return message
+}
+
+// fallback returns the plain text of a message for notifications and clients without rich formatting
+func (m chatMessage) fallback() string {
+	if m.Text == "" {
+		return m.Title
+	}
+	return m.Title + ": " + m.Text
+}
+
+// dashboardLink returns the dashboard page of the incident, server or SLO of a notification
+func dashboardLink(notification models.Notification) string {
+	if config.DashboardURL == "" {
+		return ""
+	}
+	switch {
+	case notification.Incident != nil:
+		return fmt.Sprintf("%s/incidents/%d", config.DashboardURL, notification.Incident.ID)
+	case notification.Server != nil:
+		return fmt.Sprintf("%s/servers/%d", config.DashboardURL, notification.Server.ID)
+	case notification.SLO != nil:
+		return fmt.Sprintf("%s/slos/%d", config.DashboardURL, notification.SLO.ID)
+	}
+	return config.DashboardURL
+}
+
+// levelColor returns the hex color of a notification level
+func levelColor(level string) string {
+	switch level {
+	case models.LevelCritical:
+		return "#cf222e"
+	case models.LevelWarning:
+		return "#bf8700"
+	default:
+		return "#1a7f37"
+	}
+}
+
+// slackSettings are the settings of a Slack channel. An incoming webhook posts plain
+// messages, a bot token and channel post follow-ups of an incident in its thread.
+type slackSettings struct {
+	WebhookURL string `json:"webhookUrl"`
+	Token      string `json:"token"`
+	Channel    string `json:"channel"`
+	APIURL     string `json:"apiUrl"` // Defaults to the Slack Web API
+}
+
+// slackNotifier posts Block Kit messages to Slack
+type slackNotifier struct {
+	httpDelivery
+	settings slackSettings
+}
+
+// newSlackNotifier creates a Slack notifier
+func newSlackNotifier(config json.RawMessage) (Notifier, error) {
+	var settings slackSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case settings.Token != "":
+		if settings.Channel == "" {
+			return nil, errors.New("a channel is required with a bot token")
+		}
+		if settings.APIURL == "" {
+			settings.APIURL = defaultSlackAPIURL
+		}
+		if err := validateWebhookURL("apiUrl", settings.APIURL); err != nil {
+			return nil, err
+		}
+		settings.APIURL = strings.TrimSuffix(settings.APIURL, "/")
+	case settings.WebhookURL != "":
+		if err := validateWebhookURL("webhookUrl", settings.WebhookURL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("a webhookUrl or a bot token is required")
+	}
+	return &slackNotifier{settings: settings}, nil
+}
+
+// Send posts a notification to Slack
+func (n *slackNotifier) Send(ctx context.Context, notification models.Notification) error {
+	_, err := n.SendThreaded(ctx, notification, "")
+	return err
+}
+
+// SendThreaded posts a notification, as a reply in a thread when posting with a bot token.
+// The recovery is also broadcast to the channel.
+func (n *slackNotifier) SendThreaded(ctx context.Context, notification models.Notification, threadID string) (string, error) {
+	message := newChatMessage(notification)
+	payload := map[string]interface{}{
+		"text":   message.fallback(),
+		"blocks": slackBlocks(message, notification.Level),
+	}
+
+	if n.settings.Token == "" {
+		_, err := n.postJSON(ctx, n.settings.WebhookURL, nil, payload)
+		return "", err
+	}
+
+	payload["channel"] = n.settings.Channel
+	if threadID != "" {
+		payload["thread_ts"] = threadID
+		payload["reply_broadcast"] = notification.Event == models.NotifyIncidentResolved
+	}
+	body, err := n.postJSON(ctx, n.settings.APIURL+"/chat.postMessage", map[string]string{"Authorization": "Bearer " + n.settings.Token}, payload)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		OK    bool   `json:"ok"`
+		TS    string `json:"ts"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("invalid slack response: %v", err)
+	}
+	if !response.OK {
+		return "", fmt.Errorf("slack error: %s", response.Error)
+	}
+	return response.TS, nil
+}
+
+// slackBlocks renders a chat message as Slack Block Kit blocks
+func slackBlocks(message chatMessage, level string) []interface{} {
+	icon := map[string]string{
+		models.LevelCritical: ":red_circle:",
+		models.LevelWarning:  ":large_yellow_circle:",
+	}[level]
+	if icon == "" {
+		icon = ":large_green_circle:"
+	}
+
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": truncate(icon+" "+message.Title, 150), "emoji": true},
+		},
+	}
+	if message.Text != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": truncate(slackEscape(message.Text), 3000)},
+		})
+	}
+	if len(message.Fields) > 0 {
+		fields := []interface{}{}
+		for _, field := range message.Fields {
+			if len(fields) == 10 {
+				break
+			}
+			fields = append(fields, map[string]interface{}{
+				"type": "mrkdwn",
+				"text": truncate(fmt.Sprintf("*%s*\n%s", field.Name, slackEscape(field.Value)), 2000),
+			})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type": "context",
+		"elements": []interface{}{
+			map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("%s · %s", message.Footer, message.At.Format("2006-01-02 15:04:05 MST"))},
+		},
+	})
+	if message.Link != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{
+				map[string]interface{}{
+					"type": "button",
+					"text": map[string]interface{}{"type": "plain_text", "text": "Open dashboard"},
+					"url":  message.Link,
+				},
+			},
+		})
+	}
+	return blocks
+}
+
+// slackEscape escapes the control characters of Slack mrkdwn
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// teamsSettings are the settings of a Microsoft Teams channel
+type teamsSettings struct {
+	WebhookURL string `json:"webhookUrl"`
+}
+
+// teamsNotifier posts Adaptive Cards to a Microsoft Teams incoming webhook or workflow
+type teamsNotifier struct {
+	httpDelivery
+	settings teamsSettings
+}
+
+// newTeamsNotifier creates a Microsoft Teams notifier
+func newTeamsNotifier(config json.RawMessage) (Notifier, error) {
+	var settings teamsSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL("webhookUrl", settings.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &teamsNotifier{settings: settings}, nil
+}
+
+// Send posts a notification to Microsoft Teams
+func (n *teamsNotifier) Send(ctx context.Context, notification models.Notification) error {
+	message := newChatMessage(notification)
+
+	color := map[string]string{
+		models.LevelCritical: "Attention",
+		models.LevelWarning:  "Warning",
+	}[notification.Level]
+	if color == "" {
+		color = "Good"
+	}
+
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": message.Title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+	}
+	if message.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": message.Text, "wrap": true})
+	}
+	facts := []interface{}{}
+	for _, field := range message.Fields {
+		facts = append(facts, map[string]interface{}{"title": field.Name, "value": field.Value})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	body = append(body, map[string]interface{}{
+		"type": "TextBlock", "text": fmt.Sprintf("%s · %s", message.Footer, message.At.Format("2006-01-02 15:04:05 MST")),
+		"size": "Small", "isSubtle": true, "wrap": true,
+	})
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if message.Link != "" {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": "Open dashboard", "url": message.Link},
+		}
+	}
+
+	_, err := n.postJSON(ctx, n.settings.WebhookURL, nil, map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	})
+	return err
+}
+
+// discordSettings are the settings of a Discord channel
+type discordSettings struct {
+	WebhookURL string `json:"webhookUrl"`
+	Username   string `json:"username"` // Overrides the name of the webhook
+}
+
+// discordNotifier posts embeds to a Discord webhook
+type discordNotifier struct {
+	httpDelivery
+	settings discordSettings
+}
+
+// newDiscordNotifier creates a Discord notifier
+func newDiscordNotifier(config json.RawMessage) (Notifier, error) {
+	var settings discordSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL("webhookUrl", settings.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &discordNotifier{settings: settings}, nil
+}
+
+// Send posts a notification to Discord
+func (n *discordNotifier) Send(ctx context.Context, notification models.Notification) error {
+	message := newChatMessage(notification)
+
+	color, _ := strconv.ParseInt(strings.TrimPrefix(message.Color, "#"), 16, 32)
+	embed := map[string]interface{}{
+		"title":     truncate(message.Title, 256),
+		"color":     color,
+		"timestamp": message.At.Format(time.RFC3339),
+		"footer":    map[string]interface{}{"text": message.Footer},
+	}
+	if message.Text != "" {
+		embed["description"] = truncate(message.Text, 4096)
+	}
+	if message.Link != "" {
+		embed["url"] = message.Link
+	}
+	fields := []interface{}{}
+	for _, field := range message.Fields {
+		if len(fields) == 25 {
+			break
+		}
+		fields = append(fields, map[string]interface{}{"name": truncate(field.Name, 256), "value": truncate(field.Value, 1024), "inline": true})
+	}
+	if len(fields) > 0 {
+		embed["fields"] = fields
+	}
+
+	payload := map[string]interface{}{"embeds": []interface{}{embed}}
+	if n.settings.Username != "" {
+		payload["username"] = n.settings.Username
+	}
+	_, err := n.postJSON(ctx, n.settings.WebhookURL, nil, payload)
+	return err
+}
+
+// mattermostSettings are the settings of a Mattermost channel. An incoming webhook posts
+// plain messages, an access token and channel ID post follow-ups of an incident in its thread.
+type mattermostSettings struct {
+	WebhookURL string `json:"webhookUrl"`
+	ServerURL  string `json:"serverUrl"`
+	Token      string `json:"token"`
+	ChannelID  string `json:"channelId"`
+}
+
+// mattermostNotifier posts message attachments to Mattermost
+type mattermostNotifier struct {
+	httpDelivery
+	settings mattermostSettings
+}
+
+// newMattermostNotifier creates a Mattermost notifier
+func newMattermostNotifier(config json.RawMessage) (Notifier, error) {
+	var settings mattermostSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case settings.Token != "":
+		if settings.ChannelID == "" {
+			return nil, errors.New("a channelId is required with an access token")
+		}
+		if err := validateWebhookURL("serverUrl", settings.ServerURL); err != nil {
+			return nil, err
+		}
+		settings.ServerURL = strings.TrimSuffix(settings.ServerURL, "/")
+	case settings.WebhookURL != "":
+		if err := validateWebhookURL("webhookUrl", settings.WebhookURL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("a webhookUrl or an access token is required")
+	}
+	return &mattermostNotifier{settings: settings}, nil
+}
+
+// Send posts a notification to Mattermost
+func (n *mattermostNotifier) Send(ctx context.Context, notification models.Notification) error {
+	_, err := n.SendThreaded(ctx, notification, "")
+	return err
+}
+
+// SendThreaded posts a notification, as a reply in a thread when posting with an access token
+func (n *mattermostNotifier) SendThreaded(ctx context.Context, notification models.Notification, threadID string) (string, error) {
+	message := newChatMessage(notification)
+
+	fields := []interface{}{}
+	for _, field := range message.Fields {
+		fields = append(fields, map[string]interface{}{"short": true, "title": field.Name, "value": field.Value})
+	}
+	attachment := map[string]interface{}{
+		"fallback": message.fallback(),
+		"color":    message.Color,
+		"title":    message.Title,
+		"text":     message.Text,
+		"fields":   fields,
+		"footer":   fmt.Sprintf("%s · %s", message.Footer, message.At.Format("2006-01-02 15:04:05 MST")),
+	}
+	if message.Link != "" {
+		attachment["title_link"] = message.Link
+	}
+	attachments := []interface{}{attachment}
+
+	if n.settings.Token == "" {
+		_, err := n.postJSON(ctx, n.settings.WebhookURL, nil, map[string]interface{}{"attachments": attachments})
+		return "", err
+	}
+
+	body, err := n.postJSON(ctx, n.settings.ServerURL+"/api/v4/posts", map[string]string{"Authorization": "Bearer " + n.settings.Token}, map[string]interface{}{
+		"channel_id": n.settings.ChannelID,
+		"root_id":    threadID,
+		"props":      map[string]interface{}{"attachments": attachments},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var post struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &post); err != nil {
+		return "", fmt.Errorf("invalid mattermost response: %v", err)
+	}
+	return post.ID, nil
+}
+
+// validateWebhookURL checks that a setting is an absolute HTTP or HTTPS URL
+func validateWebhookURL(name, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", name)
+	}
+	return nil
+}
+
+// postJSON posts a JSON payload and returns the response body
+func (d *httpDelivery) postJSON(ctx context.Context, target string, headers map[string]string, payload interface{}) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headers["Content-Type"] = "application/json"
+	return d.post(ctx, target, headers, body)
+}
+
+// post sends a request body and returns the response body, failing on statuses other than 2xx
+func (d *httpDelivery) post(ctx context.Context, target string, headers map[string]string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	d.status = nil
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	d.status = &resp.StatusCode
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDrainSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if message := strings.TrimSpace(string(data)); message != "" {
+			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, truncate(message, 200))
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return data, nil
+}
+
+// truncate shortens text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// decodePayload decodes the JSON body of a request
+func decodePayload(t *testing.T, request httpRequest) map[string]interface{} {
+	t.Helper()
+
+	if contentType := request.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("content type = %q, want application/json", contentType)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(request.Body, &payload); err != nil {
+		t.Fatalf("decode payload: %v\n%s", err, request.Body)
+	}
+	return payload
+}
+
+// firstItem returns the first element of a JSON array in a payload
+func firstItem(t *testing.T, payload map[string]interface{}, key string) map[string]interface{} {
+	t.Helper()
+
+	items, ok := payload[key].([]interface{})
+	if !ok || len(items) == 0 {
+		t.Fatalf("payload has no %s: %v", key, payload)
+	}
+	item, ok := items[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s[0] is not an object: %v", key, items[0])
+	}
+	return item
+}
+
+func TestChatWebhookPayloads(t *testing.T) {
+	tests := []struct {
+		channelType string
+		check       func(t *testing.T, payload map[string]interface{}, notification models.Notification)
+	}{
+		{models.ChannelSlack, func(t *testing.T, payload map[string]interface{}, notification models.Notification) {
+			if text, _ := payload["text"].(string); !strings.Contains(text, notification.Title) {
+				t.Errorf("fallback text %q does not mention the title", text)
+			}
+			header := firstItem(t, payload, "blocks")
+			if header["type"] != "header" {
+				t.Errorf("first block = %v, want a header", header)
+			}
+		}},
+		{models.ChannelTeams, func(t *testing.T, payload map[string]interface{}, notification models.Notification) {
+			attachment := firstItem(t, payload, "attachments")
+			card, _ := attachment["content"].(map[string]interface{})
+			if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" || card["type"] != "AdaptiveCard" {
+				t.Errorf("attachment is not an adaptive card: %v", attachment)
+			}
+			if title := firstItem(t, card, "body"); title["text"] != notification.Title {
+				t.Errorf("card title = %v, want %q", title["text"], notification.Title)
+			}
+		}},
+		{models.ChannelDiscord, func(t *testing.T, payload map[string]interface{}, notification models.Notification) {
+			embed := firstItem(t, payload, "embeds")
+			if embed["title"] != notification.Title || embed["description"] != notification.Message {
+				t.Errorf("embed = %v", embed)
+			}
+			if field := firstItem(t, embed, "fields"); field["name"] != "Server" || field["value"] != notification.Server.Name {
+				t.Errorf("first field = %v, want the server", field)
+			}
+		}},
+		{models.ChannelMattermost, func(t *testing.T, payload map[string]interface{}, notification models.Notification) {
+			attachment := firstItem(t, payload, "attachments")
+			if attachment["title"] != notification.Title || attachment["text"] != notification.Message {
+				t.Errorf("attachment = %v", attachment)
+			}
+			if attachment["color"] != levelColor(notification.Level) {
+				t.Errorf("color = %v, want %s", attachment["color"], levelColor(notification.Level))
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.channelType, func(t *testing.T) {
+			standIn := newHTTPStandIn(t, httpResponse{200, "ok"})
+			config, _ := json.Marshal(map[string]string{"webhookUrl": standIn.URL + "/hook"})
+			notifier, err := newNotifier(test.channelType, config)
+			if err != nil {
+				t.Fatalf("newNotifier: %v", err)
+			}
+
+			notification := sampleNotification()
+			if err := notifier.Send(context.Background(), notification); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			request := standIn.next(t)
+			if request.Path != "/hook" {
+				t.Errorf("posted to %s, want /hook", request.Path)
+			}
+			test.check(t, decodePayload(t, request), notification)
+			if status := notifier.(ResponseNotifier).ResponseStatus(); status == nil || *status != 200 {
+				t.Errorf("response status = %v, want 200", status)
+			}
+		})
+	}
+}
+
+func TestChatThreadsIncidentFollowUps(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  func(url string) map[string]string
+		response  string
+		path      string
+		threadKey string
+		threadID  string
+	}{
+		{
+			name: "slack",
+			settings: func(url string) map[string]string {
+				return map[string]string{"token": "secret-token", "channel": "C123", "apiUrl": url}
+			},
+			response:  `{"ok":true,"ts":"1700000000.000100"}`,
+			path:      "/chat.postMessage",
+			threadKey: "thread_ts",
+			threadID:  "1700000000.000100",
+		},
+		{
+			name: "mattermost",
+			settings: func(url string) map[string]string {
+				return map[string]string{"token": "secret-token", "channelId": "C123", "serverUrl": url}
+			},
+			response:  `{"id":"post1"}`,
+			path:      "/api/v4/posts",
+			threadKey: "root_id",
+			threadID:  "post1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notifications, serverID := newTestNotificationService(t)
+			standIn := newHTTPStandIn(t, httpResponse{200, test.response})
+			createTestChannel(t, notifications, test.name, test.settings(standIn.URL), serverID)
+
+			// The opening message starts the thread, follow-ups of the incident reply to it
+			for _, event := range []string{models.NotifyIncidentOpened, models.NotifyIncidentReminder, models.NotifyIncidentResolved} {
+				notifications.Notify(incidentNotification(event, 7, "api is down"), serverID)
+				if err := notifications.DeliverDue(time.Now().UTC()); err != nil {
+					t.Fatalf("DeliverDue: %v", err)
+				}
+
+				request := standIn.next(t)
+				if request.Path != test.path {
+					t.Errorf("%s posted to %s, want %s", event, request.Path, test.path)
+				}
+				if auth := request.Header.Get("Authorization"); auth != "Bearer secret-token" {
+					t.Errorf("%s authorization = %q", event, auth)
+				}
+
+				payload := decodePayload(t, request)
+				thread, _ := payload[test.threadKey].(string)
+				if event == models.NotifyIncidentOpened && thread != "" {
+					t.Errorf("%s replied to thread %q", event, thread)
+				}
+				if event != models.NotifyIncidentOpened && thread != test.threadID {
+					t.Errorf("%s %s = %q, want %q", event, test.threadKey, thread, test.threadID)
+				}
+				if test.name == "slack" {
+					broadcast, _ := payload["reply_broadcast"].(bool)
+					if broadcast != (event == models.NotifyIncidentResolved) {
+						t.Errorf("%s reply_broadcast = %v", event, broadcast)
+					}
+				}
+			}
+
+			// Another incident starts its own thread
+			notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 8, "web is down"), serverID)
+			if err := notifications.DeliverDue(time.Now().UTC()); err != nil {
+				t.Fatalf("DeliverDue: %v", err)
+			}
+			if thread, _ := decodePayload(t, standIn.next(t))[test.threadKey].(string); thread != "" {
+				t.Errorf("new incident replied to thread %q", thread)
+			}
+		})
+	}
+}
+
+func TestSlackErrorFailsDelivery(t *testing.T) {
+	standIn := newHTTPStandIn(t, httpResponse{200, `{"ok":false,"error":"channel_not_found"}`})
+	config, _ := json.Marshal(map[string]string{"token": "secret-token", "channel": "C123", "apiUrl": standIn.URL})
+	notifier, err := newNotifier(models.ChannelSlack, config)
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	err = notifier.Send(context.Background(), sampleNotification())
+	if err == nil || err.Error() != "slack error: channel_not_found" {
+		t.Errorf("Send error = %v, want the slack error", err)
+	}
+	if channel := decodePayload(t, standIn.next(t))["channel"]; channel != "C123" {
+		t.Errorf("channel = %v, want C123", channel)
+	}
+}
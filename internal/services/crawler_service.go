@@ -0,0 +1,357 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"golang.org/x/net/html"
+)
+
+// maxCrawlConcurrency limits the number of links checked in parallel during a crawl
+const maxCrawlConcurrency = 5
+
+// maxCrawlPageSize limits how much of a page is read when extracting links
+const maxCrawlPageSize = 5 << 20
+
+// CrawlerService crawls sites and reports broken links
+type CrawlerService struct {
+	db         *sqlx.DB
+	transports *TransportManager
+}
+
+// NewCrawlerService creates a new crawler service instance
+func NewCrawlerService(db *sqlx.DB, transports *TransportManager) *CrawlerService {
+	return &CrawlerService{
+		db:         db,
+		transports: transports,
+	}
+}
+
+// crawlPage is a page queued for crawling
+type crawlPage struct {
+	url      string
+	referrer string
+	depth    int
+}
+
+// pageLink is a link found on a crawled page
+type pageLink struct {
+	url    *url.URL
+	follow bool
+}
+
+// linkResult is the outcome of checking a single link
+type linkResult struct {
+	statusCode *int
+	err        *string
+}
+
+// broken reports whether a link check failed
+func (r linkResult) broken() bool {
+	return r.err != nil || (r.statusCode != nil && *r.statusCode >= 400)
+}
+
+// Crawl follows same-origin links from the server URL and reports broken links
+func (s *CrawlerService) Crawl(server models.Server) *models.CrawlReport {
+	start := time.Now()
+	report := &models.CrawlReport{
+		ServerID:    server.ID,
+		StartedAt:   start,
+		BrokenLinks: []models.BrokenLink{},
+	}
+	defer func() {
+		report.Duration = int(time.Since(start).Milliseconds())
+	}()
+
+	root, err := url.Parse(server.URL)
+	if err != nil {
+		errorMsg := err.Error()
+		report.Error = &errorMsg
+		return report
+	}
+
+	client, err := s.transports.Client(server)
+	if err != nil {
+		errorMsg := err.Error()
+		report.Error = &errorMsg
+		return report
+	}
+
+	checked := map[string]linkResult{}
+	queued := map[string]bool{normalizeLink(root): true}
+	queue := []crawlPage{{url: normalizeLink(root)}}
+
+	for len(queue) > 0 && report.PagesCrawled < server.CrawlMaxPages {
+		page := queue[0]
+		queue = queue[1:]
+
+		result, final, links := s.fetchPage(client, page.url)
+		report.PagesCrawled++
+		checked[page.url] = result
+		if result.broken() {
+			if page.referrer == "" {
+				report.Error = describeLinkResult(result)
+			}
+			report.BrokenLinks = append(report.BrokenLinks, brokenLink(page.url, page.referrer, result))
+			continue
+		}
+		// The server URL may redirect, for example from http to https or to another host, so
+		// the origin of the crawl is the one the first page was finally served from
+		if page.referrer == "" && final != nil {
+			root = final
+			queued[normalizeLink(final)] = true
+		}
+
+		// Decide which links become pages and which are only checked
+		var toCheck []string
+		for _, link := range links {
+			normalized := normalizeLink(link.url)
+			if link.follow && page.depth < server.CrawlDepth && sameOrigin(root, link.url) {
+				if !queued[normalized] {
+					queued[normalized] = true
+					queue = append(queue, crawlPage{url: normalized, referrer: page.url, depth: page.depth + 1})
+				}
+				continue
+			}
+
+			if _, done := checked[normalized]; done || queued[normalized] {
+				continue
+			}
+			checked[normalized] = linkResult{}
+			toCheck = append(toCheck, normalized)
+		}
+
+		for link, result := range s.checkLinks(client, toCheck) {
+			checked[link] = result
+			if result.broken() {
+				report.BrokenLinks = append(report.BrokenLinks, brokenLink(link, page.url, result))
+			}
+		}
+	}
+
+	report.LinksChecked = len(checked)
+	report.BrokenCount = len(report.BrokenLinks)
+	return report
+}
+
+// fetchPage downloads a page and returns the URL it was served from after redirects, along
+// with the links it contains
+func (s *CrawlerService) fetchPage(client *http.Client, pageURL string) (linkResult, *url.URL, []pageLink) {
+	resp, err := client.Get(pageURL)
+	if err != nil {
+		errorMsg := err.Error()
+		return linkResult{err: &errorMsg}, nil, nil
+	}
+	defer resp.Body.Close()
+
+	result := linkResult{statusCode: intPtr(resp.StatusCode)}
+	if resp.StatusCode >= 400 || !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return result, resp.Request.URL, nil
+	}
+
+	return result, resp.Request.URL, extractLinks(resp.Request.URL, io.LimitReader(resp.Body, maxCrawlPageSize))
+}
+
+// checkLinks checks links in parallel and returns the result of each one
+func (s *CrawlerService) checkLinks(client *http.Client, links []string) map[string]linkResult {
+	results := make(map[string]linkResult, len(links))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxCrawlConcurrency)
+
+	for _, link := range links {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(link string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := checkLink(client, link)
+			mu.Lock()
+			results[link] = result
+			mu.Unlock()
+		}(link)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// checkLink checks a single link, falling back to GET when HEAD is not supported
+func checkLink(client *http.Client, link string) linkResult {
+	resp, err := client.Head(link)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(link)
+	}
+	if err != nil {
+		errorMsg := err.Error()
+		return linkResult{err: &errorMsg}
+	}
+	resp.Body.Close()
+	return linkResult{statusCode: intPtr(resp.StatusCode)}
+}
+
+// extractLinks returns the absolute http(s) links referenced by an HTML document.
+// Only anchors are followed, other references such as images and scripts are just checked.
+func extractLinks(base *url.URL, body io.Reader) []pageLink {
+	var links []pageLink
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			var attr string
+			switch token.Data {
+			case "a", "link":
+				attr = "href"
+			case "img", "script", "iframe":
+				attr = "src"
+			default:
+				continue
+			}
+
+			for _, a := range token.Attr {
+				if a.Key != attr {
+					continue
+				}
+				ref, err := base.Parse(strings.TrimSpace(a.Val))
+				if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") {
+					continue
+				}
+				links = append(links, pageLink{url: ref, follow: token.Data == "a"})
+			}
+		}
+	}
+}
+
+// sameOrigin reports whether a link shares the scheme and host of the crawl root
+func sameOrigin(root, link *url.URL) bool {
+	return strings.EqualFold(root.Scheme, link.Scheme) && strings.EqualFold(root.Host, link.Host)
+}
+
+// normalizeLink strips fragments so the same page is not crawled twice
+func normalizeLink(u *url.URL) string {
+	normalized := *u
+	normalized.Fragment = ""
+	return normalized.String()
+}
+
+// brokenLink builds a broken link record from a link check result
+func brokenLink(link, referrer string, result linkResult) models.BrokenLink {
+	return models.BrokenLink{
+		URL:        link,
+		Referrer:   referrer,
+		StatusCode: result.statusCode,
+		Error:      result.err,
+	}
+}
+
+// describeLinkResult returns a human readable description of a failed link check
+func describeLinkResult(result linkResult) *string {
+	if result.err != nil {
+		return result.err
+	}
+	description := fmt.Sprintf("unexpected status code %d", *result.statusCode)
+	return &description
+}
+
+// SaveReport stores a crawl report and its broken links
+func (s *CrawlerService) SaveReport(report *models.CrawlReport) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO crawl_reports (server_id, pages_crawled, links_checked, broken_count, duration, error, started_at)
+		VALUES (:server_id, :pages_crawled, :links_checked, :broken_count, :duration, :error, :started_at)
+	`, report)
+	if err != nil {
+		logger.Error("Failed to insert crawl report for server %d: %v", report.ServerID, err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	report.ID = int(id)
+
+	for i := range report.BrokenLinks {
+		report.BrokenLinks[i].ReportID = report.ID
+		_, err := tx.NamedExec(`
+			INSERT INTO crawl_broken_links (report_id, url, referrer, status_code, error)
+			VALUES (:report_id, :url, :referrer, :status_code, :error)
+		`, report.BrokenLinks[i])
+		if err != nil {
+			logger.Error("Failed to insert broken link for crawl report %d: %v", report.ID, err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetReports returns the most recent crawl reports for a server
+func (s *CrawlerService) GetReports(serverID int, limit int) ([]models.CrawlReport, error) {
+	var reports []models.CrawlReport
+	err := s.db.Select(&reports, `
+		SELECT * FROM crawl_reports
+		WHERE server_id = ?
+		ORDER BY started_at DESC
+		LIMIT ?
+	`, serverID, limit)
+	if err != nil {
+		logger.Error("Failed to get crawl reports for server %d: %v", serverID, err)
+		return nil, err
+	}
+
+	for i := range reports {
+		if err := s.loadBrokenLinks(&reports[i]); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+// GetReport returns a single crawl report of a server
+func (s *CrawlerService) GetReport(serverID, reportID int) (*models.CrawlReport, error) {
+	var report models.CrawlReport
+	err := s.db.Get(&report, "SELECT * FROM crawl_reports WHERE id = ? AND server_id = ?", reportID, serverID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get crawl report %d: %v", reportID, err)
+		return nil, err
+	}
+
+	if err := s.loadBrokenLinks(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// loadBrokenLinks attaches the broken links to a crawl report
+func (s *CrawlerService) loadBrokenLinks(report *models.CrawlReport) error {
+	report.BrokenLinks = []models.BrokenLink{}
+	err := s.db.Select(&report.BrokenLinks, "SELECT * FROM crawl_broken_links WHERE report_id = ? ORDER BY id", report.ID)
+	if err != nil {
+		logger.Error("Failed to get broken links for crawl report %d: %v", report.ID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+func TestCrawlFollowsTheOriginOfTheRedirectedStartPage(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/about">About</a> <a href="/missing">Missing</a>`)
+		case "/about":
+			fmt.Fprint(w, `<a href="/">Home</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(site.Close)
+	// The server URL points at another origin that redirects to the site
+	redirect := httptest.NewServer(http.RedirectHandler(site.URL+"/", http.StatusMovedPermanently))
+	t.Cleanup(redirect.Close)
+
+	crawler := NewCrawlerService(nil, NewTransportManager(nil))
+	report := crawler.Crawl(models.Server{URL: redirect.URL, Timeout: 5000, CrawlDepth: 2, CrawlMaxPages: 10})
+
+	if report.Error != nil {
+		t.Fatalf("crawl error: %s", *report.Error)
+	}
+	// The start page and /about are crawled, /missing is a crawled page that is not found
+	if report.PagesCrawled != 3 {
+		t.Errorf("%d pages crawled, want 3", report.PagesCrawled)
+	}
+	if report.BrokenCount != 1 || report.BrokenLinks[0].URL != site.URL+"/missing" {
+		t.Errorf("broken links = %+v, want %s/missing", report.BrokenLinks, site.URL)
+	}
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// defaultDigestWindow is how long email channels collect notifications into one digest by default
+const defaultDigestWindow = 30 * time.Second
+
+// Default email templates, they render a single notification as well as a digest
+const (
+	defaultEmailSubject = `{{if .Digest}}{{len .Notifications}} monitoring alerts: {{.Title}} and {{more .Notifications}} more{{else}}[{{upper .Level}}] {{.Title}}{{end}}`
+
+	defaultEmailText = `{{range .Notifications}}[{{upper .Level}}] {{.Title}}
+{{if .Message}}{{.Message}}
+{{end}}{{with .Server}}Server: {{.Name}} ({{.URL}}){{if .State}}, now {{.State}}{{end}}
+{{end}}{{with .Incident}}Incident #{{.ID}}: {{.Status}}, started {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}, {{if .ResolvedAt}}resolved after{{else}}open for{{end}} {{duration .Duration}}{{if .Failures}}, {{.Failures}} failed checks{{end}}
+{{end}}{{with .SLO}}SLO: {{.Name}} ({{.Severity}})
+{{end}}{{with .Certificate}}Certificate: {{.Subject}}, issued by {{.Issuer}}, valid until {{.NotAfter.Format "2006-01-02 15:04:05 MST"}}
+{{end}}At {{.At.Format "2006-01-02 15:04:05 MST"}}
+
+{{end}}`
+
+	defaultEmailHTML = `<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f6f8fa;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#24292f">
+{{range .Notifications}}
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:640px;margin:0 auto 16px;background:#fff;border:1px solid #d0d7de;border-left:4px solid {{levelColor .Level}};border-radius:6px">
+<tr><td style="padding:16px 20px">
+<div style="font-size:12px;font-weight:600;color:{{levelColor .Level}};text-transform:uppercase">{{.Level}} · {{.Event}}</div>
+<div style="font-size:18px;font-weight:600;margin:4px 0 8px">{{.Title}}</div>
+{{if .Message}}<div style="font-size:14px;margin-bottom:12px">{{.Message}}</div>{{end}}
+<table role="presentation" cellpadding="0" cellspacing="0" style="font-size:13px;color:#57606a">
+{{with .Server}}<tr><td style="padding:2px 12px 2px 0">Server</td><td><a href="{{.URL}}" style="color:#0969da">{{.Name}}</a>{{if .State}} ({{.State}}){{end}}</td></tr>{{end}}
+{{with .Incident}}<tr><td style="padding:2px 12px 2px 0">Incident</td><td>#{{.ID}} {{.Status}}</td></tr>
+<tr><td style="padding:2px 12px 2px 0">Started</td><td>{{.StartedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><td style="padding:2px 12px 2px 0">{{if .ResolvedAt}}Resolved after{{else}}Open for{{end}}</td><td>{{duration .Duration}}{{if .Failures}}, {{.Failures}} failed checks{{end}}</td></tr>{{end}}
+{{with .SLO}}<tr><td style="padding:2px 12px 2px 0">SLO</td><td>{{.Name}} ({{.Severity}})</td></tr>{{end}}
+{{with .Certificate}}<tr><td style="padding:2px 12px 2px 0">Certificate</td><td>{{.Subject}}, issued by {{.Issuer}}, valid until {{.NotAfter.Format "2006-01-02 15:04:05 MST"}}</td></tr>{{end}}
+<tr><td style="padding:2px 12px 2px 0">At</td><td>{{.At.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+</table>
+</td></tr>
+</table>
+{{end}}
+</body>
+</html>`
+)
+
+// emailTemplateFuncs are the functions available to email templates
+var emailTemplateFuncs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"duration": func(seconds float64) string {
+		return formatDuration(time.Duration(seconds * float64(time.Second)))
+	},
+	"more": func(notifications []models.Notification) int {
+		return len(notifications) - 1
+	},
+	"levelColor": levelColor,
+}
+
+// emailView is the data rendered by email templates. The fields of the first notification
+// are available directly, a digest ranges over all of them.
+type emailView struct {
+	models.Notification
+	Notifications []models.Notification
+	Digest        bool
+}
+
+// emailSettings are the settings of an email channel
+type emailSettings struct {
+	To              []string      `json:"to"`
+	SMTP            *SMTPSettings `json:"smtp"`            // Defaults to the SMTP server configured in the environment
+	SubjectTemplate string        `json:"subjectTemplate"` // Go text/template
+	TextTemplate    string        `json:"textTemplate"`    // Go text/template
+	HTMLTemplate    string        `json:"htmlTemplate"`    // Go html/template
+	DigestWindow    *string       `json:"digestWindow"`    // Duration such as "1m", "0s" sends every notification on its own
+}
+
+// emailNotifier emails notifications, combining those within its digest window
+type emailNotifier struct {
+	to      []string
+	mailer  *Mailer
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+	window  time.Duration
+}
+
+// newEmailNotifier creates an email notifier, parsing its templates and rendering them once
+// with a sample notification so that mistakes surface when the channel is saved
+func newEmailNotifier(config json.RawMessage) (Notifier, error) {
+	var settings emailSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	if len(settings.To) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	for _, address := range settings.To {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q", address)
+		}
+	}
+
+	smtpSettings, err := emailSMTPSettings(settings.SMTP)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := &emailNotifier{
+		to:     settings.To,
+		mailer: &Mailer{settings: smtpSettings},
+		window: defaultDigestWindow,
+	}
+	if settings.DigestWindow != nil {
+		window, err := time.ParseDuration(*settings.DigestWindow)
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("invalid digest window %q", *settings.DigestWindow)
+		}
+		notifier.window = window
+	}
+
+	if notifier.subject, err = parseTextTemplate("subject", settings.SubjectTemplate, defaultEmailSubject); err != nil {
+		return nil, err
+	}
+	if notifier.text, err = parseTextTemplate("text", settings.TextTemplate, defaultEmailText); err != nil {
+		return nil, err
+	}
+	if notifier.html, err = parseHTMLTemplate(settings.HTMLTemplate, defaultEmailHTML); err != nil {
+		return nil, err
+	}
+
+	sample := sampleNotification()
+	for _, notifications := range [][]models.Notification{{sample}, {sample, sample}} {
+		if _, _, _, err := notifier.render(notifications); err != nil {
+			return nil, err
+		}
+	}
+	return notifier, nil
+}
+
+// emailSMTPSettings completes the SMTP server of an email channel. A channel without its own
+// server uses the one configured in the environment, credentials are never shared with
+// another host.
+func emailSMTPSettings(settings *SMTPSettings) (SMTPSettings, error) {
+	if settings == nil || settings.Host == "" {
+		if config.SMTPHost == "" {
+			return SMTPSettings{}, ErrMailerNotConfigured
+		}
+		return DefaultSMTPSettings(), nil
+	}
+
+	smtpSettings := *settings
+	switch smtpSettings.TLS {
+	case "":
+		smtpSettings.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPTLS, SMTPPlain:
+	default:
+		return SMTPSettings{}, fmt.Errorf("invalid smtp tls mode %q, expected starttls, tls or none", smtpSettings.TLS)
+	}
+	if smtpSettings.Port == "" {
+		smtpSettings.Port = "25"
+		if smtpSettings.TLS == SMTPTLS {
+			smtpSettings.Port = "465"
+		}
+	}
+	if smtpSettings.From == "" {
+		smtpSettings.From = config.SMTPFrom
+	}
+	if _, err := mail.ParseAddress(smtpSettings.From); err != nil {
+		return SMTPSettings{}, fmt.Errorf("invalid sender %q", smtpSettings.From)
+	}
+	return smtpSettings, nil
+}
+
+// BatchWindow returns the digest window of the channel
+func (n *emailNotifier) BatchWindow() time.Duration {
+	return n.window
+}
+
+// Send emails a single notification
+func (n *emailNotifier) Send(ctx context.Context, notification models.Notification) error {
+	return n.SendBatch(ctx, []models.Notification{notification})
+}
+
+// SendBatch emails notifications as one message, a digest if there are several
+func (n *emailNotifier) SendBatch(ctx context.Context, notifications []models.Notification) error {
+	subject, text, html, err := n.render(notifications)
+	if err != nil {
+		return err
+	}
+	return n.mailer.SendAlternative(ctx, n.to, subject, text, html)
+}
+
+// render renders the subject, text and HTML body of an email
+func (n *emailNotifier) render(notifications []models.Notification) (string, string, string, error) {
+	view := emailView{
+		Notification:  notifications[0],
+		Notifications: notifications,
+		Digest:        len(notifications) > 1,
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := n.subject.Execute(&subject, view); err != nil {
+		return "", "", "", fmt.Errorf("invalid subject template: %v", err)
+	}
+	if err := n.text.Execute(&text, view); err != nil {
+		return "", "", "", fmt.Errorf("invalid text template: %v", err)
+	}
+	if err := n.html.Execute(&html, view); err != nil {
+		return "", "", "", fmt.Errorf("invalid html template: %v", err)
+	}
+
+	// Header values must stay on one line
+	oneLine := strings.Join(strings.Fields(subject.String()), " ")
+	return oneLine, text.String(), html.String(), nil
+}
+
+// parseTextTemplate parses a user template, or the default one if it is empty
+func parseTextTemplate(name, source, defaultSource string) (*texttemplate.Template, error) {
+	if source == "" {
+		source = defaultSource
+	}
+	tmpl, err := texttemplate.New(name).Funcs(emailTemplateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// parseHTMLTemplate parses a user HTML template, or the default one if it is empty
+func parseHTMLTemplate(source, defaultSource string) (*htmltemplate.Template, error) {
+	if source == "" {
+		source = defaultSource
+	}
+	tmpl, err := htmltemplate.New("html").Funcs(emailTemplateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid html template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// sampleNotification is a notification with every field set, used to check templates
+func sampleNotification() models.Notification {
+	now := time.Now().UTC()
+	failure := "connection refused"
+	return models.Notification{
+		ID:      "00000000000000000000000000000000",
+		Event:   models.NotifyIncidentOpened,
+		Level:   models.LevelCritical,
+		Title:   "example is down",
+		Message: "connection refused",
+		Server: &models.NotificationServer{
+			ID:           1,
+			Name:         "example",
+			URL:          "https://example.com",
+			State:        models.StateDown,
+			ResponseTime: intPtr(5000),
+			StatusCode:   intPtr(503),
+			Error:        &failure,
+			Timings:      &models.CheckTimings{DNS: 12, Connect: 25, TLS: 40, FirstByte: 4900, Total: 5000},
+		},
+		Incident: &models.NotificationIncident{
+			ID:         1,
+			Title:      "example is down",
+			Status:     models.IncidentResolved,
+			StartedAt:  now.Add(-time.Hour),
+			ResolvedAt: &now,
+			Failures:   3,
+			LastError:  "connection refused",
+			Duration:   3600,
+		},
+		SLO: &models.NotificationSLO{
+			ID:            1,
+			Name:          "example availability",
+			Severity:      models.SeverityPage,
+			LongWindow:    60,
+			ShortWindow:   5,
+			Threshold:     14.4,
+			LongBurnRate:  16.2,
+			ShortBurnRate: 21.5,
+		},
+		Certificate: &models.NotificationCertificate{
+			Subject:  "example.com",
+			Issuer:   "Example CA",
+			NotAfter: now.Add(7 * 24 * time.Hour),
+			DaysLeft: 7,
+		},
+		At: now,
+	}
+}
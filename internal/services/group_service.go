@@ -0,0 +1,317 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+var (
+	// ErrUnknownGroup is returned when referencing a group that does not exist
+	ErrUnknownGroup = errors.New("unknown group")
+
+	// ErrGroupCycle is returned when moving a group below itself
+	ErrGroupCycle = errors.New("group cycle")
+
+	// ErrGroupNotEmpty is returned when deleting a group that still has servers or subgroups
+	ErrGroupNotEmpty = errors.New("group is not empty")
+)
+
+// stateSeverity ranks server states from best to worst for group aggregation
+var stateSeverity = map[string]int{
+	models.StateUp:          1,
+	models.StateMaintenance: 2,
+	models.StateDegraded:    3,
+	models.StateUnreachable: 4,
+	models.StateDown:        5,
+}
+
+// GroupService manages the hierarchy of server groups
+type GroupService struct {
+	db      *sqlx.DB
+	servers *ServerService
+}
+
+// NewGroupService creates a new group service instance
+func NewGroupService(db *sqlx.DB, servers *ServerService) *GroupService {
+	return &GroupService{
+		db:      db,
+		servers: servers,
+	}
+}
+
+// CreateGroup creates a new group
+func (s *GroupService) CreateGroup(req models.CreateGroupRequest) (*models.Group, error) {
+	group := &models.Group{
+		Name:        req.Name,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := s.servers.validateGroup(group.ParentID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.NamedExec(`
+		INSERT INTO server_groups (name, description, parent_id, created_at, updated_at)
+		VALUES (:name, :description, :parent_id, :created_at, :updated_at)
+	`, group)
+	if err != nil {
+		logger.Error("Failed to create group: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	group.ID = int(id)
+	return group, nil
+}
+
+// GetGroups returns all groups with their aggregate state
+func (s *GroupService) GetGroups() ([]models.Group, error) {
+	groups := []models.Group{}
+	err := s.db.Select(&groups, "SELECT * FROM server_groups ORDER BY name")
+	if err != nil {
+		logger.Error("Failed to get groups: %v", err)
+		return nil, err
+	}
+
+	if err := s.aggregate(groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetGroupByID returns a group with its aggregate state
+func (s *GroupService) GetGroupByID(id int) (*models.Group, error) {
+	var group models.Group
+	err := s.db.Get(&group, "SELECT * FROM server_groups WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get group %d: %v", id, err)
+		return nil, err
+	}
+
+	groups := []models.Group{group}
+	if err := s.aggregate(groups); err != nil {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+// UpdateGroup updates a group
+func (s *GroupService) UpdateGroup(id int, req models.UpdateGroupRequest) (*models.Group, error) {
+	group, err := s.GetGroupByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, nil
+	}
+
+	if req.Name != nil {
+		group.Name = *req.Name
+	}
+	if req.Description != nil {
+		group.Description = *req.Description
+	}
+	// A parent of 0 moves the group to the top level
+	if req.ParentID != nil {
+		group.ParentID = optionalID(*req.ParentID)
+		if err := s.servers.validateGroup(group.ParentID); err != nil {
+			return nil, err
+		}
+		if group.ParentID != nil {
+			descendants, err := groupWithDescendants(s.db, id)
+			if err != nil {
+				return nil, err
+			}
+			for _, descendant := range descendants {
+				if descendant == *group.ParentID {
+					return nil, fmt.Errorf("%w: group %d cannot be moved below itself", ErrGroupCycle, id)
+				}
+			}
+		}
+	}
+	group.UpdatedAt = time.Now().UTC()
+
+	_, err = s.db.NamedExec(`
+		UPDATE server_groups
+		SET name = :name, description = :description, parent_id = :parent_id, updated_at = :updated_at
+		WHERE id = :id
+	`, group)
+	if err != nil {
+		logger.Error("Failed to update group %d: %v", id, err)
+		return nil, err
+	}
+
+	return s.GetGroupByID(id)
+}
+
+// DeleteGroup deletes a group without servers or subgroups
+func (s *GroupService) DeleteGroup(id int) error {
+	var members int
+	err := s.db.Get(&members, `
+		SELECT (SELECT COUNT(*) FROM servers WHERE group_id = ?) + (SELECT COUNT(*) FROM server_groups WHERE parent_id = ?)
+	`, id, id)
+	if err != nil {
+		logger.Error("Failed to count members of group %d: %v", id, err)
+		return err
+	}
+	if members > 0 {
+		return ErrGroupNotEmpty
+	}
+
+	_, err = s.db.Exec("DELETE FROM slo_alerts WHERE slo_id IN (SELECT id FROM slos WHERE group_id = ?)", id)
+	if err != nil {
+		logger.Error("Failed to delete SLO alerts of group %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM slos WHERE group_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete SLOs of group %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM report_recipients WHERE schedule_id IN (SELECT id FROM report_schedules WHERE group_id = ?)", id)
+	if err != nil {
+		logger.Error("Failed to delete report recipients of group %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE reports SET schedule_id = NULL WHERE schedule_id IN (SELECT id FROM report_schedules WHERE group_id = ?)", id)
+	if err != nil {
+		logger.Error("Failed to detach reports of group %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM report_schedules WHERE group_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete report schedules of group %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM server_groups WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete group %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// aggregate fills in the server count and worst latest state of each group, including subgroups
+func (s *GroupService) aggregate(groups []models.Group) error {
+	members := []struct {
+		ID      int  `db:"id"`
+		GroupID *int `db:"group_id"`
+	}{}
+	err := s.db.Select(&members, "SELECT id, group_id FROM servers WHERE group_id IS NOT NULL")
+	if err != nil {
+		logger.Error("Failed to get group members: %v", err)
+		return err
+	}
+
+	statuses, err := s.servers.GetLatestStatuses()
+	if err != nil {
+		return err
+	}
+
+	for i := range groups {
+		subtree, err := groupWithDescendants(s.db, groups[i].ID)
+		if err != nil {
+			return err
+		}
+		inSubtree := make(map[int]bool)
+		for _, id := range subtree {
+			inSubtree[id] = true
+		}
+
+		groups[i].ServerCount = 0
+		groups[i].State = ""
+		for _, member := range members {
+			if !inSubtree[*member.GroupID] {
+				continue
+			}
+			groups[i].ServerCount++
+			if status, exists := statuses[member.ID]; exists {
+				groups[i].State = worstState(groups[i].State, status.State)
+			}
+		}
+	}
+	return nil
+}
+
+// worstState returns the more severe of two server states
+func worstState(a, b string) string {
+	if stateSeverity[b] > stateSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// groupAncestors returns the ID of a group followed by the IDs of the groups it is nested in, innermost first
+func groupAncestors(db *sqlx.DB, id int) ([]int, error) {
+	edges := []struct {
+		ID       int  `db:"id"`
+		ParentID *int `db:"parent_id"`
+	}{}
+	err := db.Select(&edges, "SELECT id, parent_id FROM server_groups WHERE parent_id IS NOT NULL")
+	if err != nil {
+		logger.Error("Failed to get group hierarchy: %v", err)
+		return nil, err
+	}
+
+	parents := make(map[int]int)
+	for _, edge := range edges {
+		parents[edge.ID] = *edge.ParentID
+	}
+
+	ids := []int{id}
+	visited := map[int]bool{id: true}
+	for parent, exists := parents[id]; exists && !visited[parent]; parent, exists = parents[parent] {
+		visited[parent] = true
+		ids = append(ids, parent)
+	}
+	return ids, nil
+}
+
+// groupWithDescendants returns the ID of a group followed by the IDs of all groups nested below it
+func groupWithDescendants(db *sqlx.DB, id int) ([]int, error) {
+	edges := []struct {
+		ID       int  `db:"id"`
+		ParentID *int `db:"parent_id"`
+	}{}
+	err := db.Select(&edges, "SELECT id, parent_id FROM server_groups WHERE parent_id IS NOT NULL")
+	if err != nil {
+		logger.Error("Failed to get group hierarchy: %v", err)
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	for _, edge := range edges {
+		children[*edge.ParentID] = append(children[*edge.ParentID], edge.ID)
+	}
+
+	ids := []int{id}
+	visited := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !visited[child] {
+				visited[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
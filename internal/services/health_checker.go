@@ -2,31 +2,65 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/waltertaya/server_check_bd/internal/config"
 	"github.com/waltertaya/server_check_bd/internal/logger"
 	"github.com/waltertaya/server_check_bd/internal/models"
 )
 
+// maxDrainSize limits how much of a response body is read before a connection is reused
+const maxDrainSize = 1 << 20
+
+// ErrCheckInProgress is returned when a server is checked while a check of it is still running
+var ErrCheckInProgress = errors.New("check already in progress")
+
 // HealthChecker represents a service that checks server health
 type HealthChecker struct {
-	serverService *ServerService
-	clients       map[int]chan models.ServerStatus
-	mu            sync.RWMutex
-	ctx           context.Context
-	cancel        context.CancelFunc
+	serverService   *ServerService
+	securityService *SecurityService
+	crawlerService  *CrawlerService
+	maintenance     *MaintenanceService
+	incidents       *IncidentService
+	notifications   *NotificationService
+	transports      *TransportManager
+	clients         map[int]chan models.ServerStatus
+	inFlight        map[int]bool
+	lastRuns        map[int]time.Time
+	lastAudits      map[int]time.Time
+	notified        map[int]string // Last down, up or degraded state of each server, for state change notifications
+	mu              sync.RWMutex
+	ctx             context.Context
+	cancel          context.CancelFunc
 }
 
 // NewHealthChecker creates a new health checker instance
-func NewHealthChecker(serverService *ServerService) *HealthChecker {
+func NewHealthChecker(serverService *ServerService, securityService *SecurityService, crawlerService *CrawlerService, maintenance *MaintenanceService, incidents *IncidentService, notifications *NotificationService, transports *TransportManager) *HealthChecker {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &HealthChecker{
-		serverService: serverService,
-		clients:       make(map[int]chan models.ServerStatus),
-		ctx:           ctx,
-		cancel:        cancel,
+		serverService:   serverService,
+		securityService: securityService,
+		crawlerService:  crawlerService,
+		maintenance:     maintenance,
+		incidents:       incidents,
+		notifications:   notifications,
+		transports:      transports,
+		clients:         make(map[int]chan models.ServerStatus),
+		inFlight:        make(map[int]bool),
+		lastRuns:        make(map[int]time.Time),
+		lastAudits:      make(map[int]time.Time),
+		notified:        make(map[int]string),
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 }
 
@@ -40,6 +74,18 @@ func (hc *HealthChecker) Start() {
 func (hc *HealthChecker) Stop() {
 	logger.Info("Stopping health checker")
 	hc.cancel()
+	hc.transports.Close()
+}
+
+// RemoveServer forgets the scheduling state of a deleted server and closes its idle connections
+func (hc *HealthChecker) RemoveServer(serverID int) {
+	hc.mu.Lock()
+	delete(hc.lastRuns, serverID)
+	delete(hc.lastAudits, serverID)
+	delete(hc.notified, serverID)
+	hc.mu.Unlock()
+
+	hc.transports.Release(serverID)
 }
 
 // Subscribe adds a new client to receive status updates
@@ -79,43 +125,475 @@ func (hc *HealthChecker) checkLoop() {
 				continue
 			}
 
+			// Maintenance windows are resolved once per tick rather than once per check
+			var active map[int]bool
 			for _, server := range servers {
-				go hc.checkServer(server)
+				if hc.checkDue(server) {
+					if active == nil {
+						active = hc.activeMaintenance()
+					}
+					go hc.checkServer(server, active[server.ID])
+				}
+				if hc.auditDue(server) {
+					go hc.auditServer(server)
+				}
 			}
 		}
 	}
 }
 
-// checkServer checks the health of a single server
-func (hc *HealthChecker) checkServer(server models.Server) {
-	start := time.Now()
-	client := &http.Client{
-		Timeout: time.Duration(server.Timeout) * time.Millisecond,
+// CheckNow runs a check of a server immediately, records it and notifies subscribers. It
+// returns ErrCheckInProgress if the server is already being checked.
+func (hc *HealthChecker) CheckNow(server models.Server) ([]models.ServerStatus, error) {
+	if !hc.claimCheck(server.ID) {
+		return nil, ErrCheckInProgress
 	}
+	defer hc.finishCheck(server.ID)
 
-	req, err := http.NewRequest(server.Method, server.URL, nil)
+	inMaintenance, err := hc.maintenance.InMaintenance(server.ID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to check maintenance windows for server %d: %v", server.ID, err)
+	}
+	return hc.check(server, inMaintenance), nil
+}
+
+// TestServer runs exactly the check the scheduler would run without recording anything
+func (hc *HealthChecker) TestServer(server models.Server) []models.ServerStatus {
+	return hc.runFamilies(server, false)
+}
+
+// checkServer runs a scheduled check of a single server
+func (hc *HealthChecker) checkServer(server models.Server, inMaintenance bool) {
+	defer hc.finishCheck(server.ID)
+
+	hc.check(server, inMaintenance)
+}
+
+// activeMaintenance returns the IDs of servers currently in a maintenance window. On failure
+// no server is considered in maintenance.
+func (hc *HealthChecker) activeMaintenance() map[int]bool {
+	active, err := hc.maintenance.ActiveServerIDs(time.Now().UTC())
 	if err != nil {
-		hc.handleError(server, err)
+		logger.Error("Failed to check maintenance windows: %v", err)
+		return map[int]bool{}
+	}
+	return active
+}
+
+// check checks a server and records the results. Dual-stack servers are checked over
+// IPv4 and IPv6 in parallel and each result is recorded separately in the history, while
+// the server keeps the worst of them as its latest state. Checks during a
+// maintenance window still run but are recorded with the MAINTENANCE state, and failures
+// caused by a down parent are recorded as UNREACHABLE. The results then open, extend or
+// resolve the incident of the server and notify its channels of state changes and an
+// expiring certificate. Checks of a server never overlap, so transports left over from
+// address families it is no longer checked over can be released first.
+func (hc *HealthChecker) check(server models.Server, inMaintenance bool) []models.ServerStatus {
+	hc.transports.Retain(server.ID, checkFamilies(server)...)
+
+	statuses := hc.runFamilies(server, true)
+	for i := range statuses {
+		hc.markUnreachable(server, &statuses[i])
+		if inMaintenance {
+			statuses[i].State = models.StateMaintenance
+		}
+		hc.recordStatus(server, statuses[i])
+	}
+	hc.finishStatus(server, statuses)
+	return statuses
+}
+
+// runFamilies runs the check of a server once per address family it is checked over, in
+// parallel for dual-stack servers. Crawl reports are only stored when persist is set.
+func (hc *HealthChecker) runFamilies(server models.Server, persist bool) []models.ServerStatus {
+	families := checkFamilies(server)
+	if len(families) == 1 {
+		return []models.ServerStatus{hc.runCheck(server, persist)}
+	}
+
+	statuses := make([]models.ServerStatus, len(families))
+	var wg sync.WaitGroup
+	for i, family := range families {
+		wg.Add(1)
+		go func(i int, target models.Server) {
+			defer wg.Done()
+			statuses[i] = hc.runCheck(target, persist)
+		}(i, withFamily(server, family))
+	}
+	wg.Wait()
+	return statuses
+}
+
+// checkFamilies returns the address families a server is checked over
+func checkFamilies(server models.Server) []string {
+	if server.AddressFamily == models.FamilyBoth {
+		return []string{models.FamilyIPv4, models.FamilyIPv6}
+	}
+	return []string{server.AddressFamily}
+}
+
+// finishStatus stores the combined result of a check on the server, then updates its incident
+// and notifies its channels
+func (hc *HealthChecker) finishStatus(server models.Server, statuses []models.ServerStatus) {
+	status := combineStatuses(statuses)
+	if err := hc.serverService.SetLatestStatus(server.ID, status); err != nil {
+		logger.Error("Failed to update latest status of server %d: %v", server.ID, err)
+	}
+	hc.observeIncident(server, statuses)
+	hc.notifyStateChange(server, status)
+	hc.notifyCertificate(server, statuses)
+}
+
+// observeIncident updates the incident of a server from the results of a check
+func (hc *HealthChecker) observeIncident(server models.Server, statuses []models.ServerStatus) {
+	if err := hc.incidents.Observe(server, statuses); err != nil {
+		logger.Error("Failed to update incident of server %d: %v", server.ID, err)
+	}
+}
+
+// notifyStateChange notifies the channels of a server when a check takes it down or brings it
+// back up or degraded. Changes are tracked against the last state the server was notified in
+// rather than its stored state, so maintenance and unreachable checks in between neither send
+// a recovery nor repeat a down notification. The state stored on the server only seeds this
+// after a restart. The combined status of a check is the worst of its address families, so a
+// dual-stack server is down while either family is.
+func (hc *HealthChecker) notifyStateChange(server models.Server, status models.ServerStatus) {
+	hc.mu.Lock()
+	previous, seen := hc.notified[server.ID]
+	if !seen {
+		previous = server.LastState
+	}
+	switch status.State {
+	case models.StateDown, models.StateUp, models.StateDegraded:
+		hc.notified[server.ID] = status.State
+	}
+	hc.mu.Unlock()
+
+	notification := models.Notification{
+		Server: &models.NotificationServer{
+			ID:           server.ID,
+			Name:         server.Name,
+			URL:          server.URL,
+			State:        status.State,
+			ResponseTime: status.ResponseTime,
+			StatusCode:   status.StatusCode,
+			Error:        status.Error,
+			Timings:      status.Timings,
+		},
+		At: status.LastChecked.UTC(),
+	}
+	switch {
+	case status.State == models.StateDown && previous != models.StateDown:
+		notification.Event = models.NotifyMonitorDown
+		notification.Level = models.LevelCritical
+		notification.Title = fmt.Sprintf("%s is down", server.Name)
+		if status.Error != nil {
+			notification.Message = *status.Error
+		} else if status.StatusCode != nil {
+			notification.Message = fmt.Sprintf("Responded with status %d, expected %d", *status.StatusCode, server.ExpectedStatus)
+		}
+	case (status.State == models.StateUp || status.State == models.StateDegraded) && previous == models.StateDown:
+		notification.Event = models.NotifyMonitorUp
+		notification.Level = models.LevelInfo
+		notification.Title = fmt.Sprintf("%s is up", server.Name)
+		if status.StatusCode != nil && status.ResponseTime != nil {
+			notification.Message = fmt.Sprintf("Responded with status %d in %d ms", *status.StatusCode, *status.ResponseTime)
+		}
+	default:
 		return
 	}
+	hc.notifications.Notify(notification, server.ID)
+}
 
-	resp, err := client.Do(req)
+// notifyCertificate notifies the channels of a server once its TLS certificate is within
+// CertificateExpiryWarning of expiring. Each certificate is notified once, when a check
+// first finds it within the warning period.
+func (hc *HealthChecker) notifyCertificate(server models.Server, statuses []models.ServerStatus) {
+	if config.CertificateExpiryWarning <= 0 {
+		return
+	}
+
+	var certificate *models.PeerCertificate
+	for _, status := range statuses {
+		if status.Certificate != nil {
+			certificate = status.Certificate
+			break
+		}
+	}
+	if certificate == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	remaining := certificate.NotAfter.Sub(now)
+	if remaining > config.CertificateExpiryWarning {
+		return
+	}
+	seen := server.CertificateExpiresAt != nil && server.CertificateExpiresAt.Equal(certificate.NotAfter)
+	if seen && server.LastCheckedAt != nil && certificate.NotAfter.Sub(*server.LastCheckedAt) <= config.CertificateExpiryWarning {
+		return
+	}
+
+	days := int(remaining.Hours() / 24)
+	notification := models.Notification{
+		Event:   models.NotifyCertificateExpiring,
+		Level:   models.LevelWarning,
+		Title:   fmt.Sprintf("Certificate of %s expires in %d days", server.Name, days),
+		Message: fmt.Sprintf("The certificate for %s issued by %s is valid until %s", certificate.Subject, certificate.Issuer, certificate.NotAfter.UTC().Format("2006-01-02 15:04:05 MST")),
+		Server: &models.NotificationServer{
+			ID:    server.ID,
+			Name:  server.Name,
+			URL:   server.URL,
+			State: server.LastState,
+		},
+		Certificate: &models.NotificationCertificate{
+			Subject:  certificate.Subject,
+			Issuer:   certificate.Issuer,
+			NotAfter: certificate.NotAfter.UTC(),
+			DaysLeft: days,
+		},
+		At: now.UTC(),
+	}
+	if remaining <= 0 {
+		notification.Level = models.LevelCritical
+		notification.Title = fmt.Sprintf("Certificate of %s has expired", server.Name)
+	}
+	hc.notifications.Notify(notification, server.ID)
+}
+
+// runCheck performs the check appropriate for the server type. Side products such as
+// crawl reports are only stored when persist is set.
+func (hc *HealthChecker) runCheck(server models.Server, persist bool) models.ServerStatus {
+	var status models.ServerStatus
+	switch server.Type {
+	case models.TypeCrawler:
+		status = hc.crawlServer(server, persist)
+	default:
+		status = hc.probeServer(server)
+	}
+
+	status.AddressFamily = server.AddressFamily
+	return status
+}
+
+// markUnreachable marks a failed check as UNREACHABLE when a parent of the server is down,
+// recording the failing ancestor as the root cause
+func (hc *HealthChecker) markUnreachable(server models.Server, status *models.ServerStatus) {
+	if status.State != models.StateDown || len(server.ParentIDs) == 0 {
+		return
+	}
+
+	root, err := hc.rootCause(server.ParentIDs)
 	if err != nil {
-		hc.handleError(server, err)
+		logger.Error("Failed to resolve dependencies of server %d: %v", server.ID, err)
 		return
 	}
+	if root == nil {
+		return
+	}
+
+	status.State = models.StateUnreachable
+	status.RootCauseID = &root.ID
+	message := fmt.Sprintf("Unreachable because dependency %s is down", root.Name)
+	if status.Error != nil {
+		message += ": " + *status.Error
+	}
+	status.Error = &message
+}
+
+// rootCause returns the first parent that is down, or the root cause of a parent that is
+// itself unreachable, based on their latest recorded status
+func (hc *HealthChecker) rootCause(parentIDs []int) (*models.Server, error) {
+	for _, parentID := range parentIDs {
+		status, err := hc.serverService.GetLatestStatus(parentID)
+		if err != nil {
+			return nil, err
+		}
+		if status == nil {
+			continue
+		}
+
+		rootID := parentID
+		switch status.State {
+		case models.StateDown:
+		case models.StateUnreachable:
+			if status.RootCauseID == nil {
+				continue
+			}
+			rootID = *status.RootCauseID
+		default:
+			continue
+		}
+		return hc.serverService.GetServerByID(rootID)
+	}
+	return nil, nil
+}
+
+// withFamily returns a copy of a server restricted to a single address family
+func withFamily(server models.Server, family string) models.Server {
+	server.AddressFamily = family
+	return server
+}
+
+// probeServer performs a single HTTP request against a server
+func (hc *HealthChecker) probeServer(server models.Server) models.ServerStatus {
+	client, err := hc.transports.Client(server)
+	if err != nil {
+		return errorStatus(err)
+	}
+
+	req, err := http.NewRequest(server.Method, server.URL, nil)
+	if err != nil {
+		return errorStatus(err)
+	}
+
+	trace := &checkTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	trace.mark(&trace.start)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		status := errorStatus(err)
+		status.Timings = trace.timings()
+		return status
+	}
 	defer resp.Body.Close()
+	timings := trace.timings()
+
+	// Drain the body so keep-alive connections can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainSize))
+
+	statusAssertion := models.AssertionResult{
+		Name:     "status code",
+		Expected: strconv.Itoa(server.ExpectedStatus),
+		Actual:   strconv.Itoa(resp.StatusCode),
+		Passed:   resp.StatusCode == server.ExpectedStatus,
+	}
 
-	duration := time.Since(start)
 	status := models.ServerStatus{
-		IsUp:         resp.StatusCode == server.ExpectedStatus,
+		IsUp:         statusAssertion.Passed,
 		StatusCode:   &resp.StatusCode,
-		ResponseTime: intPtr(int(duration.Milliseconds())),
-		LastChecked:  time.Now(),
+		ResponseTime: intPtr(timings.Total),
+		LastChecked:  time.Now().UTC(),
+		State:        models.StateUp,
+		Timings:      timings,
+		Assertions:   []models.AssertionResult{statusAssertion},
+	}
+	if !status.IsUp {
+		status.State = models.StateDown
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		leaf := resp.TLS.PeerCertificates[0]
+		status.Certificate = &models.PeerCertificate{
+			Subject:  leaf.Subject.CommonName,
+			Issuer:   leaf.Issuer.CommonName,
+			NotAfter: leaf.NotAfter,
+		}
+		// Certificates may name their hosts only in the subject alternative names
+		if status.Certificate.Subject == "" && len(leaf.DNSNames) > 0 {
+			status.Certificate.Subject = leaf.DNSNames[0]
+		}
+		if status.Certificate.Issuer == "" {
+			status.Certificate.Issuer = leaf.Issuer.String()
+		}
 	}
+	return status
+}
+
+// checkTrace collects the timestamps of the phases of an HTTP check. The transport may call
+// the hooks from other goroutines, such as when racing connection attempts, so fields are
+// guarded by mu.
+type checkTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// clientTrace returns the hooks that fill in the trace
+func (t *checkTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
 
-	// Update server status
-	err = hc.serverService.UpdateServerStatus(server.ID, status)
+// mark records the current time in a field of the trace
+func (t *checkTrace) mark(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	*field = time.Now()
+}
+
+// timings converts the collected timestamps into phase durations
+func (t *checkTrace) timings() *models.CheckTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	phase := func(from, to time.Time) int {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return int(to.Sub(from).Milliseconds())
+	}
+
+	return &models.CheckTimings{
+		DNS:       phase(t.dnsStart, t.dnsDone),
+		Connect:   phase(t.connectStart, t.connectDone),
+		TLS:       phase(t.tlsStart, t.tlsDone),
+		FirstByte: phase(t.start, t.firstByte),
+		Total:     phase(t.start, time.Now()),
+		Reused:    t.reused,
+	}
+}
+
+// crawlServer runs a crawl and derives the server status from its report
+func (hc *HealthChecker) crawlServer(server models.Server, persist bool) models.ServerStatus {
+	report := hc.crawlerService.Crawl(server)
+	if persist {
+		if err := hc.crawlerService.SaveReport(report); err != nil {
+			logger.Error("Failed to save crawl report: %v", err)
+		}
+	}
+
+	status := models.ServerStatus{
+		IsUp:         report.Error == nil,
+		ResponseTime: intPtr(report.Duration),
+		Error:        report.Error,
+		LastChecked:  time.Now().UTC(),
+		State:        models.StateUp,
+	}
+	switch {
+	case report.Error != nil:
+		status.State = models.StateDown
+	case report.BrokenCount > 0:
+		status.State = models.StateDegraded
+		summary := fmt.Sprintf("%d broken links found across %d pages", report.BrokenCount, report.PagesCrawled)
+		status.Error = &summary
+	}
+	return status
+}
+
+// recordStatus stores the check result of one address family in the history and notifies subscribers
+func (hc *HealthChecker) recordStatus(server models.Server, status models.ServerStatus) {
+	err := hc.serverService.UpdateServerStatus(server.ID, status)
 	if err != nil {
 		logger.Error("Failed to update server status: %v", err)
 		return
@@ -133,31 +611,83 @@ func (hc *HealthChecker) checkServer(server models.Server) {
 	hc.mu.RUnlock()
 }
 
-// handleError handles errors during server health checks
-func (hc *HealthChecker) handleError(server models.Server, err error) {
+// checkDue reports whether a server should be checked now and marks it in flight if so.
+// Paused servers are skipped, and since crawls are expensive, crawler monitors only run
+// once per configured interval.
+func (hc *HealthChecker) checkDue(server models.Server) bool {
+	if !server.Enabled {
+		return false
+	}
+
+	hc.mu.Lock()
+	due := server.Type != models.TypeCrawler || time.Since(hc.lastRuns[server.ID]) >= time.Duration(server.Interval)*time.Millisecond
+	hc.mu.Unlock()
+
+	return due && hc.claimCheck(server.ID)
+}
+
+// claimCheck marks a server in flight unless it already is, so scheduled and manual checks
+// of the same server never overlap
+func (hc *HealthChecker) claimCheck(serverID int) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.inFlight[serverID] {
+		return false
+	}
+	hc.inFlight[serverID] = true
+	hc.lastRuns[serverID] = time.Now()
+	return true
+}
+
+// finishCheck clears the in-flight marker of a server
+func (hc *HealthChecker) finishCheck(serverID int) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	delete(hc.inFlight, serverID)
+}
+
+// errorStatus builds a DOWN status from a check error
+func errorStatus(err error) models.ServerStatus {
 	errorMsg := err.Error()
-	status := models.ServerStatus{
+	return models.ServerStatus{
 		IsUp:        false,
 		Error:       &errorMsg,
-		LastChecked: time.Now(),
+		LastChecked: time.Now().UTC(),
+		State:       models.StateDown,
 	}
+}
 
-	err = hc.serverService.UpdateServerStatus(server.ID, status)
-	if err != nil {
-		logger.Error("Failed to update server status: %v", err)
-		return
+// auditDue reports whether a security audit should run for a server and reserves the slot if so.
+// Servers are audited once per audit interval while they are not paused.
+func (hc *HealthChecker) auditDue(server models.Server) bool {
+	if !server.Enabled || !server.SecurityAudit || !strings.HasPrefix(strings.ToLower(server.URL), "https://") {
+		return false
 	}
 
-	// Notify subscribers
-	hc.mu.RLock()
-	if ch, exists := hc.clients[server.ID]; exists {
-		select {
-		case ch <- status:
-		default:
-			// Channel is full, skip this update
-		}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	interval := time.Duration(server.AuditInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = config.SecurityAuditInterval
 	}
-	hc.mu.RUnlock()
+	if time.Since(hc.lastAudits[server.ID]) < interval {
+		return false
+	}
+	hc.lastAudits[server.ID] = time.Now()
+	return true
+}
+
+// auditServer grades the security headers and TLS configuration of a server
+func (hc *HealthChecker) auditServer(server models.Server) {
+	report := hc.securityService.Audit(server)
+	if err := hc.securityService.SaveReport(report); err != nil {
+		logger.Error("Failed to save security report: %v", err)
+		return
+	}
+	logger.Info("Security audit of server %d graded %s", server.ID, report.Grade)
 }
 
 // intPtr returns a pointer to an int
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+func TestNotifyStateChange(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastState string // Stored state of the server when the checker starts
+		states    []string
+		want      []string
+	}{
+		{"down and up", "", []string{models.StateUp, models.StateDown, models.StateDown, models.StateUp}, []string{models.NotifyMonitorDown, models.NotifyMonitorUp}},
+		{"down to degraded", models.StateUp, []string{models.StateDown, models.StateDegraded}, []string{models.NotifyMonitorDown, models.NotifyMonitorUp}},
+		{"degraded is not a change", models.StateUp, []string{models.StateDegraded, models.StateUp}, []string{}},
+		{"recovery after maintenance", models.StateUp, []string{models.StateDown, models.StateMaintenance, models.StateUp}, []string{models.NotifyMonitorDown, models.NotifyMonitorUp}},
+		{"still down after maintenance", models.StateUp, []string{models.StateDown, models.StateMaintenance, models.StateDown}, []string{models.NotifyMonitorDown}},
+		{"still down after unreachable", models.StateUp, []string{models.StateDown, models.StateUnreachable, models.StateDown}, []string{models.NotifyMonitorDown}},
+		{"down before a restart", models.StateDown, []string{models.StateDown, models.StateUp}, []string{models.NotifyMonitorUp}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notifications, serverID := newTestNotificationService(t)
+			channel, err := notifications.CreateChannel(models.CreateNotificationChannelRequest{
+				Type:      models.ChannelLog,
+				Name:      "log",
+				ServerIDs: []int{serverID},
+				Events:    []string{models.NotifyMonitorDown, models.NotifyMonitorUp},
+			})
+			if err != nil {
+				t.Fatalf("create channel: %v", err)
+			}
+			checker := NewHealthChecker(nil, nil, nil, nil, nil, notifications, nil)
+
+			// Every check sees the state stored when the checker started, like the snapshot a
+			// scheduler tick takes before earlier checks have stored their results
+			server := models.Server{ID: serverID, Name: "api", LastState: test.lastState}
+			for i, state := range test.states {
+				checker.notifyStateChange(server, models.ServerStatus{
+					State:       state,
+					LastChecked: time.Now().Add(time.Duration(i) * time.Minute),
+				})
+			}
+
+			events := []string{}
+			for _, delivery := range channelDeliveries(t, notifications, channel.ID) {
+				events = append(events, delivery.Event)
+			}
+			if !reflect.DeepEqual(events, test.want) {
+				t.Errorf("notifications = %v, want %v", events, test.want)
+			}
+		})
+	}
+}
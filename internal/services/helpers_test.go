@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/db"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	conn, err := sqlx.Connect("sqlite3", path+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := db.RunMigrations(conn); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	return conn
+}
+
+// useTestEncryptionKey sets an encryption key for the duration of a test so channel
+// settings can be stored
+func useTestEncryptionKey(t *testing.T) {
+	t.Helper()
+
+	key := config.EncryptionKey
+	config.EncryptionKey = make([]byte, 32)
+	t.Cleanup(func() { config.EncryptionKey = key })
+}
+
+// createTestServer creates a server named "api" checking https://api.example.com/health
+func createTestServer(t *testing.T, servers *ServerService) *models.Server {
+	t.Helper()
+
+	server, err := servers.CreateServer(models.CreateServerRequest{
+		Name:           "api",
+		URL:            "https://api.example.com/health",
+		Method:         "GET",
+		ExpectedStatus: 200,
+		Timeout:        5000,
+		Interval:       60000,
+	})
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	return server
+}
+
+// smtpMessage is an email received by a smtpStandIn
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpStandIn is a minimal plain-text SMTP server that accepts every message, or rejects
+// every recipient when reject is set
+type smtpStandIn struct {
+	listener net.Listener
+	reject   bool
+	messages chan smtpMessage
+}
+
+// newSMTPStandIn starts an SMTP stand-in on a local port
+func newSMTPStandIn(t *testing.T, reject bool) *smtpStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStandIn{listener: listener, reject: reject, messages: make(chan smtpMessage, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// settings returns mailer settings sending through the stand-in
+func (s *smtpStandIn) settings() SMTPSettings {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPSettings{Host: host, Port: port, From: "monitor@example.com", TLS: SMTPPlain}
+}
+
+// serve speaks just enough SMTP for net/smtp to deliver a message
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var message smtpMessage
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message = smtpMessage{From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			if s.reject {
+				reply("550 mailbox unavailable")
+				continue
+			}
+			message.To = append(message.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			message.Data = data.String()
+			s.messages <- message
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// httpRequest is a request received by a httpStandIn
+type httpRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// httpResponse is a canned response of a httpStandIn
+type httpResponse struct {
+	Status int
+	Body   string
+}
+
+// httpStandIn is an HTTP server that records every request and answers with its canned
+// responses in order, repeating the last one
+type httpStandIn struct {
+	*httptest.Server
+	requests chan httpRequest
+
+	mu        sync.Mutex
+	responses []httpResponse
+}
+
+// newHTTPStandIn starts an HTTP stand-in on a local port
+func newHTTPStandIn(t *testing.T, responses ...httpResponse) *httpStandIn {
+	t.Helper()
+
+	s := &httpStandIn{requests: make(chan httpRequest, 10), responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve records a request and writes the next response
+func (s *httpStandIn) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.requests <- httpRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body}
+
+	s.mu.Lock()
+	response := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(response.Status)
+	w.Write([]byte(response.Body))
+}
+
+// next returns the oldest request not yet returned, failing the test if none arrives
+func (s *httpStandIn) next(t *testing.T) httpRequest {
+	t.Helper()
+
+	select {
+	case request := <-s.requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+		return httpRequest{}
+	}
+}
@@ -0,0 +1,545 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// maxHistoryBuckets limits how many buckets a single aggregation can produce
+const maxHistoryBuckets = 10000
+
+// ErrTooManyBuckets is returned when an aggregation would produce more than maxHistoryBuckets buckets
+var ErrTooManyBuckets = errors.New("time range too large for bucket size")
+
+// HistoryService queries and aggregates the check history of servers
+type HistoryService struct {
+	db *sqlx.DB
+}
+
+// NewHistoryService creates a new history service instance
+func NewHistoryService(db *sqlx.DB) *HistoryService {
+	return &HistoryService{
+		db: db,
+	}
+}
+
+// rollupResolution is a rollup resolution with its bucket size
+type rollupResolution struct {
+	name string
+	size time.Duration
+}
+
+// rollupResolutions lists the resolutions history is rolled up into, finest first
+var rollupResolutions = []rollupResolution{
+	{models.ResolutionMinute, time.Minute},
+	{models.ResolutionHour, time.Hour},
+	{models.ResolutionDay, 24 * time.Hour},
+}
+
+// historyCheck is the part of a check needed to aggregate history
+type historyCheck struct {
+	CheckedAt    time.Time `db:"checked_at"`
+	IsUp         bool      `db:"is_up"`
+	State        string    `db:"state"`
+	ResponseTime *int      `db:"response_time"`
+}
+
+// historyCursor identifies the last check of a page
+type historyCursor struct {
+	CheckedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// GetServerHistory returns a page of the check history of a server, newest first
+func (s *HistoryService) GetServerHistory(id int, query models.HistoryQuery) (*models.HistoryPage, error) {
+	where, args := historyConditions(id, query)
+
+	if query.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		var cursor historyCursor
+		if err := json.Unmarshal(data, &cursor); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		where += " AND (checked_at < ? OR (checked_at = ? AND id < ?))"
+		args = append(args, cursor.CheckedAt.UTC(), cursor.CheckedAt.UTC(), cursor.ID)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	page := &models.HistoryPage{History: []models.ServerHistory{}}
+	err := s.db.Select(&page.History, `
+		SELECT * FROM status_history
+		WHERE `+where+`
+		ORDER BY checked_at DESC, id DESC
+		LIMIT ?
+	`, append(args, query.Limit+1)...)
+	if err != nil {
+		logger.Error("Failed to get server history for server %d: %v", id, err)
+		return nil, err
+	}
+
+	if len(page.History) > query.Limit {
+		page.History = page.History[:query.Limit]
+		last := page.History[len(page.History)-1]
+		data, err := json.Marshal(historyCursor{CheckedAt: last.CheckedAt, ID: last.ID})
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, nil
+}
+
+// AggregateServerHistory groups the check history of a server into buckets of the given
+// size, oldest first. Only buckets containing checks are returned. Rows are streamed so
+// long ranges do not have to be loaded into memory at once. Ranges whose raw history has
+// been removed by retention are read from rollups, falling back to coarser rollups than
+// the bucket size once finer ones have expired.
+func (s *HistoryService) AggregateServerHistory(id int, query models.HistoryQuery, bucket time.Duration) ([]models.HistoryBucket, error) {
+	if query.From != nil && query.To != nil && query.To.Sub(*query.From)/bucket > maxHistoryBuckets {
+		return nil, ErrTooManyBuckets
+	}
+
+	aggregator := &bucketAggregator{size: bucket}
+
+	// Rollups do not keep individual checks, so filtered aggregations only cover raw history
+	if query.Up == nil && query.Error == "" && query.Family == "" {
+		rollups, err := s.getRollups(id, query, bucket)
+		if err != nil {
+			return nil, err
+		}
+		for _, rollup := range rollups {
+			if err := aggregator.add(rollup); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	where, args := historyConditions(id, query)
+	rows, err := s.db.Queryx(`
+		SELECT checked_at, is_up, state, response_time FROM status_history
+		WHERE `+where+`
+		ORDER BY checked_at
+	`, args...)
+	if err != nil {
+		logger.Error("Failed to aggregate server history for server %d: %v", id, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var start time.Time
+	var checks []historyCheck
+	for rows.Next() {
+		var check historyCheck
+		if err := rows.StructScan(&check); err != nil {
+			return nil, err
+		}
+
+		if len(checks) > 0 && !bucketStart(check.CheckedAt, bucket).Equal(start) {
+			if err := aggregator.add(summarizeChecks(id, "", start, checks)); err != nil {
+				return nil, err
+			}
+			checks = checks[:0]
+		}
+		start = bucketStart(check.CheckedAt, bucket)
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(checks) > 0 {
+		if err := aggregator.add(summarizeChecks(id, "", start, checks)); err != nil {
+			return nil, err
+		}
+	}
+	return aggregator.finish(), nil
+}
+
+// SummarizeServerHistory aggregates the check history of a server between from and to into
+// a single bucket. Raw history is summarised exactly, daily rollups cover older days.
+func (s *HistoryService) SummarizeServerHistory(id int, from, to time.Time) (models.HistoryBucket, error) {
+	query := models.HistoryQuery{From: &from, To: &to}
+	parts, err := s.getRollups(id, query, 24*time.Hour)
+	if err != nil {
+		return models.HistoryBucket{}, err
+	}
+
+	where, args := historyConditions(id, query)
+	rows, err := s.db.Queryx(`
+		SELECT checked_at, is_up, state, response_time FROM status_history
+		WHERE `+where+`
+	`, args...)
+	if err != nil {
+		logger.Error("Failed to summarize server history for server %d: %v", id, err)
+		return models.HistoryBucket{}, err
+	}
+	defer rows.Close()
+
+	raw := checkSummary{rollup: models.HistoryRollup{ServerID: id, BucketStart: from}}
+	for rows.Next() {
+		var check historyCheck
+		if err := rows.StructScan(&check); err != nil {
+			return models.HistoryBucket{}, err
+		}
+		raw.add(check)
+	}
+	if err := rows.Err(); err != nil {
+		return models.HistoryBucket{}, err
+	}
+	if raw.rollup.Checks > 0 {
+		parts = append(parts, raw.finish())
+	}
+	return mergeRollups(from, parts), nil
+}
+
+// outage is a period during which checks of a server failed
+type outage struct {
+	start time.Time
+	end   *time.Time // Nil while the server has not recovered
+}
+
+// serverOutages returns the periods between from and to during which a server failed its
+// checks, oldest first. An outage starts with the first failed check and ends with the next
+// successful one, checks during maintenance are ignored. Where raw history has been removed
+// by retention, minutes with more failed than successful checks count as failed.
+func (s *HistoryService) serverOutages(id int, from, to time.Time) ([]outage, error) {
+	outages := []outage{}
+	var current *outage
+	observe := func(t time.Time, failed bool) {
+		switch {
+		case failed && current == nil:
+			current = &outage{start: t}
+		case !failed && current != nil:
+			end := t
+			current.end = &end
+			outages = append(outages, *current)
+			current = nil
+		}
+	}
+
+	query := models.HistoryQuery{From: &from, To: &to}
+	rollups, err := s.getRollups(id, query, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	for _, rollup := range rollups {
+		counted := rollup.Checks - rollup.Maintenance
+		if counted > 0 {
+			observe(rollup.BucketStart, rollup.Failures*2 > counted)
+		}
+	}
+
+	where, args := historyConditions(id, query)
+	rows, err := s.db.Queryx(`
+		SELECT checked_at, is_up, state, response_time FROM status_history
+		WHERE `+where+` AND state != ?
+		ORDER BY checked_at
+	`, append(args, models.StateMaintenance)...)
+	if err != nil {
+		logger.Error("Failed to get outages of server %d: %v", id, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var check historyCheck
+		if err := rows.StructScan(&check); err != nil {
+			return nil, err
+		}
+		observe(check.CheckedAt, !check.IsUp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		outages = append(outages, *current)
+	}
+	return outages, nil
+}
+
+// getRollups returns the rollups of a server in the part of a query older than its raw
+// history, oldest first. The coarsest resolution not larger than the bucket is used, and
+// coarser resolutions fill in older ranges where it has already expired.
+func (s *HistoryService) getRollups(id int, query models.HistoryQuery, bucket time.Duration) ([]models.HistoryRollup, error) {
+	upper := query.To
+	first := []time.Time{}
+	err := s.db.Select(&first, "SELECT checked_at FROM status_history WHERE server_id = ? ORDER BY checked_at LIMIT 1", id)
+	if err != nil {
+		logger.Error("Failed to get oldest check of server %d: %v", id, err)
+		return nil, err
+	}
+	if len(first) > 0 && (upper == nil || first[0].Before(*upper)) {
+		upper = &first[0]
+	}
+
+	finest := 0
+	for i, resolution := range rollupResolutions {
+		if resolution.size <= bucket {
+			finest = i
+		}
+	}
+
+	// Collected from newest to oldest range, each resolution only covers what finer ones do not
+	var ranges [][]models.HistoryRollup
+	for _, resolution := range rollupResolutions[finest:] {
+		conditions := []string{"server_id = ?", "resolution = ?"}
+		args := []interface{}{id, resolution.name}
+		if query.From != nil {
+			conditions = append(conditions, "bucket_start >= ?")
+			args = append(args, bucketStart(*query.From, resolution.size))
+		}
+		if upper != nil {
+			conditions = append(conditions, "bucket_start <= ?")
+			args = append(args, upper.Add(-resolution.size).UTC())
+		}
+
+		rollups := []models.HistoryRollup{}
+		err := s.db.Select(&rollups, `
+			SELECT * FROM history_rollups
+			WHERE `+strings.Join(conditions, " AND ")+`
+			ORDER BY bucket_start
+		`, args...)
+		if err != nil {
+			logger.Error("Failed to get %s rollups of server %d: %v", resolution.name, id, err)
+			return nil, err
+		}
+		if len(rollups) > 0 {
+			ranges = append(ranges, rollups)
+			upper = &rollups[0].BucketStart
+		}
+	}
+
+	result := []models.HistoryRollup{}
+	for i := len(ranges) - 1; i >= 0; i-- {
+		result = append(result, ranges[i]...)
+	}
+	return result, nil
+}
+
+// historyConditions builds the SQL conditions selecting the history of a server matching a query
+func historyConditions(id int, query models.HistoryQuery) (string, []interface{}) {
+	conditions := []string{"server_id = ?"}
+	args := []interface{}{id}
+
+	if query.From != nil {
+		conditions = append(conditions, "checked_at >= ?")
+		args = append(args, query.From.UTC())
+	}
+	if query.To != nil {
+		conditions = append(conditions, "checked_at < ?")
+		args = append(args, query.To.UTC())
+	}
+	if query.Up != nil {
+		conditions = append(conditions, "is_up = ?")
+		args = append(args, *query.Up)
+	}
+	if query.Error != "" {
+		conditions = append(conditions, `error LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(query.Error)+"%")
+	}
+	if query.Family != "" {
+		conditions = append(conditions, "address_family = ?")
+		args = append(args, query.Family)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// bucketStart returns the start of the bucket containing t, buckets are aligned to UTC
+func bucketStart(t time.Time, bucket time.Duration) time.Time {
+	return t.UTC().Truncate(bucket)
+}
+
+// summarizeChecks computes the exact rollup of the checks in a bucket
+func summarizeChecks(serverID int, resolution string, start time.Time, checks []historyCheck) models.HistoryRollup {
+	summary := checkSummary{rollup: models.HistoryRollup{ServerID: serverID, Resolution: resolution, BucketStart: start}}
+	for _, check := range checks {
+		summary.add(check)
+	}
+	return summary.finish()
+}
+
+// checkSummary accumulates checks into an exact rollup
+type checkSummary struct {
+	rollup    models.HistoryRollup
+	latencies []int
+}
+
+// add counts a check
+func (s *checkSummary) add(check historyCheck) {
+	s.rollup.Checks++
+	if check.State == models.StateMaintenance {
+		s.rollup.Maintenance++
+	} else if !check.IsUp {
+		s.rollup.Failures++
+	}
+	if check.ResponseTime != nil {
+		s.latencies = append(s.latencies, *check.ResponseTime)
+		s.rollup.LatencySum += int64(*check.ResponseTime)
+	}
+}
+
+// finish computes the latency statistics and returns the rollup
+func (s *checkSummary) finish() models.HistoryRollup {
+	rollup := s.rollup
+	rollup.LatencyCount = len(s.latencies)
+	if len(s.latencies) == 0 {
+		return rollup
+	}
+
+	latencies := append([]int{}, s.latencies...)
+	sort.Ints(latencies)
+	rollup.Min = &latencies[0]
+	rollup.Max = &latencies[len(latencies)-1]
+	rollup.P50 = percentile(latencies, 50)
+	rollup.P95 = percentile(latencies, 95)
+	rollup.P99 = percentile(latencies, 99)
+	return rollup
+}
+
+// rollUpChecks groups checks sorted by time into rollups of a resolution
+func rollUpChecks(serverID int, resolution rollupResolution, checks []historyCheck) []models.HistoryRollup {
+	rollups := []models.HistoryRollup{}
+	for first := 0; first < len(checks); {
+		start := bucketStart(checks[first].CheckedAt, resolution.size)
+		last := first + 1
+		for last < len(checks) && bucketStart(checks[last].CheckedAt, resolution.size).Equal(start) {
+			last++
+		}
+		rollups = append(rollups, summarizeChecks(serverID, resolution.name, start, checks[first:last]))
+		first = last
+	}
+	return rollups
+}
+
+// mergeRollups combines the rollups of a bucket. Counts, minimum, maximum and average are
+// exact, percentiles of several rollups are approximated by their mean weighted by the
+// number of response times in each rollup.
+func mergeRollups(start time.Time, rollups []models.HistoryRollup) models.HistoryBucket {
+	bucket := models.HistoryBucket{Start: start}
+	var latencyCount int
+	var latencySum int64
+	var minLatency, maxLatency int
+	var p50, p95, p99 float64
+	for _, rollup := range rollups {
+		bucket.Checks += rollup.Checks
+		bucket.Maintenance += rollup.Maintenance
+		bucket.Successes += rollup.Checks - rollup.Maintenance - rollup.Failures
+		if rollup.LatencyCount == 0 {
+			continue
+		}
+
+		if latencyCount == 0 || *rollup.Min < minLatency {
+			minLatency = *rollup.Min
+		}
+		if latencyCount == 0 || *rollup.Max > maxLatency {
+			maxLatency = *rollup.Max
+		}
+		latencyCount += rollup.LatencyCount
+		latencySum += rollup.LatencySum
+		weight := float64(rollup.LatencyCount)
+		p50 += float64(*rollup.P50) * weight
+		p95 += float64(*rollup.P95) * weight
+		p99 += float64(*rollup.P99) * weight
+	}
+
+	if counted := bucket.Checks - bucket.Maintenance; counted > 0 {
+		ratio := float64(bucket.Successes) / float64(counted)
+		bucket.SuccessRatio = &ratio
+	}
+	if latencyCount == 0 {
+		return bucket
+	}
+
+	avg := float64(latencySum) / float64(latencyCount)
+	bucket.Min = &minLatency
+	bucket.Max = &maxLatency
+	bucket.Avg = &avg
+	bucket.P50 = weightedMean(p50, latencyCount)
+	bucket.P95 = weightedMean(p95, latencyCount)
+	bucket.P99 = weightedMean(p99, latencyCount)
+	return bucket
+}
+
+// weightedMean rounds a weighted sum divided by the total weight to whole milliseconds
+func weightedMean(sum float64, weight int) *int {
+	mean := int(math.Round(sum / float64(weight)))
+	return &mean
+}
+
+// bucketAggregator merges rollups, oldest first, into buckets of a fixed size
+type bucketAggregator struct {
+	size    time.Duration
+	buckets []models.HistoryBucket
+	start   time.Time
+	pending []models.HistoryRollup
+}
+
+// add adds a rollup to the bucket containing its start
+func (a *bucketAggregator) add(rollup models.HistoryRollup) error {
+	start := bucketStart(rollup.BucketStart, a.size)
+	if len(a.pending) > 0 && !start.Equal(a.start) {
+		a.flush()
+		if len(a.buckets) >= maxHistoryBuckets {
+			return ErrTooManyBuckets
+		}
+	}
+	a.start = start
+	a.pending = append(a.pending, rollup)
+	return nil
+}
+
+// flush completes the current bucket
+func (a *bucketAggregator) flush() {
+	if len(a.pending) == 0 {
+		return
+	}
+	a.buckets = append(a.buckets, mergeRollups(a.start, a.pending))
+	a.pending = a.pending[:0]
+}
+
+// finish completes the last bucket and returns all buckets
+func (a *bucketAggregator) finish() []models.HistoryBucket {
+	a.flush()
+	if a.buckets == nil {
+		return []models.HistoryBucket{}
+	}
+	return a.buckets
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []int, p float64) *int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return &sorted[rank-1]
+}
+
+// ParseBucket parses an aggregation bucket size
+func ParseBucket(value string) (time.Duration, error) {
+	switch value {
+	case "1m":
+		return time.Minute, nil
+	case "5m":
+		return 5 * time.Minute, nil
+	case "1h":
+		return time.Hour, nil
+	case "1d":
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid bucket %q, expected 1m, 5m, 1h or 1d", value)
+}
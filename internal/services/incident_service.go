@@ -0,0 +1,603 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+var (
+	// ErrIncidentResolved is returned when acknowledging an incident that is already resolved
+	ErrIncidentResolved = errors.New("incident is resolved")
+
+	// ErrUnknownUser is returned when referencing a user that does not exist
+	ErrUnknownUser = errors.New("unknown user")
+)
+
+// incidentSelect selects incidents along with the emails of the users who acknowledged them
+// and are assigned to them
+const incidentSelect = `
+	SELECT incidents.*, acknowledger.email AS acknowledged_by_email, assignee.email AS assignee_email
+	FROM incidents
+	LEFT JOIN users acknowledger ON acknowledger.id = incidents.acknowledged_by
+	LEFT JOIN users assignee ON assignee.id = incidents.assignee_id
+`
+
+// IncidentService manages incidents and opens and resolves them from check results
+type IncidentService struct {
+	db            *sqlx.DB
+	notifications *NotificationService
+}
+
+// NewIncidentService creates a new incident service instance
+func NewIncidentService(db *sqlx.DB, notifications *NotificationService) *IncidentService {
+	return &IncidentService{
+		db:            db,
+		notifications: notifications,
+	}
+}
+
+// Observe updates the automatic incident of a server from the results of a check. A DOWN
+// result opens an incident or is attached to the open one, and the incident is resolved once
+// every result is up again. Checks during maintenance and UNREACHABLE results, whose
+// incident belongs to the failing dependency, leave incidents unchanged.
+func (s *IncidentService) Observe(server models.Server, statuses []models.ServerStatus) error {
+	down, up := false, true
+	var checkedAt time.Time
+	var failure string
+	for _, status := range statuses {
+		switch status.State {
+		case models.StateMaintenance, models.StateUnreachable:
+			return nil
+		case models.StateDown:
+			down = true
+			if status.Error != nil && failure == "" {
+				failure = *status.Error
+			}
+		}
+		up = up && status.IsUp
+		if status.LastChecked.After(checkedAt) {
+			checkedAt = status.LastChecked
+		}
+	}
+	if checkedAt.IsZero() {
+		checkedAt = time.Now().UTC()
+	}
+
+	incident, err := s.openIncident(server.ID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case down && incident == nil:
+		return s.open(server, checkedAt, failure)
+	case down:
+		_, err := s.db.Exec(`
+			UPDATE incidents SET failures = failures + 1, last_failure_at = ?, last_error = ?, updated_at = ?
+			WHERE id = ?
+		`, checkedAt, failure, time.Now().UTC(), incident.ID)
+		if err != nil {
+			logger.Error("Failed to attach failure to incident %d: %v", incident.ID, err)
+			return err
+		}
+		return s.remind(incident, checkedAt)
+	case up && incident != nil:
+		message := fmt.Sprintf("Recovered after %d failed checks", incident.Failures)
+		if err := s.setStatus(incident, models.IncidentResolved, message, "", checkedAt); err != nil {
+			return err
+		}
+		logger.Info("Incident %d of server %s resolved after %v", incident.ID, server.Name, checkedAt.Sub(incident.StartedAt).Round(time.Second))
+	}
+	return nil
+}
+
+// open opens an automatic incident for a server whose check failed
+func (s *IncidentService) open(server models.Server, checkedAt time.Time, failure string) error {
+	now := time.Now().UTC()
+	incident := &models.Incident{
+		ServerID:       server.ID,
+		Title:          fmt.Sprintf("%s is down", server.Name),
+		Status:         models.IncidentInvestigating,
+		Automatic:      true,
+		StartedAt:      checkedAt,
+		Failures:       1,
+		LastFailureAt:  &checkedAt,
+		LastError:      failure,
+		LastNotifiedAt: &checkedAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.insert(incident, failure); err != nil {
+		return err
+	}
+
+	logger.Warn("Opened incident %d: %s", incident.ID, incident.Title)
+	return nil
+}
+
+// remind notifies an open incident again if nobody has acknowledged it within the repeat interval
+func (s *IncidentService) remind(incident *models.Incident, at time.Time) error {
+	if incident.AcknowledgedAt != nil || config.IncidentRepeatInterval <= 0 {
+		return nil
+	}
+	if incident.LastNotifiedAt != nil && at.Sub(*incident.LastNotifiedAt) < config.IncidentRepeatInterval {
+		return nil
+	}
+
+	_, err := s.db.Exec("UPDATE incidents SET last_notified_at = ? WHERE id = ?", at, incident.ID)
+	if err != nil {
+		logger.Error("Failed to record reminder of incident %d: %v", incident.ID, err)
+		return err
+	}
+
+	elapsed := at.Sub(incident.StartedAt).Round(time.Second)
+	logger.Warn("Incident %d: %s is still unacknowledged after %v", incident.ID, incident.Title, elapsed)
+	s.notify(incident, models.NotifyIncidentReminder, models.LevelWarning, fmt.Sprintf("Still unacknowledged after %v", elapsed), at)
+	return nil
+}
+
+// CreateIncident opens an incident manually, it is not resolved automatically
+func (s *IncidentService) CreateIncident(req models.CreateIncidentRequest) (*models.Incident, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", req.ServerID); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownServer, req.ServerID)
+	}
+
+	now := time.Now().UTC()
+	incident := &models.Incident{
+		ServerID:  req.ServerID,
+		Title:     req.Title,
+		Status:    req.Status,
+		StartedAt: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if incident.Status == "" {
+		incident.Status = models.IncidentInvestigating
+	}
+	if err := s.insert(incident, req.Message); err != nil {
+		return nil, err
+	}
+	return s.GetIncidentByID(incident.ID)
+}
+
+// insert stores a new incident along with the event opening its timeline
+func (s *IncidentService) insert(incident *models.Incident, message string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO incidents (server_id, title, status, automatic, started_at, failures, last_failure_at, last_error, last_notified_at, created_at, updated_at)
+		VALUES (:server_id, :title, :status, :automatic, :started_at, :failures, :last_failure_at, :last_error, :last_notified_at, :created_at, :updated_at)
+	`, incident)
+	if err != nil {
+		logger.Error("Failed to create incident for server %d: %v", incident.ServerID, err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	incident.ID = int(id)
+
+	if err := addIncidentEvent(tx, incident.ID, models.EventOpened, incident.Status, message, "", incident.StartedAt); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.notify(incident, models.NotifyIncidentOpened, models.LevelCritical, message, incident.StartedAt)
+	return nil
+}
+
+// GetIncidents returns the incidents matching a filter, newest first
+func (s *IncidentService) GetIncidents(filter models.IncidentFilter) ([]models.Incident, error) {
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if filter.ServerID != nil {
+		conditions = append(conditions, "incidents.server_id = ?")
+		args = append(args, *filter.ServerID)
+	}
+	if filter.GroupID != nil {
+		subtree, err := groupWithDescendants(s.db, *filter.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		condition, groupArgs, err := sqlx.In("incidents.server_id IN (SELECT id FROM servers WHERE group_id IN (?))", subtree)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, groupArgs...)
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := []string{}
+		for _, status := range filter.Statuses {
+			if status == "open" {
+				statuses = append(statuses, models.IncidentInvestigating, models.IncidentIdentified, models.IncidentMonitoring)
+			} else {
+				statuses = append(statuses, status)
+			}
+		}
+		condition, statusArgs, err := sqlx.In("incidents.status IN (?)", statuses)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, statusArgs...)
+	}
+	if filter.Acknowledged != nil {
+		if *filter.Acknowledged {
+			conditions = append(conditions, "incidents.acknowledged_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "incidents.acknowledged_at IS NULL")
+		}
+	}
+	if filter.Assignee != "" {
+		conditions = append(conditions, "assignee.email = ?")
+		args = append(args, filter.Assignee)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "(incidents.resolved_at IS NULL OR incidents.resolved_at >= ?)")
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "incidents.started_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := incidentSelect + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY incidents.started_at DESC, incidents.id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	incidents := []models.Incident{}
+	if err := s.db.Select(&incidents, query, args...); err != nil {
+		logger.Error("Failed to get incidents: %v", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for i := range incidents {
+		annotateIncident(&incidents[i], now)
+	}
+	return incidents, nil
+}
+
+// GetIncidentByID returns an incident with its timeline, oldest event first
+func (s *IncidentService) GetIncidentByID(id int) (*models.Incident, error) {
+	var incident models.Incident
+	err := s.db.Get(&incident, incidentSelect+" WHERE incidents.id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get incident %d: %v", id, err)
+		return nil, err
+	}
+
+	incident.Timeline = []models.IncidentEvent{}
+	err = s.db.Select(&incident.Timeline, "SELECT * FROM incident_events WHERE incident_id = ? ORDER BY created_at, id", id)
+	if err != nil {
+		logger.Error("Failed to get timeline of incident %d: %v", id, err)
+		return nil, err
+	}
+
+	annotateIncident(&incident, time.Now().UTC())
+	return &incident, nil
+}
+
+// UpdateIncident changes the title or status of an incident. Status changes are added to the
+// timeline with the message, a message without a status change is added as a note.
+// Resolving an automatic incident while the server is still down opens a new one on the next
+// failed check.
+func (s *IncidentService) UpdateIncident(id int, req models.UpdateIncidentRequest) (*models.Incident, error) {
+	incident, err := s.GetIncidentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if incident == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	if req.Title != nil && *req.Title != incident.Title {
+		_, err := s.db.Exec("UPDATE incidents SET title = ?, updated_at = ? WHERE id = ?", *req.Title, now, id)
+		if err != nil {
+			logger.Error("Failed to update incident %d: %v", id, err)
+			return nil, err
+		}
+		incident.Title = *req.Title
+	}
+
+	switch {
+	case req.Status != nil && *req.Status != incident.Status:
+		if err := s.setStatus(incident, *req.Status, req.Message, "", now); err != nil {
+			return nil, err
+		}
+	case req.Message != "":
+		if _, err := s.AddNote(id, models.AddIncidentNoteRequest{Message: req.Message}); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetIncidentByID(id)
+}
+
+// setStatus changes the status of an incident and records the change in its timeline.
+// Resolving sets the resolution time, any other status reopens a resolved incident.
+func (s *IncidentService) setStatus(incident *models.Incident, status, message, actor string, at time.Time) error {
+	var resolvedAt *time.Time
+	event := models.EventStatus
+	if status == models.IncidentResolved {
+		resolvedAt = &at
+		event = models.EventResolved
+	}
+	err := s.update(incident.ID, event, status, message, actor, at,
+		"UPDATE incidents SET status = ?, resolved_at = ?, updated_at = ? WHERE id = ?", status, resolvedAt, time.Now().UTC(), incident.ID)
+	if err != nil {
+		return err
+	}
+
+	if status == models.IncidentResolved {
+		resolved := *incident
+		resolved.Status = status
+		resolved.ResolvedAt = resolvedAt
+		s.notify(&resolved, models.NotifyIncidentResolved, models.LevelInfo, message, at)
+	}
+	return nil
+}
+
+// notify sends a notification about an incident to the channels bound to its server
+func (s *IncidentService) notify(incident *models.Incident, event, level, message string, at time.Time) {
+	notification := models.Notification{
+		Event:   event,
+		Level:   level,
+		Title:   incident.Title,
+		Message: message,
+		Incident: &models.NotificationIncident{
+			ID:         incident.ID,
+			Title:      incident.Title,
+			Status:     incident.Status,
+			StartedAt:  incident.StartedAt.UTC(),
+			ResolvedAt: incident.ResolvedAt,
+			Failures:   incident.Failures,
+			LastError:  incident.LastError,
+			Duration:   at.Sub(incident.StartedAt).Seconds(),
+		},
+		At: at.UTC(),
+	}
+
+	var server models.NotificationServer
+	err := s.db.Get(&server, "SELECT id, name, url, last_state, last_response_time FROM servers WHERE id = ?", incident.ServerID)
+	if err != nil {
+		logger.Error("Failed to get server %d of incident %d: %v", incident.ServerID, incident.ID, err)
+	} else {
+		notification.Server = &server
+	}
+
+	s.notifications.Notify(notification, incident.ServerID)
+}
+
+// Acknowledge records that a user is handling an incident, which stops its reminders. The
+// incident is assigned to the user unless it already has an assignee. It returns nil if the
+// incident does not exist.
+func (s *IncidentService) Acknowledge(id int, user models.User) (*models.Incident, error) {
+	incident, err := s.GetIncidentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if incident == nil {
+		return nil, nil
+	}
+	if incident.Status == models.IncidentResolved {
+		return nil, ErrIncidentResolved
+	}
+	if incident.AcknowledgedBy != nil && *incident.AcknowledgedBy == user.ID {
+		return incident, nil
+	}
+
+	now := time.Now().UTC()
+	err = s.update(id, models.EventAcknowledged, incident.Status, fmt.Sprintf("Acknowledged by %s", user.Email), user.Email, now, `
+		UPDATE incidents SET acknowledged_by = ?, acknowledged_at = ?, assignee_id = COALESCE(assignee_id, ?), updated_at = ?
+		WHERE id = ?
+	`, user.ID, now, user.ID, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Incident %d acknowledged by %s", id, user.Email)
+	return s.GetIncidentByID(id)
+}
+
+// Unacknowledge clears the acknowledgement of an incident, reminders resume after the repeat
+// interval. It returns nil if the incident does not exist.
+func (s *IncidentService) Unacknowledge(id int, user models.User) (*models.Incident, error) {
+	incident, err := s.GetIncidentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if incident == nil {
+		return nil, nil
+	}
+	if incident.AcknowledgedAt == nil {
+		return incident, nil
+	}
+
+	now := time.Now().UTC()
+	err = s.update(id, models.EventUnacknowledged, incident.Status, fmt.Sprintf("Unacknowledged by %s", user.Email), user.Email, now, `
+		UPDATE incidents SET acknowledged_by = NULL, acknowledged_at = NULL, last_notified_at = ?, updated_at = ?
+		WHERE id = ?
+	`, now, now, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetIncidentByID(id)
+}
+
+// Assign assigns an incident to the user with an email, or unassigns it if the email is
+// empty. It returns nil if the incident does not exist.
+func (s *IncidentService) Assign(id int, req models.AssignIncidentRequest, user models.User) (*models.Incident, error) {
+	incident, err := s.GetIncidentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if incident == nil {
+		return nil, nil
+	}
+
+	var assigneeID *int
+	message := fmt.Sprintf("Unassigned by %s", user.Email)
+	if req.Email != "" {
+		var assignee models.User
+		err := s.db.Get(&assignee, "SELECT * FROM users WHERE email = ?", req.Email)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownUser, req.Email)
+			}
+			logger.Error("Failed to get user %s: %v", req.Email, err)
+			return nil, err
+		}
+		assigneeID = &assignee.ID
+		message = fmt.Sprintf("Assigned to %s by %s", assignee.Email, user.Email)
+	}
+
+	now := time.Now().UTC()
+	err = s.update(id, models.EventAssigned, incident.Status, message, user.Email, now,
+		"UPDATE incidents SET assignee_id = ?, updated_at = ? WHERE id = ?", assigneeID, now, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetIncidentByID(id)
+}
+
+// update applies a change to an incident and records it in the timeline in one transaction
+func (s *IncidentService) update(id int, eventType, status, message, actor string, at time.Time, query string, args ...interface{}) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Error("Failed to update incident %d: %v", id, err)
+		return err
+	}
+	if err := addIncidentEvent(tx, id, eventType, status, message, actor, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddNote adds a note to the timeline of an incident. It returns nil if the incident does not exist.
+func (s *IncidentService) AddNote(id int, req models.AddIncidentNoteRequest) (*models.IncidentEvent, error) {
+	var incident models.Incident
+	err := s.db.Get(&incident, "SELECT * FROM incidents WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get incident %d: %v", id, err)
+		return nil, err
+	}
+
+	event := &models.IncidentEvent{
+		IncidentID: id,
+		Type:       models.EventNote,
+		Status:     incident.Status,
+		Message:    req.Message,
+		CreatedAt:  time.Now().UTC(),
+	}
+	result, err := s.db.NamedExec(`
+		INSERT INTO incident_events (incident_id, type, status, message, created_at)
+		VALUES (:incident_id, :type, :status, :message, :created_at)
+	`, event)
+	if err != nil {
+		logger.Error("Failed to add note to incident %d: %v", id, err)
+		return nil, err
+	}
+
+	eventID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	event.ID = int(eventID)
+	return event, nil
+}
+
+// DeleteIncident deletes an incident and its timeline
+func (s *IncidentService) DeleteIncident(id int) error {
+	_, err := s.db.Exec("DELETE FROM incident_events WHERE incident_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete timeline of incident %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM notification_threads WHERE incident_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete notification threads of incident %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM incidents WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete incident %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// openIncident returns the unresolved automatic incident of a server, or nil if there is none
+func (s *IncidentService) openIncident(serverID int) (*models.Incident, error) {
+	var incident models.Incident
+	err := s.db.Get(&incident, `
+		SELECT * FROM incidents WHERE server_id = ? AND automatic = 1 AND status != ?
+		ORDER BY started_at DESC LIMIT 1
+	`, serverID, models.IncidentResolved)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get open incident of server %d: %v", serverID, err)
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// addIncidentEvent adds an event to the timeline of an incident
+func addIncidentEvent(tx *sqlx.Tx, incidentID int, eventType, status, message, actor string, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO incident_events (incident_id, type, status, message, actor, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, incidentID, eventType, status, message, actor, at)
+	if err != nil {
+		logger.Error("Failed to add %s event to incident %d: %v", eventType, incidentID, err)
+		return err
+	}
+	return nil
+}
+
+// annotateIncident fills in the duration of an incident
+func annotateIncident(incident *models.Incident, now time.Time) {
+	end := now
+	if incident.ResolvedAt != nil {
+		end = *incident.ResolvedAt
+	}
+	incident.Duration = end.Sub(incident.StartedAt).Seconds()
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+)
+
+// SMTP connection security modes
+const (
+	SMTPStartTLS = "starttls" // Upgrade with STARTTLS when the server supports it
+	SMTPTLS      = "tls"      // Implicit TLS, usually on port 465
+	SMTPPlain    = "none"     // Never encrypt
+)
+
+// smtpTimeout limits how long sending a single email may take
+const smtpTimeout = 30 * time.Second
+
+// ErrMailerNotConfigured is returned when sending email without an SMTP server configured
+var ErrMailerNotConfigured = errors.New("smtp server not configured")
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SMTPSettings describes how to reach an SMTP server
+type SMTPSettings struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	TLS      string `json:"tls"` // starttls, tls or none
+}
+
+// DefaultSMTPSettings returns the SMTP server configured in the environment
+func DefaultSMTPSettings() SMTPSettings {
+	return SMTPSettings{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+		From:     config.SMTPFrom,
+		TLS:      config.SMTPTLS,
+	}
+}
+
+// Mailer sends email through an SMTP server
+type Mailer struct {
+	settings SMTPSettings
+}
+
+// NewMailer creates a new mailer instance sending through the SMTP server configured in the environment
+func NewMailer() *Mailer {
+	return &Mailer{
+		settings: DefaultSMTPSettings(),
+	}
+}
+
+// Send sends an HTML email with optional attachments
+func (m *Mailer) Send(to []string, subject, html string, attachments ...Attachment) error {
+	return m.SendAlternative(context.Background(), to, subject, "", html, attachments...)
+}
+
+// SendAlternative sends an email with plain text and HTML versions of its body, either of
+// which may be empty, and optional attachments
+func (m *Mailer) SendAlternative(ctx context.Context, to []string, subject, text, html string, attachments ...Attachment) error {
+	if m.settings.Host == "" {
+		return ErrMailerNotConfigured
+	}
+
+	message, err := buildMessage(m.settings.From, to, subject, text, html, attachments)
+	if err != nil {
+		return err
+	}
+	if err := m.deliver(ctx, to, message); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// deliver sends a message over a new SMTP connection secured as configured
+func (m *Mailer) deliver(ctx context.Context, to []string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.settings.Host, m.settings.Port)
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: m.settings.Host}
+	if m.settings.TLS == SMTPTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, m.settings.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.settings.TLS == "" || m.settings.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if m.settings.Username != "" {
+		auth := smtp.PlainAuth("", m.settings.Username, m.settings.Password, m.settings.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.settings.From); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders a MIME message with a text and HTML body followed by its attachments
+func buildMessage(from string, to []string, subject, text, html string, attachments []Attachment) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	switch {
+	case text != "" && html != "":
+		// Clients show the last alternative they support, so HTML goes last
+		var body bytes.Buffer
+		alternative := multipart.NewWriter(&body)
+		if err := writeTextPart(alternative, "text/plain; charset=utf-8", text); err != nil {
+			return nil, err
+		}
+		if err := writeTextPart(alternative, "text/html; charset=utf-8", html); err != nil {
+			return nil, err
+		}
+		if err := alternative.Close(); err != nil {
+			return nil, err
+		}
+
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(body.Bytes()); err != nil {
+			return nil, err
+		}
+	case text != "":
+		if err := writeTextPart(writer, "text/plain; charset=utf-8", text); err != nil {
+			return nil, err
+		}
+	default:
+		if err := writeTextPart(writer, "text/html; charset=utf-8", html); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attachment := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Base64 lines must not exceed 76 characters
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTextPart adds a quoted-printable text part to a multipart message
+func writeTextPart(writer *multipart.Writer, contentType, body string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := quotedprintable.NewWriter(part)
+	if _, err := encoder.Write([]byte(body)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/schedule"
+)
+
+var (
+	// ErrInvalidSchedule is returned when a maintenance window has an unparseable recurrence or timezone
+	ErrInvalidSchedule = errors.New("invalid schedule")
+
+	// ErrUnknownServer is returned when referencing a server that does not exist
+	ErrUnknownServer = errors.New("unknown server")
+
+	// ErrNoMaintenanceTargets is returned when a maintenance window has neither servers nor tags
+	ErrNoMaintenanceTargets = errors.New("maintenance window must target at least one server or tag")
+)
+
+// MaintenanceService manages maintenance windows
+type MaintenanceService struct {
+	db *sqlx.DB
+}
+
+// NewMaintenanceService creates a new maintenance service instance
+func NewMaintenanceService(db *sqlx.DB) *MaintenanceService {
+	return &MaintenanceService{
+		db: db,
+	}
+}
+
+// CreateWindow validates and stores a maintenance window
+func (s *MaintenanceService) CreateWindow(req models.CreateMaintenanceWindowRequest) (*models.MaintenanceWindow, error) {
+	now := time.Now().UTC()
+	window := &models.MaintenanceWindow{
+		Name:        req.Name,
+		Description: req.Description,
+		StartsAt:    req.StartsAt.UTC(),
+		Duration:    req.Duration,
+		Recurrence:  req.Recurrence,
+		Timezone:    req.Timezone,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ServerIDs:   uniqueIDs(req.ServerIDs),
+		Tags:        req.Tags,
+	}
+	if window.Tags == nil {
+		window.Tags = []string{}
+	}
+	if window.Timezone == "" {
+		window.Timezone = "UTC"
+	}
+	if err := s.validate(window); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO maintenance_windows (name, description, starts_at, duration, recurrence, timezone, created_at, updated_at)
+		VALUES (:name, :description, :starts_at, :duration, :recurrence, :timezone, :created_at, :updated_at)
+	`, window)
+	if err != nil {
+		logger.Error("Failed to create maintenance window: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	window.ID = int(id)
+
+	if err := setWindowServers(tx, window.ID, window.ServerIDs); err != nil {
+		return nil, err
+	}
+	if err := setWindowTags(tx, window.ID, window.Tags); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.annotate(window, now)
+	return window, nil
+}
+
+// GetWindows returns all maintenance windows
+func (s *MaintenanceService) GetWindows() ([]models.MaintenanceWindow, error) {
+	windows := []models.MaintenanceWindow{}
+	err := s.db.Select(&windows, "SELECT * FROM maintenance_windows ORDER BY starts_at")
+	if err != nil {
+		logger.Error("Failed to get maintenance windows: %v", err)
+		return nil, err
+	}
+
+	links := []struct {
+		WindowID int `db:"window_id"`
+		ServerID int `db:"server_id"`
+	}{}
+	err = s.db.Select(&links, "SELECT window_id, server_id FROM maintenance_window_servers ORDER BY server_id")
+	if err != nil {
+		logger.Error("Failed to get maintenance window servers: %v", err)
+		return nil, err
+	}
+
+	servers := make(map[int][]int)
+	for _, link := range links {
+		servers[link.WindowID] = append(servers[link.WindowID], link.ServerID)
+	}
+
+	tagLinks := []windowTag{}
+	err = s.db.Select(&tagLinks, "SELECT * FROM maintenance_window_tags ORDER BY key, value")
+	if err != nil {
+		logger.Error("Failed to get maintenance window tags: %v", err)
+		return nil, err
+	}
+
+	tags := make(map[int][]string)
+	for _, tag := range tagLinks {
+		tags[tag.WindowID] = append(tags[tag.WindowID], tag.selector())
+	}
+
+	now := time.Now().UTC()
+	for i := range windows {
+		windows[i].ServerIDs = servers[windows[i].ID]
+		if windows[i].ServerIDs == nil {
+			windows[i].ServerIDs = []int{}
+		}
+		windows[i].Tags = tags[windows[i].ID]
+		if windows[i].Tags == nil {
+			windows[i].Tags = []string{}
+		}
+		s.annotate(&windows[i], now)
+	}
+	return windows, nil
+}
+
+// GetWindowByID returns a maintenance window by its ID
+func (s *MaintenanceService) GetWindowByID(id int) (*models.MaintenanceWindow, error) {
+	var window models.MaintenanceWindow
+	err := s.db.Get(&window, "SELECT * FROM maintenance_windows WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get maintenance window %d: %v", id, err)
+		return nil, err
+	}
+
+	window.ServerIDs = []int{}
+	err = s.db.Select(&window.ServerIDs, "SELECT server_id FROM maintenance_window_servers WHERE window_id = ? ORDER BY server_id", id)
+	if err != nil {
+		logger.Error("Failed to get servers of maintenance window %d: %v", id, err)
+		return nil, err
+	}
+
+	tagLinks := []windowTag{}
+	err = s.db.Select(&tagLinks, "SELECT * FROM maintenance_window_tags WHERE window_id = ? ORDER BY key, value", id)
+	if err != nil {
+		logger.Error("Failed to get tags of maintenance window %d: %v", id, err)
+		return nil, err
+	}
+	window.Tags = []string{}
+	for _, tag := range tagLinks {
+		window.Tags = append(window.Tags, tag.selector())
+	}
+
+	s.annotate(&window, time.Now().UTC())
+	return &window, nil
+}
+
+// UpdateWindow updates a maintenance window
+func (s *MaintenanceService) UpdateWindow(id int, req models.UpdateMaintenanceWindowRequest) (*models.MaintenanceWindow, error) {
+	window, err := s.GetWindowByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if window == nil {
+		return nil, nil
+	}
+
+	if req.Name != nil {
+		window.Name = *req.Name
+	}
+	if req.Description != nil {
+		window.Description = *req.Description
+	}
+	if req.StartsAt != nil {
+		window.StartsAt = req.StartsAt.UTC()
+	}
+	if req.Duration != nil {
+		window.Duration = *req.Duration
+	}
+	if req.Recurrence != nil {
+		window.Recurrence = *req.Recurrence
+	}
+	if req.Timezone != nil {
+		window.Timezone = *req.Timezone
+	}
+	if req.ServerIDs != nil {
+		window.ServerIDs = uniqueIDs(req.ServerIDs)
+	}
+	if req.Tags != nil {
+		window.Tags = req.Tags
+	}
+	window.UpdatedAt = time.Now().UTC()
+
+	if err := s.validate(window); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(`
+		UPDATE maintenance_windows
+		SET name = :name, description = :description, starts_at = :starts_at, duration = :duration,
+			recurrence = :recurrence, timezone = :timezone, updated_at = :updated_at
+		WHERE id = :id
+	`, window)
+	if err != nil {
+		logger.Error("Failed to update maintenance window %d: %v", id, err)
+		return nil, err
+	}
+
+	if req.ServerIDs != nil {
+		if _, err := tx.Exec("DELETE FROM maintenance_window_servers WHERE window_id = ?", id); err != nil {
+			return nil, err
+		}
+		if err := setWindowServers(tx, id, window.ServerIDs); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		if _, err := tx.Exec("DELETE FROM maintenance_window_tags WHERE window_id = ?", id); err != nil {
+			return nil, err
+		}
+		if err := setWindowTags(tx, id, window.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.annotate(window, window.UpdatedAt)
+	return window, nil
+}
+
+// DeleteWindow deletes a maintenance window
+func (s *MaintenanceService) DeleteWindow(id int) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM maintenance_window_servers WHERE window_id = ?", id); err != nil {
+		logger.Error("Failed to delete servers of maintenance window %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM maintenance_window_tags WHERE window_id = ?", id); err != nil {
+		logger.Error("Failed to delete tags of maintenance window %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM maintenance_windows WHERE id = ?", id); err != nil {
+		logger.Error("Failed to delete maintenance window %d: %v", id, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// ActiveServerIDs returns the IDs of servers covered by a maintenance window at time t,
+// either directly or through one of its tag selectors
+func (s *MaintenanceService) ActiveServerIDs(t time.Time) (map[int]bool, error) {
+	windows, err := s.GetWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[int]bool)
+	for _, window := range windows {
+		if !windowActive(window, t) {
+			continue
+		}
+		for _, serverID := range window.ServerIDs {
+			active[serverID] = true
+		}
+		for _, tag := range window.Tags {
+			selector, err := ParseTagSelector(tag)
+			if err != nil {
+				continue
+			}
+			serverIDs := []int{}
+			err = s.db.Select(&serverIDs, "SELECT server_id FROM server_tags WHERE key = ? AND (? = '' OR value = ?)",
+				selector.Key, selector.Value, selector.Value)
+			if err != nil {
+				logger.Error("Failed to resolve maintenance window tag %s: %v", tag, err)
+				return nil, err
+			}
+			for _, serverID := range serverIDs {
+				active[serverID] = true
+			}
+		}
+	}
+	return active, nil
+}
+
+// InMaintenance reports whether a server is covered by a maintenance window at time t
+func (s *MaintenanceService) InMaintenance(serverID int, t time.Time) (bool, error) {
+	active, err := s.ActiveServerIDs(t)
+	if err != nil {
+		return false, err
+	}
+	return active[serverID], nil
+}
+
+// validate checks the timezone, recurrence and servers of a window
+func (s *MaintenanceService) validate(window *models.MaintenanceWindow) error {
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, window.Timezone)
+	}
+	if window.Recurrence != "" {
+		if _, err := schedule.Parse(window.Recurrence, window.StartsAt); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+	}
+
+	if len(window.ServerIDs) == 0 && len(window.Tags) == 0 {
+		return ErrNoMaintenanceTargets
+	}
+	for _, tag := range window.Tags {
+		if _, err := ParseTagSelector(tag); err != nil {
+			return fmt.Errorf("%w: %v", ErrNoMaintenanceTargets, err)
+		}
+	}
+
+	for _, serverID := range window.ServerIDs {
+		var count int
+		if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", serverID); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %d", ErrUnknownServer, serverID)
+		}
+	}
+	return nil
+}
+
+// annotate fills in whether a window is active and when it next starts
+func (s *MaintenanceService) annotate(window *models.MaintenanceWindow, now time.Time) {
+	window.Active = windowActive(*window, now)
+	window.NextStart = nil
+	if next, ok := nextWindowStart(*window, now); ok {
+		window.NextStart = &next
+	}
+}
+
+// setWindowServers links servers to a maintenance window
+func setWindowServers(tx *sqlx.Tx, windowID int, serverIDs []int) error {
+	for _, serverID := range serverIDs {
+		_, err := tx.Exec("INSERT OR IGNORE INTO maintenance_window_servers (window_id, server_id) VALUES (?, ?)", windowID, serverID)
+		if err != nil {
+			logger.Error("Failed to link server %d to maintenance window %d: %v", serverID, windowID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// windowTag is a tag selector of a maintenance window as stored in the database
+type windowTag struct {
+	WindowID int    `db:"window_id"`
+	Key      string `db:"key"`
+	Value    string `db:"value"`
+}
+
+// selector formats the tag as a "key:value" selector, or just the key when any value matches
+func (t windowTag) selector() string {
+	if t.Value == "" {
+		return t.Key
+	}
+	return t.Key + ":" + t.Value
+}
+
+// setWindowTags stores the tag selectors of a maintenance window
+func setWindowTags(tx *sqlx.Tx, windowID int, tags []string) error {
+	for _, tag := range tags {
+		selector, err := ParseTagSelector(tag)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO maintenance_window_tags (window_id, key, value) VALUES (?, ?, ?)", windowID, selector.Key, selector.Value)
+		if err != nil {
+			logger.Error("Failed to add tag %s to maintenance window %d: %v", tag, windowID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// windowRecurrence returns the recurrence of a window evaluated in its timezone, or nil for one-off windows
+func windowRecurrence(window models.MaintenanceWindow) (schedule.Recurrence, *time.Location) {
+	loc, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if window.Recurrence == "" {
+		return nil, loc
+	}
+	recurrence, err := schedule.Parse(window.Recurrence, window.StartsAt.In(loc))
+	if err != nil {
+		return nil, loc
+	}
+	return recurrence, loc
+}
+
+// windowActive reports whether a maintenance window covers time t. Recurring windows are
+// active for their duration after each occurrence, starting no earlier than StartsAt.
+func windowActive(window models.MaintenanceWindow, t time.Time) bool {
+	duration := time.Duration(window.Duration) * time.Minute
+	recurrence, loc := windowRecurrence(window)
+	if recurrence == nil {
+		if window.Recurrence != "" {
+			return false
+		}
+		return !t.Before(window.StartsAt) && t.Before(window.StartsAt.Add(duration))
+	}
+
+	after := t.Add(-duration)
+	if after.Before(window.StartsAt) {
+		after = window.StartsAt
+	}
+	occurrence, ok := recurrence.Previous(t.In(loc), after.In(loc))
+	return ok && t.Before(occurrence.Add(duration))
+}
+
+// nextWindowStart returns the next time a maintenance window starts after now
+func nextWindowStart(window models.MaintenanceWindow, now time.Time) (time.Time, bool) {
+	recurrence, loc := windowRecurrence(window)
+	if recurrence == nil {
+		if window.Recurrence == "" && window.StartsAt.After(now) {
+			return window.StartsAt, true
+		}
+		return time.Time{}, false
+	}
+
+	from := now
+	if window.StartsAt.After(from) {
+		from = window.StartsAt.Add(-time.Nanosecond)
+	}
+	return recurrence.Next(from.In(loc))
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+func TestWindowActive(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// Nightly from 23:00 to 01:00 Berlin time, and Mondays from 22:00 to 01:00 UTC
+	nightly := models.MaintenanceWindow{
+		StartsAt:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Duration:   120,
+		Recurrence: "0 23 * * *",
+		Timezone:   "Europe/Berlin",
+	}
+	weekly := models.MaintenanceWindow{
+		StartsAt:   time.Date(2026, 10, 5, 22, 0, 0, 0, time.UTC),
+		Duration:   180,
+		Recurrence: "RRULE:FREQ=WEEKLY;BYDAY=MO",
+		Timezone:   "UTC",
+	}
+	lateStart := nightly
+	lateStart.StartsAt = time.Date(2026, 10, 10, 23, 30, 0, 0, berlin)
+	oneOff := models.MaintenanceWindow{
+		StartsAt: time.Date(2026, 10, 10, 23, 30, 0, 0, time.UTC),
+		Duration: 60,
+		Timezone: "UTC",
+	}
+	invalid := weekly
+	invalid.Recurrence = "FREQ=YEARLY"
+
+	tests := []struct {
+		name   string
+		window models.MaintenanceWindow
+		at     time.Time
+		active bool
+	}{
+		{"before a nightly occurrence", nightly, time.Date(2026, 10, 10, 22, 59, 0, 0, berlin), false},
+		{"nightly before midnight", nightly, time.Date(2026, 10, 10, 23, 30, 0, 0, berlin), true},
+		{"nightly after midnight", nightly, time.Date(2026, 10, 11, 0, 59, 0, 0, berlin), true},
+		{"nightly end", nightly, time.Date(2026, 10, 11, 1, 0, 0, 0, berlin), false},
+		{"nightly on the night daylight saving ends", nightly, time.Date(2026, 10, 24, 22, 30, 0, 0, time.UTC), true},
+		{"nightly end in the repeated hour", nightly, time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), false},
+		{"weekly after midnight", weekly, time.Date(2026, 10, 13, 0, 30, 0, 0, time.UTC), true},
+		{"weekly end", weekly, time.Date(2026, 10, 13, 1, 0, 0, 0, time.UTC), false},
+		{"weekly on another day", weekly, time.Date(2026, 10, 11, 23, 0, 0, 0, time.UTC), false},
+		{"occurrence before the start", lateStart, time.Date(2026, 10, 11, 0, 30, 0, 0, berlin), false},
+		{"first occurrence after the start", lateStart, time.Date(2026, 10, 12, 0, 30, 0, 0, berlin), true},
+		{"one-off after midnight", oneOff, time.Date(2026, 10, 11, 0, 29, 0, 0, time.UTC), true},
+		{"one-off end", oneOff, time.Date(2026, 10, 11, 0, 30, 0, 0, time.UTC), false},
+		{"unparsable recurrence", invalid, time.Date(2026, 10, 12, 23, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if active := windowActive(test.window, test.at); active != test.active {
+				t.Errorf("windowActive(%v) = %v, want %v", test.at, active, test.active)
+			}
+		})
+	}
+}
+
+func TestNextWindowStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	nightly := models.MaintenanceWindow{
+		StartsAt:   time.Date(2026, 10, 10, 23, 30, 0, 0, berlin),
+		Duration:   120,
+		Recurrence: "0 23 * * *",
+		Timezone:   "Europe/Berlin",
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before the start", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 11, 23, 0, 0, 0, berlin)},
+		{"during an occurrence", time.Date(2026, 10, 12, 0, 30, 0, 0, berlin), time.Date(2026, 10, 12, 23, 0, 0, 0, berlin)},
+		{"after daylight saving ends", time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), time.Date(2026, 10, 25, 22, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := nextWindowStart(nightly, test.now)
+			if !ok || !got.Equal(test.want) {
+				t.Errorf("nextWindowStart(%v) = %v, %v, want %v", test.now, got, ok, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,835 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/utils"
+)
+
+const (
+	// notificationPollInterval is how often the delivery queue is checked for due retries
+	notificationPollInterval = 5 * time.Second
+
+	// notificationTimeout limits how long a single delivery attempt may take
+	notificationTimeout = 30 * time.Second
+
+	// notificationBatchSize limits how many deliveries are attempted per poll
+	notificationBatchSize = 100
+
+	// notificationWorkers limits the number of channels sent to in parallel per poll
+	notificationWorkers = 5
+)
+
+// ErrInvalidChannel is returned when a notification channel has an invalid type, settings or binding
+var ErrInvalidChannel = errors.New("invalid notification channel")
+
+// NotificationService manages notification channels and delivers notifications to them
+// through a queue, retrying failed deliveries with exponential backoff
+type NotificationService struct {
+	db     *sqlx.DB
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewNotificationService creates a new notification service instance
+func NewNotificationService(db *sqlx.DB) *NotificationService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &NotificationService{
+		db:     db,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins delivering queued notifications
+func (s *NotificationService) Start() {
+	logger.Info("Starting notification dispatcher")
+	go s.deliveryLoop()
+}
+
+// Stop stops delivering queued notifications, pending deliveries are sent after a restart
+func (s *NotificationService) Stop() {
+	logger.Info("Stopping notification dispatcher")
+	s.cancel()
+}
+
+// deliveryLoop delivers due notifications on every interval and whenever new ones are queued
+func (s *NotificationService) deliveryLoop() {
+	ticker := time.NewTicker(notificationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		if err := s.DeliverDue(time.Now().UTC()); err != nil {
+			logger.Error("Failed to deliver notifications: %v", err)
+		}
+	}
+}
+
+// Notify queues a notification for every enabled channel bound to any of the servers and
+// subscribed to its event. Failures are logged rather than returned so that notification
+// problems never interrupt the checks and alerts raising them.
+func (s *NotificationService) Notify(notification models.Notification, serverIDs ...int) {
+	if len(serverIDs) == 0 {
+		return
+	}
+
+	channels, err := s.boundChannels(serverIDs, notification.Event)
+	if err != nil {
+		logger.Error("Failed to get channels for notification %s: %v", notification.Event, err)
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	if notification.ID == "" {
+		if notification.ID, err = newEventID(); err != nil {
+			logger.Error("Failed to generate ID for notification %s: %v", notification.Event, err)
+			return
+		}
+	}
+	for _, channel := range channels {
+		if _, err := s.enqueue(channel.ID, notification, true); err != nil {
+			logger.Error("Failed to queue notification %s for channel %d: %v", notification.Event, channel.ID, err)
+		}
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// boundChannels returns the enabled channels bound to any of the servers directly or by tag
+// that are subscribed to an event, explicitly or through the default events
+func (s *NotificationService) boundChannels(serverIDs []int, event string) ([]models.NotificationChannel, error) {
+	query, args, err := sqlx.In(`
+		SELECT * FROM notification_channels
+		WHERE enabled = 1 AND id IN (
+			SELECT channel_id FROM notification_bindings WHERE server_id IN (?)
+			UNION
+			SELECT b.channel_id FROM notification_bindings b
+			JOIN server_tags t ON t.key = b.tag_key AND (b.tag_value = '' OR b.tag_value = t.value)
+			WHERE t.server_id IN (?)
+		) AND (
+			id IN (SELECT channel_id FROM notification_channel_events WHERE event = ?)
+			OR (? AND id NOT IN (SELECT channel_id FROM notification_channel_events))
+		)
+		ORDER BY id
+	`, serverIDs, serverIDs, event, slices.Contains(models.DefaultNotificationEvents, event))
+	if err != nil {
+		return nil, err
+	}
+
+	channels := []models.NotificationChannel{}
+	if err := s.db.Select(&channels, query, args...); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// enqueue stores a delivery of a notification to a channel. Queued deliveries are picked up
+// by the delivery loop, others are attempted by the caller.
+func (s *NotificationService) enqueue(channelID int, notification models.Notification, queued bool) (*models.NotificationDelivery, error) {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	delivery := &models.NotificationDelivery{
+		ChannelID: channelID,
+		Event:     notification.Event,
+		Title:     notification.Title,
+		Payload:   string(payload),
+		Status:    models.DeliveryPending,
+		CreatedAt: now,
+	}
+	if queued {
+		delivery.NextAttemptAt = &now
+	}
+
+	result, err := s.db.NamedExec(`
+		INSERT INTO notification_deliveries (channel_id, event, title, payload, status, attempts, next_attempt_at, last_error, created_at)
+		VALUES (:channel_id, :event, :title, :payload, :status, :attempts, :next_attempt_at, :last_error, :created_at)
+	`, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	delivery.ID = int(id)
+	return delivery, nil
+}
+
+// DeliverDue attempts the pending deliveries whose next attempt is at or before now
+func (s *NotificationService) DeliverDue(now time.Time) error {
+	now = now.UTC()
+	due := []models.NotificationDelivery{}
+	err := s.db.Select(&due, `
+		SELECT * FROM notification_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id LIMIT ?
+	`, models.DeliveryPending, now, notificationBatchSize)
+	if err != nil {
+		logger.Error("Failed to get due notification deliveries: %v", err)
+		return err
+	}
+
+	var channelIDs []int
+	byChannel := make(map[int][]*models.NotificationDelivery)
+	for i := range due {
+		if _, ok := byChannel[due[i].ChannelID]; !ok {
+			channelIDs = append(channelIDs, due[i].ChannelID)
+		}
+		byChannel[due[i].ChannelID] = append(byChannel[due[i].ChannelID], &due[i])
+	}
+
+	// Channels are sent to concurrently so that a slow or unreachable one does not hold up the
+	// others, while the deliveries of each channel are still attempted in order
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, notificationWorkers)
+	for _, channelID := range channelIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(channelID int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.deliverChannel(channelID, byChannel[channelID], now); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(channelID)
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// deliverChannel attempts the due deliveries of one channel, as a batch for batching channels
+func (s *NotificationService) deliverChannel(channelID int, deliveries []*models.NotificationDelivery, now time.Time) error {
+	channel, err := s.getChannel(channelID)
+	if err != nil {
+		return err
+	}
+
+	if channel != nil && channel.Enabled {
+		if notifier, err := newNotifier(channel.Type, channel.Config); err == nil {
+			if batch, ok := notifier.(BatchNotifier); ok && batch.BatchWindow() > 0 {
+				return s.attemptBatch(deliveries, batch, now)
+			}
+		}
+	}
+
+	for _, delivery := range deliveries {
+		if err := s.attempt(delivery, channel, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attemptBatch sends the due deliveries of a batching channel as one message once the window
+// of the oldest new delivery has passed, so that notifications arriving within the window are
+// combined. Retries of a failed batch are sent together again.
+func (s *NotificationService) attemptBatch(deliveries []*models.NotificationDelivery, notifier BatchNotifier, now time.Time) error {
+	for _, delivery := range deliveries {
+		if delivery.Attempts == 0 && now.Sub(delivery.CreatedAt) < notifier.BatchWindow() {
+			return nil
+		}
+	}
+
+	notifications := make([]models.Notification, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		var notification models.Notification
+		if err := json.Unmarshal([]byte(delivery.Payload), &notification); err != nil {
+			return err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, notificationTimeout)
+	defer cancel()
+	sendErr := notifier.SendBatch(ctx, notifications)
+	for _, delivery := range deliveries {
+		delivery.ResponseStatus = nil
+		if responder, ok := notifier.(ResponseNotifier); ok {
+			delivery.ResponseStatus = responder.ResponseStatus()
+		}
+		if err := s.record(delivery, sendErr, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends a delivery to its channel and records the outcome
+func (s *NotificationService) attempt(delivery *models.NotificationDelivery, channel *models.NotificationChannel, retry bool) error {
+	var sendErr error
+	delivery.ResponseStatus = nil
+	switch {
+	case channel == nil:
+		sendErr = errors.New("channel no longer exists")
+		retry = false
+	case !channel.Enabled:
+		sendErr = errors.New("channel is disabled")
+		retry = false
+	default:
+		sendErr = s.send(delivery, channel)
+	}
+	return s.record(delivery, sendErr, retry)
+}
+
+// record stores the outcome of a delivery attempt. A failed delivery is scheduled for another
+// attempt with exponential backoff while retry is set and attempts remain, and marked as
+// failed otherwise.
+func (s *NotificationService) record(delivery *models.NotificationDelivery, sendErr error, retry bool) error {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.NextAttemptAt = nil
+	if sendErr == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = sendErr.Error()
+		if retry && delivery.Attempts < config.NotificationMaxAttempts {
+			next := now.Add(config.NotificationRetryDelay << (delivery.Attempts - 1))
+			delivery.NextAttemptAt = &next
+		} else {
+			delivery.Status = models.DeliveryFailed
+		}
+		logger.Error("Failed to deliver notification %d to channel %d (attempt %d): %v", delivery.ID, delivery.ChannelID, delivery.Attempts, sendErr)
+	}
+
+	_, err := s.db.NamedExec(`
+		UPDATE notification_deliveries
+		SET status = :status, attempts = :attempts, next_attempt_at = :next_attempt_at, last_error = :last_error,
+			response_status = :response_status, delivered_at = :delivered_at
+		WHERE id = :id
+	`, delivery)
+	if err != nil {
+		logger.Error("Failed to record notification delivery %d: %v", delivery.ID, err)
+		return err
+	}
+	return nil
+}
+
+// send delivers the notification of a delivery through the notifier of a channel, noting the
+// response status on the delivery for channels delivering over HTTP
+func (s *NotificationService) send(delivery *models.NotificationDelivery, channel *models.NotificationChannel) error {
+	var notification models.Notification
+	if err := json.Unmarshal([]byte(delivery.Payload), &notification); err != nil {
+		return err
+	}
+
+	notifier, err := newNotifier(channel.Type, channel.Config)
+	if err != nil {
+		return err
+	}
+	if responder, ok := notifier.(ResponseNotifier); ok {
+		defer func() { delivery.ResponseStatus = responder.ResponseStatus() }()
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, notificationTimeout)
+	defer cancel()
+
+	threaded, ok := notifier.(ThreadedNotifier)
+	if !ok || notification.Incident == nil {
+		return notifier.Send(ctx, notification)
+	}
+
+	threads := []string{}
+	err = s.db.Select(&threads, "SELECT thread_id FROM notification_threads WHERE channel_id = ? AND incident_id = ?", channel.ID, notification.Incident.ID)
+	if err != nil {
+		return err
+	}
+	var threadID string
+	if len(threads) > 0 {
+		threadID = threads[0]
+	}
+
+	started, err := threaded.SendThreaded(ctx, notification, threadID)
+	if err != nil {
+		return err
+	}
+	if threadID == "" && started != "" {
+		_, err := s.db.Exec("INSERT OR IGNORE INTO notification_threads (channel_id, incident_id, thread_id) VALUES (?, ?, ?)", channel.ID, notification.Incident.ID, started)
+		if err != nil {
+			logger.Error("Failed to record thread of incident %d in channel %d: %v", notification.Incident.ID, channel.ID, err)
+		}
+	}
+	return nil
+}
+
+// SendTest sends a test notification to a channel immediately, without retries, and returns
+// the recorded delivery. It returns nil if the channel does not exist.
+func (s *NotificationService) SendTest(id int) (*models.NotificationDelivery, error) {
+	channel, err := s.getChannel(id)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, nil
+	}
+
+	eventID, err := newEventID()
+	if err != nil {
+		return nil, err
+	}
+	notification := models.Notification{
+		ID:      eventID,
+		Event:   models.NotifyTest,
+		Level:   models.LevelInfo,
+		Title:   "Test notification",
+		Message: fmt.Sprintf("This is a test notification sent to channel %q.", channel.Name),
+		At:      time.Now().UTC(),
+	}
+	delivery, err := s.enqueue(channel.ID, notification, false)
+	if err != nil {
+		logger.Error("Failed to record test notification for channel %d: %v", id, err)
+		return nil, err
+	}
+	// Disabled channels can still be tested
+	channel.Enabled = true
+	if err := s.attempt(delivery, channel, false); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// GetDeliveries returns the deliveries matching a filter, newest first
+func (s *NotificationService) GetDeliveries(filter models.NotificationDeliveryFilter) ([]models.NotificationDelivery, error) {
+	deliveries := []models.NotificationDelivery{}
+	err := s.db.Select(&deliveries, `
+		SELECT * FROM notification_deliveries
+		WHERE (? IS NULL OR channel_id = ?) AND (? = '' OR status = ?)
+		ORDER BY created_at DESC, id DESC LIMIT ?
+	`, filter.ChannelID, filter.ChannelID, filter.Status, filter.Status, filter.Limit)
+	if err != nil {
+		logger.Error("Failed to get notification deliveries: %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// CreateChannel validates and stores a notification channel along with its bindings
+func (s *NotificationService) CreateChannel(req models.CreateNotificationChannelRequest) (*models.NotificationChannel, error) {
+	now := time.Now().UTC()
+	channel := &models.NotificationChannel{
+		Type:      req.Type,
+		Name:      req.Name,
+		Config:    req.Config,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ServerIDs: uniqueIDs(req.ServerIDs),
+		Tags:      uniqueStrings(req.Tags),
+		Events:    uniqueStrings(req.Events),
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+	if err := s.validateChannel(channel); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO notification_channels (type, name, config, enabled, created_at, updated_at)
+		VALUES (:type, :name, :config, :enabled, :created_at, :updated_at)
+	`, channel)
+	if err != nil {
+		logger.Error("Failed to create notification channel: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	channel.ID = int(id)
+
+	if err := setChannelBindings(tx, channel); err != nil {
+		return nil, err
+	}
+	if err := setChannelEvents(tx, channel); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := redactChannel(channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// GetChannels returns all notification channels with their bindings, secrets masked
+func (s *NotificationService) GetChannels() ([]models.NotificationChannel, error) {
+	channels := []models.NotificationChannel{}
+	if err := s.db.Select(&channels, "SELECT * FROM notification_channels ORDER BY id"); err != nil {
+		logger.Error("Failed to get notification channels: %v", err)
+		return nil, err
+	}
+
+	bindings := []notificationBinding{}
+	if err := s.db.Select(&bindings, "SELECT * FROM notification_bindings ORDER BY server_id, tag_key, tag_value"); err != nil {
+		logger.Error("Failed to get notification bindings: %v", err)
+		return nil, err
+	}
+
+	subscriptions := []channelEvent{}
+	if err := s.db.Select(&subscriptions, "SELECT * FROM notification_channel_events ORDER BY event"); err != nil {
+		logger.Error("Failed to get notification channel events: %v", err)
+		return nil, err
+	}
+
+	byChannel := make(map[int][]notificationBinding)
+	for _, binding := range bindings {
+		byChannel[binding.ChannelID] = append(byChannel[binding.ChannelID], binding)
+	}
+	events := make(map[int][]string)
+	for _, subscription := range subscriptions {
+		events[subscription.ChannelID] = append(events[subscription.ChannelID], subscription.Event)
+	}
+	for i := range channels {
+		if err := decryptChannel(&channels[i]); err != nil {
+			return nil, err
+		}
+		applyBindings(&channels[i], byChannel[channels[i].ID])
+		channels[i].Events = append([]string{}, events[channels[i].ID]...)
+		if err := redactChannel(&channels[i]); err != nil {
+			return nil, err
+		}
+	}
+	return channels, nil
+}
+
+// GetChannelByID returns a notification channel by its ID with its secrets masked
+func (s *NotificationService) GetChannelByID(id int) (*models.NotificationChannel, error) {
+	channel, err := s.getChannel(id)
+	if err != nil || channel == nil {
+		return nil, err
+	}
+	if err := redactChannel(channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// getChannel returns a notification channel by its ID with its secrets
+func (s *NotificationService) getChannel(id int) (*models.NotificationChannel, error) {
+	var channel models.NotificationChannel
+	err := s.db.Get(&channel, "SELECT * FROM notification_channels WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get notification channel %d: %v", id, err)
+		return nil, err
+	}
+	if err := decryptChannel(&channel); err != nil {
+		return nil, err
+	}
+
+	bindings := []notificationBinding{}
+	err = s.db.Select(&bindings, "SELECT * FROM notification_bindings WHERE channel_id = ? ORDER BY server_id, tag_key, tag_value", id)
+	if err != nil {
+		logger.Error("Failed to get bindings of notification channel %d: %v", id, err)
+		return nil, err
+	}
+	applyBindings(&channel, bindings)
+
+	channel.Events = []string{}
+	err = s.db.Select(&channel.Events, "SELECT event FROM notification_channel_events WHERE channel_id = ? ORDER BY event", id)
+	if err != nil {
+		logger.Error("Failed to get events of notification channel %d: %v", id, err)
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// UpdateChannel updates a notification channel, replacing its settings, bindings and events
+// when present. Masked secrets in new settings keep their stored value.
+func (s *NotificationService) UpdateChannel(id int, req models.UpdateNotificationChannelRequest) (*models.NotificationChannel, error) {
+	channel, err := s.getChannel(id)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, nil
+	}
+
+	if req.Name != nil {
+		channel.Name = *req.Name
+	}
+	if req.Config != nil {
+		config, err := restoreSecrets(channel.Type, req.Config, channel.Config)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+		}
+		channel.Config = config
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+	if req.ServerIDs != nil {
+		channel.ServerIDs = uniqueIDs(req.ServerIDs)
+	}
+	if req.Tags != nil {
+		channel.Tags = uniqueStrings(req.Tags)
+	}
+	if req.Events != nil {
+		channel.Events = uniqueStrings(req.Events)
+	}
+	if err := s.validateChannel(channel); err != nil {
+		return nil, err
+	}
+	channel.UpdatedAt = time.Now().UTC()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(`
+		UPDATE notification_channels
+		SET name = :name, config = :config, enabled = :enabled, updated_at = :updated_at
+		WHERE id = :id
+	`, channel)
+	if err != nil {
+		logger.Error("Failed to update notification channel %d: %v", id, err)
+		return nil, err
+	}
+
+	if err := setChannelBindings(tx, channel); err != nil {
+		return nil, err
+	}
+	if err := setChannelEvents(tx, channel); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := redactChannel(channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// DeleteChannel deletes a notification channel along with its bindings, events, threads and delivery log
+func (s *NotificationService) DeleteChannel(id int) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM notification_threads WHERE channel_id = ?", id); err != nil {
+		logger.Error("Failed to delete threads of notification channel %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_deliveries WHERE channel_id = ?", id); err != nil {
+		logger.Error("Failed to delete deliveries of notification channel %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_bindings WHERE channel_id = ?", id); err != nil {
+		logger.Error("Failed to delete bindings of notification channel %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_channel_events WHERE channel_id = ?", id); err != nil {
+		logger.Error("Failed to delete events of notification channel %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_channels WHERE id = ?", id); err != nil {
+		logger.Error("Failed to delete notification channel %d: %v", id, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// validateChannel checks the type, settings, bindings and events of a channel and encrypts its settings
+func (s *NotificationService) validateChannel(channel *models.NotificationChannel) error {
+	if len(channel.Config) == 0 || string(channel.Config) == "null" {
+		channel.Config = json.RawMessage("{}")
+	}
+	if _, err := newNotifier(channel.Type, channel.Config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+	}
+
+	for _, tag := range channel.Tags {
+		if _, err := ParseTagSelector(tag); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+		}
+	}
+
+	for _, event := range channel.Events {
+		if !slices.Contains(models.NotificationEvents, event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidChannel, event)
+		}
+	}
+
+	if len(channel.ServerIDs) > 0 {
+		query, args, err := sqlx.In("SELECT id FROM servers WHERE id IN (?)", channel.ServerIDs)
+		if err != nil {
+			return err
+		}
+		existing := []int{}
+		if err := s.db.Select(&existing, query, args...); err != nil {
+			return err
+		}
+		found := make(map[int]bool)
+		for _, id := range existing {
+			found[id] = true
+		}
+		for _, id := range channel.ServerIDs {
+			if !found[id] {
+				return fmt.Errorf("%w: %d", ErrUnknownServer, id)
+			}
+		}
+	}
+
+	encrypted, err := utils.Encrypt(channel.Config)
+	if err != nil {
+		return err
+	}
+	channel.EncryptedConfig = encrypted
+	return nil
+}
+
+// notificationBinding binds a channel to a server, or to the servers matching a tag
+type notificationBinding struct {
+	ChannelID int     `db:"channel_id"`
+	ServerID  *int    `db:"server_id"`
+	TagKey    *string `db:"tag_key"`
+	TagValue  *string `db:"tag_value"`
+}
+
+// applyBindings sets the server and tag bindings of a channel
+func applyBindings(channel *models.NotificationChannel, bindings []notificationBinding) {
+	channel.ServerIDs = []int{}
+	channel.Tags = []string{}
+	for _, binding := range bindings {
+		switch {
+		case binding.ServerID != nil:
+			channel.ServerIDs = append(channel.ServerIDs, *binding.ServerID)
+		case binding.TagKey != nil:
+			tag := *binding.TagKey
+			if binding.TagValue != nil && *binding.TagValue != "" {
+				tag += ":" + *binding.TagValue
+			}
+			channel.Tags = append(channel.Tags, tag)
+		}
+	}
+}
+
+// setChannelBindings replaces the bindings of a channel
+func setChannelBindings(tx *sqlx.Tx, channel *models.NotificationChannel) error {
+	if _, err := tx.Exec("DELETE FROM notification_bindings WHERE channel_id = ?", channel.ID); err != nil {
+		logger.Error("Failed to clear bindings of notification channel %d: %v", channel.ID, err)
+		return err
+	}
+	for _, serverID := range channel.ServerIDs {
+		if _, err := tx.Exec("INSERT INTO notification_bindings (channel_id, server_id) VALUES (?, ?)", channel.ID, serverID); err != nil {
+			logger.Error("Failed to bind notification channel %d to server %d: %v", channel.ID, serverID, err)
+			return err
+		}
+	}
+	for _, tag := range channel.Tags {
+		selector, _ := ParseTagSelector(tag)
+		if _, err := tx.Exec("INSERT INTO notification_bindings (channel_id, tag_key, tag_value) VALUES (?, ?, ?)", channel.ID, selector.Key, selector.Value); err != nil {
+			logger.Error("Failed to bind notification channel %d to tag %s: %v", channel.ID, tag, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// channelEvent subscribes a channel to an event
+type channelEvent struct {
+	ChannelID int    `db:"channel_id"`
+	Event     string `db:"event"`
+}
+
+// setChannelEvents replaces the events a channel is subscribed to
+func setChannelEvents(tx *sqlx.Tx, channel *models.NotificationChannel) error {
+	if _, err := tx.Exec("DELETE FROM notification_channel_events WHERE channel_id = ?", channel.ID); err != nil {
+		logger.Error("Failed to clear events of notification channel %d: %v", channel.ID, err)
+		return err
+	}
+	for _, event := range channel.Events {
+		if _, err := tx.Exec("INSERT INTO notification_channel_events (channel_id, event) VALUES (?, ?)", channel.ID, event); err != nil {
+			logger.Error("Failed to subscribe notification channel %d to %s: %v", channel.ID, event, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// newEventID returns a random ID identifying a notification across its deliveries
+func newEventID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// redactChannel masks the secret settings of a channel before it is returned by the API
+func redactChannel(channel *models.NotificationChannel) error {
+	config, err := redactSecrets(channel.Type, channel.Config)
+	if err != nil {
+		logger.Error("Failed to redact config of notification channel %d: %v", channel.ID, err)
+		return err
+	}
+	channel.Config = config
+	return nil
+}
+
+// decryptChannel decrypts the settings of a channel loaded from the database
+func decryptChannel(channel *models.NotificationChannel) error {
+	config, err := utils.Decrypt(channel.EncryptedConfig)
+	if err != nil {
+		logger.Error("Failed to decrypt config of notification channel %d: %v", channel.ID, err)
+		return err
+	}
+	channel.Config = config
+	return nil
+}
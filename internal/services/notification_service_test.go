@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// newTestNotificationService creates a notification service with one server to bind
+// channels to, and returns the ID of that server
+func newTestNotificationService(t *testing.T) (*NotificationService, int) {
+	t.Helper()
+
+	useTestEncryptionKey(t)
+	db := newTestDB(t)
+	server := createTestServer(t, NewServerService(db))
+	return NewNotificationService(db), server.ID
+}
+
+// createTestChannel creates a channel bound to a server
+func createTestChannel(t *testing.T, notifications *NotificationService, channelType string, settings interface{}, serverID int) *models.NotificationChannel {
+	t.Helper()
+
+	config, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("encode settings: %v", err)
+	}
+	channel, err := notifications.CreateChannel(models.CreateNotificationChannelRequest{
+		Type:      channelType,
+		Name:      channelType,
+		Config:    config,
+		ServerIDs: []int{serverID},
+	})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	return channel
+}
+
+// incidentNotification returns a notification about an incident
+func incidentNotification(event string, incidentID int, title string) models.Notification {
+	return models.Notification{
+		Event:    event,
+		Level:    models.LevelCritical,
+		Title:    title,
+		Message:  "connection refused",
+		Incident: &models.NotificationIncident{ID: incidentID, Title: title, Status: models.IncidentInvestigating},
+		At:       time.Now().UTC(),
+	}
+}
+
+// channelDeliveries returns the deliveries of a channel, oldest first
+func channelDeliveries(t *testing.T, notifications *NotificationService, channelID int) []models.NotificationDelivery {
+	t.Helper()
+
+	deliveries, err := notifications.GetDeliveries(models.NotificationDeliveryFilter{ChannelID: &channelID, Limit: 100})
+	if err != nil {
+		t.Fatalf("GetDeliveries: %v", err)
+	}
+	for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+		deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+	}
+	return deliveries
+}
+
+func TestDeliverDueRetriesWithBackoff(t *testing.T) {
+	notifications, serverID := newTestNotificationService(t)
+	standIn := newHTTPStandIn(t, httpResponse{503, "unavailable"}, httpResponse{200, "ok"})
+	channel := createTestChannel(t, notifications, models.ChannelWebhook, map[string]string{
+		"url":    standIn.URL,
+		"secret": "0123456789abcdef",
+	}, serverID)
+
+	notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 1, "api is down"), serverID)
+	now := time.Now().UTC()
+	if err := notifications.DeliverDue(now); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	standIn.next(t)
+
+	deliveries := channelDeliveries(t, notifications, channel.ID)
+	if len(deliveries) != 1 {
+		t.Fatalf("%d deliveries, want 1", len(deliveries))
+	}
+	failed := deliveries[0]
+	if failed.Status != models.DeliveryPending || failed.Attempts != 1 || !strings.Contains(failed.LastError, "unexpected status 503: unavailable") {
+		t.Errorf("after a failed attempt: status %s, attempts %d, error %q", failed.Status, failed.Attempts, failed.LastError)
+	}
+	if failed.ResponseStatus == nil || *failed.ResponseStatus != 503 {
+		t.Errorf("response status = %v, want 503", failed.ResponseStatus)
+	}
+	if failed.NextAttemptAt == nil || failed.NextAttemptAt.Before(now.Add(config.NotificationRetryDelay)) {
+		t.Fatalf("next attempt = %v, want at least %v after %v", failed.NextAttemptAt, config.NotificationRetryDelay, now)
+	}
+
+	if err := notifications.DeliverDue(now); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if len(standIn.requests) != 0 {
+		t.Fatal("retried before the backoff passed")
+	}
+
+	if err := notifications.DeliverDue(*failed.NextAttemptAt); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	standIn.next(t)
+
+	delivered := channelDeliveries(t, notifications, channel.ID)[0]
+	if delivered.Status != models.DeliveryDelivered || delivered.Attempts != 2 || delivered.LastError != "" || delivered.DeliveredAt == nil {
+		t.Errorf("after a retry: status %s, attempts %d, error %q, delivered at %v", delivered.Status, delivered.Attempts, delivered.LastError, delivered.DeliveredAt)
+	}
+	if delivered.ResponseStatus == nil || *delivered.ResponseStatus != 200 {
+		t.Errorf("response status = %v, want 200", delivered.ResponseStatus)
+	}
+}
+
+func TestDeliverDueFailsAfterMaxAttempts(t *testing.T) {
+	notifications, serverID := newTestNotificationService(t)
+	standIn := newHTTPStandIn(t, httpResponse{500, ""})
+	channel := createTestChannel(t, notifications, models.ChannelWebhook, map[string]string{
+		"url":    standIn.URL,
+		"secret": "0123456789abcdef",
+	}, serverID)
+
+	notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 1, "api is down"), serverID)
+	for attempt := 1; attempt <= config.NotificationMaxAttempts+1; attempt++ {
+		if err := notifications.DeliverDue(time.Now().Add(time.Duration(attempt) * 24 * time.Hour)); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+	}
+
+	if len(standIn.requests) != config.NotificationMaxAttempts {
+		t.Errorf("%d attempts, want %d", len(standIn.requests), config.NotificationMaxAttempts)
+	}
+	delivery := channelDeliveries(t, notifications, channel.ID)[0]
+	if delivery.Status != models.DeliveryFailed || delivery.Attempts != config.NotificationMaxAttempts || delivery.NextAttemptAt != nil {
+		t.Errorf("status %s, attempts %d, next attempt %v", delivery.Status, delivery.Attempts, delivery.NextAttemptAt)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != 500 || delivery.LastError != "unexpected status 500" {
+		t.Errorf("response status %v, error %q", delivery.ResponseStatus, delivery.LastError)
+	}
+}
+
+func TestDeliverDueDoesNotWaitForSlowChannels(t *testing.T) {
+	notifications, serverID := newTestNotificationService(t)
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	fast := newHTTPStandIn(t, httpResponse{200, "ok"})
+	for _, url := range []string{slow.URL, fast.URL} {
+		createTestChannel(t, notifications, models.ChannelWebhook, map[string]string{
+			"url":    url,
+			"secret": "0123456789abcdef",
+		}, serverID)
+	}
+
+	notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 1, "api is down"), serverID)
+	done := make(chan error, 1)
+	go func() { done <- notifications.DeliverDue(time.Now().UTC()) }()
+	t.Cleanup(func() {
+		close(release)
+		<-done
+	})
+
+	// The fast channel is sent to while the slow one has not answered yet
+	fast.next(t)
+	select {
+	case err := <-done:
+		t.Fatalf("DeliverDue returned before the slow channel answered: %v", err)
+	default:
+	}
+}
+
+func TestDeliverDueBatchesEmailDigest(t *testing.T) {
+	notifications, serverID := newTestNotificationService(t)
+	standIn := newSMTPStandIn(t, false)
+	channel := createTestChannel(t, notifications, models.ChannelEmail, map[string]interface{}{
+		"to":           []string{"ops@example.com"},
+		"smtp":         standIn.settings(),
+		"digestWindow": "1m",
+	}, serverID)
+
+	notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 1, "api is down"), serverID)
+	notifications.Notify(incidentNotification(models.NotifyIncidentOpened, 2, "web is down"), serverID)
+
+	now := time.Now().UTC()
+	if err := notifications.DeliverDue(now); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if len(standIn.messages) != 0 {
+		t.Fatal("sent before the digest window passed")
+	}
+	for _, delivery := range channelDeliveries(t, notifications, channel.ID) {
+		if delivery.Status != models.DeliveryPending || delivery.Attempts != 0 {
+			t.Errorf("delivery %d within the window: status %s, attempts %d", delivery.ID, delivery.Status, delivery.Attempts)
+		}
+	}
+
+	if err := notifications.DeliverDue(now.Add(2 * time.Minute)); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	var message smtpMessage
+	select {
+	case message = <-standIn.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+	if !strings.Contains(message.Data, "Subject: 2 monitoring alerts: api is down and 1 more") {
+		t.Errorf("digest subject missing from message:\n%s", message.Data)
+	}
+	if !strings.Contains(message.Data, "web is down") {
+		t.Errorf("second notification missing from digest:\n%s", message.Data)
+	}
+	if len(standIn.messages) != 0 {
+		t.Error("notifications of the digest were also sent on their own")
+	}
+
+	for _, delivery := range channelDeliveries(t, notifications, channel.ID) {
+		if delivery.Status != models.DeliveryDelivered || delivery.Attempts != 1 {
+			t.Errorf("delivery %d after the digest: status %s, attempts %d", delivery.ID, delivery.Status, delivery.Attempts)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// Notifier delivers notifications to one notification channel
+type Notifier interface {
+	Send(ctx context.Context, notification models.Notification) error
+}
+
+// BatchNotifier is a notifier that combines the notifications queued for its channel within
+// a window into a single message, so that a burst of failures is sent as one digest
+type BatchNotifier interface {
+	Notifier
+	BatchWindow() time.Duration
+	SendBatch(ctx context.Context, notifications []models.Notification) error
+}
+
+// ThreadedNotifier is a notifier that can post follow-ups about an incident, such as reminders
+// and the recovery, as replies to the message that opened it
+type ThreadedNotifier interface {
+	Notifier
+	// SendThreaded sends a notification as a reply to threadID if it is set, and returns the
+	// ID of the thread started by the notification, or an empty ID if none was started
+	SendThreaded(ctx context.Context, notification models.Notification, threadID string) (string, error)
+}
+
+// ResponseNotifier is a notifier delivering over HTTP, the status code of its last response is
+// recorded with the delivery
+type ResponseNotifier interface {
+	Notifier
+	ResponseStatus() *int
+}
+
+// notifierFactory builds the notifier of a channel from its settings, rejecting invalid settings
+type notifierFactory func(config json.RawMessage) (Notifier, error)
+
+// notifierFactories are the supported notification channel types
+var notifierFactories = map[string]notifierFactory{
+	models.ChannelLog:        newLogNotifier,
+	models.ChannelEmail:      newEmailNotifier,
+	models.ChannelSlack:      newSlackNotifier,
+	models.ChannelTeams:      newTeamsNotifier,
+	models.ChannelDiscord:    newDiscordNotifier,
+	models.ChannelMattermost: newMattermostNotifier,
+	models.ChannelWebhook:    newWebhookNotifier,
+}
+
+// secretMask replaces the secret settings of channels in API responses. Sending it back in an
+// update keeps the stored secret.
+const secretMask = "********"
+
+// notifierSecrets are the paths of the secret settings of each channel type, "*" matching every
+// key of an object
+var notifierSecrets = map[string][][]string{
+	models.ChannelEmail:      {{"smtp", "password"}},
+	models.ChannelSlack:      {{"webhookUrl"}, {"token"}},
+	models.ChannelTeams:      {{"webhookUrl"}},
+	models.ChannelDiscord:    {{"webhookUrl"}},
+	models.ChannelMattermost: {{"webhookUrl"}, {"token"}},
+	models.ChannelWebhook:    {{"secret"}, {"headers", "*"}},
+}
+
+// redactSecrets returns the settings of a channel with every secret replaced by the mask
+func redactSecrets(channelType string, config json.RawMessage) (json.RawMessage, error) {
+	return rewriteSecrets(channelType, config, func(path []string, value string) string {
+		if value == "" {
+			return value
+		}
+		return secretMask
+	})
+}
+
+// restoreSecrets returns new settings of a channel with every masked secret replaced by the
+// secret stored at the same path
+func restoreSecrets(channelType string, config, stored json.RawMessage) (json.RawMessage, error) {
+	var previous interface{}
+	if err := decodeSettings(stored, &previous); err != nil {
+		return nil, err
+	}
+	return rewriteSecrets(channelType, config, func(path []string, value string) string {
+		if value != secretMask {
+			return value
+		}
+		current := previous
+		for _, key := range path {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return value
+			}
+			current = object[key]
+		}
+		if secret, ok := current.(string); ok {
+			return secret
+		}
+		return value
+	})
+}
+
+// rewriteSecrets replaces the string secrets of channel settings with the result of rewrite,
+// which receives the full path of each secret
+func rewriteSecrets(channelType string, config json.RawMessage, rewrite func(path []string, value string) string) (json.RawMessage, error) {
+	secrets := notifierSecrets[channelType]
+	if len(secrets) == 0 || len(config) == 0 {
+		return config, nil
+	}
+
+	var settings interface{}
+	if err := decodeSettings(config, &settings); err != nil {
+		return nil, err
+	}
+	for _, pattern := range secrets {
+		rewriteSecret(settings, pattern, nil, rewrite)
+	}
+	return json.Marshal(settings)
+}
+
+// rewriteSecret follows a secret path pattern through decoded settings
+func rewriteSecret(value interface{}, pattern, path []string, rewrite func(path []string, value string) string) {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(pattern) == 0 {
+		return
+	}
+
+	keys := []string{pattern[0]}
+	if pattern[0] == "*" {
+		keys = keys[:0]
+		for key := range object {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		child, exists := object[key]
+		if !exists {
+			continue
+		}
+		childPath := append(append([]string{}, path...), key)
+		if len(pattern) > 1 {
+			rewriteSecret(child, pattern[1:], childPath, rewrite)
+		} else if secret, ok := child.(string); ok {
+			object[key] = rewrite(childPath, secret)
+		}
+	}
+}
+
+// decodeSettings decodes channel settings generically, keeping numbers as they were written
+func decodeSettings(config json.RawMessage, settings *interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	if err := decoder.Decode(settings); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+// newNotifier builds the notifier of a channel
+func newNotifier(channelType string, config json.RawMessage) (Notifier, error) {
+	factory, ok := notifierFactories[channelType]
+	if !ok {
+		return nil, fmt.Errorf("unknown channel type %q", channelType)
+	}
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	return factory(config)
+}
+
+// decodeNotifierConfig decodes the settings of a channel, rejecting unknown fields
+func decodeNotifierConfig(config json.RawMessage, settings interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(settings); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+// logNotifier writes notifications to the application log, it has no settings
+type logNotifier struct{}
+
+// newLogNotifier creates a log notifier
+func newLogNotifier(config json.RawMessage) (Notifier, error) {
+	var settings struct{}
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+	return logNotifier{}, nil
+}
+
+// Send logs a notification at a level matching its severity
+func (logNotifier) Send(ctx context.Context, notification models.Notification) error {
+	switch notification.Level {
+	case models.LevelCritical, models.LevelWarning:
+		logger.Warn("Notification %s: %s: %s", notification.Event, notification.Title, notification.Message)
+	default:
+		logger.Info("Notification %s: %s: %s", notification.Event, notification.Title, notification.Message)
+	}
+	return nil
+}
@@ -0,0 +1,685 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"github.com/waltertaya/server_check_bd/internal/schedule"
+)
+
+// reportCheckInterval is how often schedules are checked for due reports, the resolution of cron expressions
+const reportCheckInterval = time.Minute
+
+// defaultReportSchedules are the cron expressions of schedules that do not configure one,
+// shortly after the period they report on has ended
+var defaultReportSchedules = map[string]string{
+	models.ReportWeekly:  "0 8 * * 1",
+	models.ReportMonthly: "0 8 1 * *",
+}
+
+// ErrInvalidReport is returned when a report has an invalid server selection
+var ErrInvalidReport = errors.New("invalid report")
+
+// reportColumns are the columns of reports without the rendered documents
+const reportColumns = "id, schedule_id, name, period, period_start, period_end, group_id, tag, delivered_at, delivery_error, created_at"
+
+// ReportService generates availability reports and emails them on a schedule
+type ReportService struct {
+	db      *sqlx.DB
+	servers *ServerService
+	history *HistoryService
+	mailer  *Mailer
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewReportService creates a new report service instance
+func NewReportService(db *sqlx.DB, servers *ServerService, history *HistoryService, mailer *Mailer) *ReportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReportService{
+		db:      db,
+		servers: servers,
+		history: history,
+		mailer:  mailer,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start begins running report schedules as they fall due
+func (s *ReportService) Start() {
+	logger.Info("Starting report scheduler")
+	go s.scheduleLoop()
+}
+
+// Stop stops running report schedules
+func (s *ReportService) Stop() {
+	logger.Info("Stopping report scheduler")
+	s.cancel()
+}
+
+// scheduleLoop runs due schedules on every interval
+func (s *ReportService) scheduleLoop() {
+	ticker := time.NewTicker(reportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunDue(time.Now().UTC()); err != nil {
+				logger.Error("Failed to run report schedules: %v", err)
+			}
+		}
+	}
+}
+
+// RunDue generates and delivers the reports of schedules whose next run is at or before now.
+// The next run of a schedule is moved before its report is generated, so a failing schedule
+// is retried at its next run rather than on every tick, and does not hold up the others.
+func (s *ReportService) RunDue(now time.Time) error {
+	now = now.UTC()
+	due := []models.ReportSchedule{}
+	err := s.db.Select(&due, "SELECT * FROM report_schedules WHERE next_run_at <= ? ORDER BY next_run_at", now)
+	if err != nil {
+		logger.Error("Failed to get due report schedules: %v", err)
+		return err
+	}
+
+	for _, schedule := range due {
+		next := nextReportRun(schedule.Schedule, now)
+		_, err := s.db.Exec("UPDATE report_schedules SET next_run_at = ? WHERE id = ?", next, schedule.ID)
+		if err != nil {
+			logger.Error("Failed to update next run of report schedule %d: %v", schedule.ID, err)
+			return err
+		}
+
+		if err := s.attachRecipients(&schedule); err != nil {
+			continue
+		}
+		if _, err := s.run(schedule, now); err != nil {
+			logger.Error("Failed to run report schedule %d: %v", schedule.ID, err)
+		}
+	}
+	return nil
+}
+
+// RunSchedule generates and delivers the report of a schedule immediately, without moving its
+// next run. It returns nil if the schedule does not exist.
+func (s *ReportService) RunSchedule(id int) (*models.Report, error) {
+	schedule, err := s.GetScheduleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, nil
+	}
+	return s.run(*schedule, time.Now().UTC())
+}
+
+// run generates the report of a schedule, emails it to the recipients and records the run.
+// Delivery failures are recorded on the report rather than returned.
+func (s *ReportService) run(schedule models.ReportSchedule, now time.Time) (*models.Report, error) {
+	report, err := s.generate(&schedule.ID, schedule.Name, schedule.Period, schedule.GroupID, schedule.Tag, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.deliver(report, schedule.Recipients); err != nil {
+		logger.Error("Failed to deliver report %q: %v", schedule.Name, err)
+	} else {
+		logger.Info("Delivered report %q to %d recipients", schedule.Name, len(schedule.Recipients))
+	}
+
+	_, err = s.db.Exec("UPDATE report_schedules SET last_run_at = ? WHERE id = ?", now, schedule.ID)
+	if err != nil {
+		logger.Error("Failed to update last run of report schedule %d: %v", schedule.ID, err)
+		return nil, err
+	}
+	return report, nil
+}
+
+// deliver emails a report with its CSV attached and records the outcome
+func (s *ReportService) deliver(report *models.Report, recipients []string) error {
+	subject := fmt.Sprintf("%s: %s to %s", report.Name, report.From.Format("2 Jan"), report.To.AddDate(0, 0, -1).Format("2 Jan 2006"))
+	sendErr := s.mailer.Send(recipients, subject, report.HTML, Attachment{
+		Filename:    ReportFilename(*report),
+		ContentType: "text/csv; charset=utf-8",
+		Data:        []byte(report.CSV),
+	})
+
+	if sendErr != nil {
+		report.DeliveryError = sendErr.Error()
+	} else {
+		now := time.Now().UTC()
+		report.DeliveredAt = &now
+	}
+	_, err := s.db.Exec("UPDATE reports SET delivered_at = ?, delivery_error = ? WHERE id = ?", report.DeliveredAt, report.DeliveryError, report.ID)
+	if err != nil {
+		logger.Error("Failed to record delivery of report %d: %v", report.ID, err)
+		return err
+	}
+	return sendErr
+}
+
+// CreateReport generates a report for the last complete period without emailing it
+func (s *ReportService) CreateReport(req models.CreateReportRequest) (*models.Report, error) {
+	if err := s.validateSelection(req.GroupID, req.Tag); err != nil {
+		return nil, err
+	}
+	return s.generate(nil, req.Name, req.Period, req.GroupID, req.Tag, time.Now().UTC())
+}
+
+// GetReports returns all generated reports without their documents, newest first
+func (s *ReportService) GetReports() ([]models.Report, error) {
+	reports := []models.Report{}
+	err := s.db.Select(&reports, "SELECT "+reportColumns+" FROM reports ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		logger.Error("Failed to get reports: %v", err)
+		return nil, err
+	}
+	return reports, nil
+}
+
+// GetReportByID returns a report with its rendered HTML and CSV
+func (s *ReportService) GetReportByID(id int) (*models.Report, error) {
+	var report models.Report
+	err := s.db.Get(&report, "SELECT * FROM reports WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get report %d: %v", id, err)
+		return nil, err
+	}
+	return &report, nil
+}
+
+// DeleteReport deletes a generated report
+func (s *ReportService) DeleteReport(id int) error {
+	_, err := s.db.Exec("DELETE FROM reports WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete report %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// CreateSchedule validates and stores a report schedule
+func (s *ReportService) CreateSchedule(req models.CreateReportScheduleRequest) (*models.ReportSchedule, error) {
+	now := time.Now().UTC()
+	schedule := &models.ReportSchedule{
+		Name:       req.Name,
+		Period:     req.Period,
+		Schedule:   req.Schedule,
+		GroupID:    req.GroupID,
+		Tag:        req.Tag,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Recipients: uniqueStrings(req.Recipients),
+	}
+	if schedule.Schedule == "" {
+		schedule.Schedule = defaultReportSchedules[schedule.Period]
+	}
+	if err := s.validateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	schedule.NextRunAt = nextReportRun(schedule.Schedule, now)
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO report_schedules (name, period, schedule, group_id, tag, next_run_at, created_at, updated_at)
+		VALUES (:name, :period, :schedule, :group_id, :tag, :next_run_at, :created_at, :updated_at)
+	`, schedule)
+	if err != nil {
+		logger.Error("Failed to create report schedule: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = int(id)
+
+	if err := setReportRecipients(tx, schedule.ID, schedule.Recipients); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetSchedules returns all report schedules
+func (s *ReportService) GetSchedules() ([]models.ReportSchedule, error) {
+	schedules := []models.ReportSchedule{}
+	err := s.db.Select(&schedules, "SELECT * FROM report_schedules ORDER BY id")
+	if err != nil {
+		logger.Error("Failed to get report schedules: %v", err)
+		return nil, err
+	}
+
+	links := []struct {
+		ScheduleID int    `db:"schedule_id"`
+		Email      string `db:"email"`
+	}{}
+	err = s.db.Select(&links, "SELECT schedule_id, email FROM report_recipients ORDER BY email")
+	if err != nil {
+		logger.Error("Failed to get report recipients: %v", err)
+		return nil, err
+	}
+
+	recipients := make(map[int][]string)
+	for _, link := range links {
+		recipients[link.ScheduleID] = append(recipients[link.ScheduleID], link.Email)
+	}
+	for i := range schedules {
+		schedules[i].Recipients = recipients[schedules[i].ID]
+		if schedules[i].Recipients == nil {
+			schedules[i].Recipients = []string{}
+		}
+	}
+	return schedules, nil
+}
+
+// GetScheduleByID returns a report schedule by its ID
+func (s *ReportService) GetScheduleByID(id int) (*models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	err := s.db.Get(&schedule, "SELECT * FROM report_schedules WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get report schedule %d: %v", id, err)
+		return nil, err
+	}
+
+	if err := s.attachRecipients(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// UpdateSchedule updates a report schedule, recomputing its next run
+func (s *ReportService) UpdateSchedule(id int, req models.UpdateReportScheduleRequest) (*models.ReportSchedule, error) {
+	schedule, err := s.GetScheduleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, nil
+	}
+
+	if req.Name != nil {
+		schedule.Name = *req.Name
+	}
+	if req.Period != nil && *req.Period != schedule.Period {
+		// Keep the schedule in step with the period unless it was customised
+		if schedule.Schedule == defaultReportSchedules[schedule.Period] && req.Schedule == nil {
+			schedule.Schedule = defaultReportSchedules[*req.Period]
+		}
+		schedule.Period = *req.Period
+	}
+	if req.Schedule != nil {
+		schedule.Schedule = *req.Schedule
+		if schedule.Schedule == "" {
+			schedule.Schedule = defaultReportSchedules[schedule.Period]
+		}
+	}
+	if req.GroupID != nil {
+		schedule.GroupID = req.GroupID
+		if *req.GroupID == 0 {
+			schedule.GroupID = nil
+		}
+	}
+	if req.Tag != nil {
+		schedule.Tag = *req.Tag
+	}
+	if req.Recipients != nil {
+		schedule.Recipients = uniqueStrings(req.Recipients)
+	}
+	if err := s.validateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	schedule.NextRunAt = nextReportRun(schedule.Schedule, now)
+	schedule.UpdatedAt = now
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(`
+		UPDATE report_schedules
+		SET name = :name, period = :period, schedule = :schedule, group_id = :group_id, tag = :tag,
+			next_run_at = :next_run_at, updated_at = :updated_at
+		WHERE id = :id
+	`, schedule)
+	if err != nil {
+		logger.Error("Failed to update report schedule %d: %v", id, err)
+		return nil, err
+	}
+
+	if err := setReportRecipients(tx, schedule.ID, schedule.Recipients); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// DeleteSchedule deletes a report schedule, reports it generated are kept
+func (s *ReportService) DeleteSchedule(id int) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM report_recipients WHERE schedule_id = ?", id); err != nil {
+		logger.Error("Failed to delete recipients of report schedule %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("UPDATE reports SET schedule_id = NULL WHERE schedule_id = ?", id); err != nil {
+		logger.Error("Failed to detach reports of report schedule %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM report_schedules WHERE id = ?", id); err != nil {
+		logger.Error("Failed to delete report schedule %d: %v", id, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// reportView is the data rendered by the report template
+type reportView struct {
+	Name        string
+	Period      string
+	From        time.Time
+	To          time.Time
+	Uptime      *float64
+	Incidents   int
+	MTTR        *float64
+	Servers     []models.ReportServer
+	GeneratedAt time.Time
+}
+
+// generate computes and stores a report over the last complete period before now
+func (s *ReportService) generate(scheduleID *int, name, period string, groupID *int, tag string, now time.Time) (*models.Report, error) {
+	calendar := models.PeriodWeek
+	if period == models.ReportMonthly {
+		calendar = models.PeriodMonth
+	}
+	current, _ := calendarPeriod(now, calendar)
+	from, to := calendarPeriod(current.Add(-time.Nanosecond), calendar)
+	previous, _ := calendarPeriod(from.Add(-time.Nanosecond), calendar)
+
+	filter := models.ServerFilter{GroupID: groupID}
+	if tag != "" {
+		selector, err := ParseTagSelector(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+		filter.Tags = []models.TagSelector{selector}
+	}
+	servers, err := s.servers.FindServers(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	view := reportView{
+		Name:        name,
+		Period:      period,
+		From:        from,
+		To:          to,
+		Servers:     []models.ReportServer{},
+		GeneratedAt: now.UTC(),
+	}
+	var successes, counted int
+	var repairs []float64
+	for _, server := range servers {
+		summary, err := s.history.SummarizeServerHistory(server.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		before, err := s.history.SummarizeServerHistory(server.ID, previous, from)
+		if err != nil {
+			return nil, err
+		}
+		outages, err := s.history.serverOutages(server.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		row := models.ReportServer{
+			ServerID:       server.ID,
+			Name:           server.Name,
+			URL:            server.URL,
+			Checks:         summary.Checks,
+			Uptime:         percentage(summary.SuccessRatio),
+			PreviousUptime: percentage(before.SuccessRatio),
+			Incidents:      len(outages),
+			Avg:            summary.Avg,
+			P50:            summary.P50,
+			P95:            summary.P95,
+			P99:            summary.P99,
+		}
+		if row.Uptime != nil && row.PreviousUptime != nil {
+			change := *row.Uptime - *row.PreviousUptime
+			row.UptimeChange = &change
+		}
+
+		var resolved []float64
+		for _, outage := range outages {
+			end := to
+			if outage.end != nil {
+				end = *outage.end
+				resolved = append(resolved, end.Sub(outage.start).Seconds())
+			}
+			row.Downtime += end.Sub(outage.start).Seconds()
+		}
+		row.MTTR = mean(resolved)
+		repairs = append(repairs, resolved...)
+
+		successes += summary.Successes
+		counted += summary.Checks - summary.Maintenance
+		view.Incidents += row.Incidents
+		view.Servers = append(view.Servers, row)
+	}
+	if counted > 0 {
+		uptime := float64(successes) / float64(counted) * 100
+		view.Uptime = &uptime
+	}
+	view.MTTR = mean(repairs)
+	sort.SliceStable(view.Servers, func(i, j int) bool {
+		return strings.ToLower(view.Servers[i].Name) < strings.ToLower(view.Servers[j].Name)
+	})
+
+	var html bytes.Buffer
+	if err := reportTemplate.Execute(&html, view); err != nil {
+		return nil, err
+	}
+	csv, err := reportCSV(view.Servers)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.Report{
+		ScheduleID: scheduleID,
+		Name:       name,
+		Period:     period,
+		From:       from,
+		To:         to,
+		GroupID:    groupID,
+		Tag:        tag,
+		HTML:       html.String(),
+		CSV:        csv,
+		CreatedAt:  now,
+	}
+	result, err := s.db.NamedExec(`
+		INSERT INTO reports (schedule_id, name, period, period_start, period_end, group_id, tag, html, csv, delivery_error, created_at)
+		VALUES (:schedule_id, :name, :period, :period_start, :period_end, :group_id, :tag, :html, :csv, :delivery_error, :created_at)
+	`, report)
+	if err != nil {
+		logger.Error("Failed to store report %q: %v", name, err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	report.ID = int(id)
+	return report, nil
+}
+
+// reportCSV renders the per-server rows of a report as CSV, empty cells have no data
+func reportCSV(rows []models.ReportServer) (string, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{
+		"server_id", "name", "url", "checks", "uptime_percent", "previous_uptime_percent", "uptime_change",
+		"incidents", "downtime_seconds", "mttr_seconds", "avg_ms", "p50_ms", "p95_ms", "p99_ms",
+	})
+
+	float := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', 4, 64)
+	}
+	integer := func(value *int) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.Itoa(*value)
+	}
+	for _, row := range rows {
+		writer.Write([]string{
+			strconv.Itoa(row.ServerID), row.Name, row.URL, strconv.Itoa(row.Checks),
+			float(row.Uptime), float(row.PreviousUptime), float(row.UptimeChange),
+			strconv.Itoa(row.Incidents), strconv.FormatFloat(row.Downtime, 'f', 0, 64), float(row.MTTR),
+			float(row.Avg), integer(row.P50), integer(row.P95), integer(row.P99),
+		})
+	}
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
+
+// ReportFilename returns the file name of the CSV export of a report
+func ReportFilename(report models.Report) string {
+	return fmt.Sprintf("report-%d-%s.csv", report.ID, report.From.Format("2006-01-02"))
+}
+
+// validateSchedule checks the cron expression and server selection of a schedule
+func (s *ReportService) validateSchedule(reportSchedule *models.ReportSchedule) error {
+	if _, err := schedule.ParseCron(reportSchedule.Schedule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return s.validateSelection(reportSchedule.GroupID, reportSchedule.Tag)
+}
+
+// validateSelection checks that the group of a report exists and its tag selector parses
+func (s *ReportService) validateSelection(groupID *int, tag string) error {
+	if tag != "" {
+		if _, err := ParseTagSelector(tag); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+	}
+	return s.servers.validateGroup(groupID)
+}
+
+// attachRecipients loads the recipients of a schedule
+func (s *ReportService) attachRecipients(schedule *models.ReportSchedule) error {
+	schedule.Recipients = []string{}
+	err := s.db.Select(&schedule.Recipients, "SELECT email FROM report_recipients WHERE schedule_id = ? ORDER BY email", schedule.ID)
+	if err != nil {
+		logger.Error("Failed to get recipients of report schedule %d: %v", schedule.ID, err)
+		return err
+	}
+	return nil
+}
+
+// setReportRecipients replaces the recipients of a schedule
+func setReportRecipients(tx *sqlx.Tx, scheduleID int, recipients []string) error {
+	if _, err := tx.Exec("DELETE FROM report_recipients WHERE schedule_id = ?", scheduleID); err != nil {
+		logger.Error("Failed to clear recipients of report schedule %d: %v", scheduleID, err)
+		return err
+	}
+	for _, email := range recipients {
+		if _, err := tx.Exec("INSERT INTO report_recipients (schedule_id, email) VALUES (?, ?)", scheduleID, email); err != nil {
+			logger.Error("Failed to add recipient to report schedule %d: %v", scheduleID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// nextReportRun returns the next time a cron expression matches after now in UTC, or nil if it never does
+func nextReportRun(expr string, now time.Time) *time.Time {
+	cron, err := schedule.ParseCron(expr)
+	if err != nil {
+		return nil
+	}
+	next, ok := cron.Next(now.UTC())
+	if !ok {
+		return nil
+	}
+	return &next
+}
+
+// percentage converts a ratio to a percentage
+func percentage(ratio *float64) *float64 {
+	if ratio == nil {
+		return nil
+	}
+	value := *ratio * 100
+	return &value
+}
+
+// mean returns the arithmetic mean of values, or nil if there are none
+func mean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	result := sum / float64(len(values))
+	return &result
+}
+
+// uniqueStrings returns values without duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// newTestReportService creates a report service mailing through an SMTP stand-in, with one
+// server that was checked three times in the week of 5 October 2026, failing once
+func newTestReportService(t *testing.T, standIn *smtpStandIn) *ReportService {
+	t.Helper()
+
+	db := newTestDB(t)
+	servers := NewServerService(db)
+	server := createTestServer(t, servers)
+
+	checkedAt := time.Date(2026, 10, 6, 10, 0, 0, 0, time.UTC)
+	for i, up := range []bool{true, false, true} {
+		state := models.StateUp
+		if !up {
+			state = models.StateDown
+		}
+		status := models.ServerStatus{
+			IsUp:          up,
+			StatusCode:    intPtr(200),
+			ResponseTime:  intPtr(100),
+			LastChecked:   checkedAt.Add(time.Duration(i) * time.Minute),
+			State:         state,
+			AddressFamily: models.FamilyAuto,
+		}
+		if err := servers.UpdateServerStatus(server.ID, status); err != nil {
+			t.Fatalf("record status: %v", err)
+		}
+	}
+
+	return NewReportService(db, servers, NewHistoryService(db), &Mailer{settings: standIn.settings()})
+}
+
+// createDueSchedule creates a weekly report schedule whose next run is before now
+func createDueSchedule(t *testing.T, reports *ReportService, name string, now time.Time) *models.ReportSchedule {
+	t.Helper()
+
+	schedule, err := reports.CreateSchedule(models.CreateReportScheduleRequest{
+		Name:       name,
+		Period:     models.ReportWeekly,
+		Recipients: []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	if _, err := reports.db.Exec("UPDATE report_schedules SET next_run_at = ? WHERE id = ?", now.Add(-time.Minute), schedule.ID); err != nil {
+		t.Fatalf("move next run: %v", err)
+	}
+	return schedule
+}
+
+// attachment returns the file name and decoded content of the first attachment of an email
+func attachment(t *testing.T, data string) (string, []byte) {
+	t.Helper()
+
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			t.Fatal("message has no attachment")
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		content, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatalf("decode attachment: %v", err)
+		}
+		return part.FileName(), content
+	}
+}
+
+func TestRunDueEmailsReportWithCSV(t *testing.T) {
+	standIn := newSMTPStandIn(t, false)
+	reports := newTestReportService(t, standIn)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	schedule := createDueSchedule(t, reports, "Weekly availability", now)
+
+	if err := reports.RunDue(now); err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+
+	var message smtpMessage
+	select {
+	case message = <-standIn.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+	if len(message.To) != 1 || message.To[0] != "ops@example.com" {
+		t.Errorf("recipients = %v, want [ops@example.com]", message.To)
+	}
+	if !strings.Contains(message.Data, "Subject: Weekly availability: 5 Oct to 11 Oct 2026") {
+		t.Errorf("subject missing from message:\n%s", message.Data)
+	}
+
+	filename, content := attachment(t, message.Data)
+	if !strings.HasSuffix(filename, "-2026-10-05.csv") {
+		t.Errorf("attachment filename = %q", filename)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("CSV has %d rows, want a header and one server", len(rows))
+	}
+	if rows[0][0] != "server_id" || rows[1][1] != "api" || rows[1][3] != "3" || rows[1][4] != "66.6667" {
+		t.Errorf("unexpected CSV:\n%s", content)
+	}
+
+	delivered, err := reports.GetReports()
+	if err != nil {
+		t.Fatalf("GetReports: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].DeliveredAt == nil || delivered[0].DeliveryError != "" {
+		t.Errorf("report not recorded as delivered: %+v", delivered)
+	}
+
+	updated, err := reports.GetScheduleByID(schedule.ID)
+	if err != nil {
+		t.Fatalf("GetScheduleByID: %v", err)
+	}
+	want := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	if updated.NextRunAt == nil || !updated.NextRunAt.Equal(want) {
+		t.Errorf("next run = %v, want %v", updated.NextRunAt, want)
+	}
+	if updated.LastRunAt == nil || !updated.LastRunAt.Equal(now) {
+		t.Errorf("last run = %v, want %v", updated.LastRunAt, now)
+	}
+}
+
+func TestRunDueRecordsFailedDeliveryAndContinues(t *testing.T) {
+	standIn := newSMTPStandIn(t, true)
+	reports := newTestReportService(t, standIn)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	first := createDueSchedule(t, reports, "First", now)
+	second := createDueSchedule(t, reports, "Second", now)
+
+	if err := reports.RunDue(now); err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+
+	generated, err := reports.GetReports()
+	if err != nil {
+		t.Fatalf("GetReports: %v", err)
+	}
+	if len(generated) != 2 {
+		t.Fatalf("generated %d reports, want 2", len(generated))
+	}
+	for _, report := range generated {
+		if report.DeliveredAt != nil || !strings.Contains(report.DeliveryError, "550") {
+			t.Errorf("report %q: delivered at %v, error %q", report.Name, report.DeliveredAt, report.DeliveryError)
+		}
+	}
+
+	for _, id := range []int{first.ID, second.ID} {
+		schedule, err := reports.GetScheduleByID(id)
+		if err != nil {
+			t.Fatalf("GetScheduleByID: %v", err)
+		}
+		if schedule.NextRunAt == nil || !schedule.NextRunAt.After(now) {
+			t.Errorf("schedule %q next run = %v, want after %v", schedule.Name, schedule.NextRunAt, now)
+		}
+	}
+}
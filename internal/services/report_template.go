@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// reportTemplate renders availability reports as a self-contained HTML document that
+// displays correctly in email clients, so styles are inline
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(value *float64) string {
+		if value == nil {
+			return "–"
+		}
+		return fmt.Sprintf("%.3f%%", *value)
+	},
+	"change": func(value *float64) string {
+		if value == nil {
+			return "–"
+		}
+		return fmt.Sprintf("%+.3f pp", *value)
+	},
+	"changeColor": func(value *float64) string {
+		switch {
+		case value == nil || *value == 0:
+			return "#555"
+		case *value > 0:
+			return "#1a7f37"
+		default:
+			return "#cf222e"
+		}
+	},
+	"duration": func(seconds float64) string {
+		return formatDuration(time.Duration(seconds * float64(time.Second)))
+	},
+	"mttr": func(seconds *float64) string {
+		if seconds == nil {
+			return "–"
+		}
+		return formatDuration(time.Duration(*seconds * float64(time.Second)))
+	},
+	"ms": func(value *int) string {
+		if value == nil {
+			return "–"
+		}
+		return fmt.Sprintf("%d ms", *value)
+	},
+	"date": func(t time.Time) string {
+		return t.Format("Mon 2 Jan 2006")
+	},
+	"lastDay": func(t time.Time) time.Time {
+		return t.AddDate(0, 0, -1)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body style="font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; color: #24292f; margin: 24px;">
+<h1 style="font-size: 22px; margin-bottom: 4px;">{{.Name}}</h1>
+<p style="color: #57606a; margin-top: 0;">{{if eq .Period "weekly"}}Weekly{{else}}Monthly{{end}} availability report for {{date .From}} to {{date (lastDay .To)}} (UTC)</p>
+
+<table style="border-collapse: collapse; margin: 16px 0;">
+<tr>
+<td style="padding: 8px 24px 8px 0;"><div style="color: #57606a; font-size: 12px;">Uptime</div><div style="font-size: 20px;">{{percent .Uptime}}</div></td>
+<td style="padding: 8px 24px 8px 0;"><div style="color: #57606a; font-size: 12px;">Incidents</div><div style="font-size: 20px;">{{.Incidents}}</div></td>
+<td style="padding: 8px 24px 8px 0;"><div style="color: #57606a; font-size: 12px;">MTTR</div><div style="font-size: 20px;">{{mttr .MTTR}}</div></td>
+<td style="padding: 8px 24px 8px 0;"><div style="color: #57606a; font-size: 12px;">Servers</div><div style="font-size: 20px;">{{len .Servers}}</div></td>
+</tr>
+</table>
+
+<table style="border-collapse: collapse; font-size: 13px;">
+<tr style="background: #f6f8fa; text-align: left;">
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">Server</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">Uptime</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">Change</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">Incidents</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">Downtime</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">MTTR</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">p50</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">p95</th>
+<th style="padding: 6px 10px; border: 1px solid #d0d7de;">p99</th>
+</tr>
+{{range .Servers}}<tr>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;"><strong>{{.Name}}</strong><br><span style="color: #57606a;">{{.URL}}</span></td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{percent .Uptime}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de; color: {{changeColor .UptimeChange}};">{{change .UptimeChange}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{.Incidents}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{duration .Downtime}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{mttr .MTTR}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{ms .P50}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{ms .P95}}</td>
+<td style="padding: 6px 10px; border: 1px solid #d0d7de;">{{ms .P99}}</td>
+</tr>
+{{else}}<tr><td colspan="9" style="padding: 6px 10px; border: 1px solid #d0d7de;">No servers</td></tr>
+{{end}}</table>
+
+<p style="color: #57606a; font-size: 12px; margin-top: 16px;">Uptime excludes maintenance windows. Change compares with the previous {{if eq .Period "weekly"}}week{{else}}month{{end}}. Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.</p>
+</body>
+</html>
+`))
+
+// formatDuration formats a duration as days, hours, minutes and seconds, omitting leading zero units
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	seconds := (d - minutes*time.Minute) / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// RetentionService rolls up and deletes old check history in the background
+type RetentionService struct {
+	db     *sqlx.DB
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewRetentionService creates a new retention service instance
+func NewRetentionService(db *sqlx.DB) *RetentionService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RetentionService{
+		db:     db,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins running the retention job periodically
+func (s *RetentionService) Start() {
+	if config.RetentionInterval <= 0 {
+		logger.Info("History retention is disabled")
+		return
+	}
+	logger.Info("Starting history retention")
+	go s.retentionLoop()
+}
+
+// Stop stops the retention job, a run in progress stops after the day it is processing
+func (s *RetentionService) Stop() {
+	logger.Info("Stopping history retention")
+	s.cancel()
+}
+
+// retentionLoop runs the retention job at startup and then on every interval
+func (s *RetentionService) retentionLoop() {
+	ticker := time.NewTicker(config.RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Run(time.Now().UTC()); err != nil {
+			logger.Error("History retention failed: %v", err)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run rolls up raw history older than the retention period into per-minute, hourly and daily
+// rollups, deletes it, and expires rollups older than their own retention. Raw history is
+// processed one UTC day of one server at a time in a transaction, so an interrupted run
+// loses nothing and the next run picks up where it stopped.
+func (s *RetentionService) Run(now time.Time) error {
+	now = now.UTC()
+	started := time.Now()
+	rolledUp := 0
+	servers := []int{}
+
+	if config.HistoryRetention > 0 {
+		cutoff := bucketStart(now.Add(-config.HistoryRetention), 24*time.Hour)
+		err := s.db.Select(&servers, "SELECT DISTINCT server_id FROM status_history WHERE checked_at < ? ORDER BY server_id", cutoff)
+		if err != nil {
+			logger.Error("Failed to get servers with expired history: %v", err)
+			return err
+		}
+
+		for i, id := range servers {
+			checks, err := s.rollUpServer(id, cutoff)
+			if err != nil {
+				return err
+			}
+			rolledUp += checks
+			logger.Info("Rolled up %d checks of server %d older than %s (%d/%d servers)", checks, id, cutoff.Format(time.DateOnly), i+1, len(servers))
+
+			if s.ctx.Err() != nil {
+				logger.Info("History retention interrupted after %d checks", rolledUp)
+				return nil
+			}
+		}
+	}
+
+	policies := []struct {
+		resolution string
+		retention  time.Duration
+	}{
+		{models.ResolutionMinute, config.MinuteRollupRetention},
+		{models.ResolutionHour, config.HourlyRollupRetention},
+		{models.ResolutionDay, config.DailyRollupRetention},
+	}
+	var expired int64
+	for _, policy := range policies {
+		if policy.retention <= 0 {
+			continue
+		}
+		cutoff := bucketStart(now.Add(-policy.retention), 24*time.Hour)
+		result, err := s.db.Exec("DELETE FROM history_rollups WHERE resolution = ? AND bucket_start < ?", policy.resolution, cutoff)
+		if err != nil {
+			logger.Error("Failed to expire %s rollups: %v", policy.resolution, err)
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		expired += deleted
+	}
+
+	if rolledUp > 0 || expired > 0 {
+		logger.Info("History retention rolled up %d checks of %d servers and expired %d rollups in %v", rolledUp, len(servers), expired, time.Since(started))
+	}
+	return nil
+}
+
+// rollUpServer rolls up and deletes the raw history of a server before the cutoff, one day
+// at a time, and returns the number of checks rolled up
+func (s *RetentionService) rollUpServer(id int, cutoff time.Time) (int, error) {
+	total := 0
+	for s.ctx.Err() == nil {
+		oldest := []time.Time{}
+		err := s.db.Select(&oldest, "SELECT checked_at FROM status_history WHERE server_id = ? AND checked_at < ? ORDER BY checked_at LIMIT 1", id, cutoff)
+		if err != nil {
+			logger.Error("Failed to get oldest check of server %d: %v", id, err)
+			return total, err
+		}
+		if len(oldest) == 0 {
+			break
+		}
+
+		checks, err := s.rollUpDay(id, bucketStart(oldest[0], 24*time.Hour))
+		if err != nil {
+			return total, err
+		}
+		if checks == 0 {
+			// Nothing matched the day of the oldest check, stop rather than loop forever
+			logger.Warn("Failed to roll up history of server %d from %v", id, oldest[0])
+			break
+		}
+		total += checks
+	}
+	return total, nil
+}
+
+// rollUpDay replaces the raw history of a server during a UTC day with its rollups
+func (s *RetentionService) rollUpDay(id int, day time.Time) (int, error) {
+	end := day.Add(24 * time.Hour)
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	checks := []historyCheck{}
+	err = tx.Select(&checks, `
+		SELECT checked_at, is_up, state, response_time FROM status_history
+		WHERE server_id = ? AND checked_at >= ? AND checked_at < ?
+		ORDER BY checked_at
+	`, id, day, end)
+	if err != nil {
+		logger.Error("Failed to get history of server %d for %s: %v", id, day.Format(time.DateOnly), err)
+		return 0, err
+	}
+
+	for _, resolution := range rollupResolutions {
+		for _, rollup := range rollUpChecks(id, resolution, checks) {
+			_, err := tx.NamedExec(`
+				INSERT OR REPLACE INTO history_rollups (
+					server_id, resolution, bucket_start, checks, failures, maintenance,
+					latency_count, latency_sum, min, max, p50, p95, p99
+				) VALUES (
+					:server_id, :resolution, :bucket_start, :checks, :failures, :maintenance,
+					:latency_count, :latency_sum, :min, :max, :p50, :p95, :p99
+				)
+			`, rollup)
+			if err != nil {
+				logger.Error("Failed to save %s rollup of server %d: %v", resolution.name, id, err)
+				return 0, err
+			}
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM status_history WHERE server_id = ? AND checked_at >= ? AND checked_at < ?", id, day, end)
+	if err != nil {
+		logger.Error("Failed to delete history of server %d for %s: %v", id, day.Format(time.DateOnly), err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(checks), nil
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// SecurityService audits security headers and TLS configuration of servers
+type SecurityService struct {
+	db         *sqlx.DB
+	transports *TransportManager
+}
+
+// NewSecurityService creates a new security service instance
+func NewSecurityService(db *sqlx.DB, transports *TransportManager) *SecurityService {
+	return &SecurityService{
+		db:         db,
+		transports: transports,
+	}
+}
+
+// securityHeader describes a response header checked during an audit
+type securityHeader struct {
+	name    string
+	penalty int
+	check   func(value string) (string, string)
+}
+
+var securityHeaders = []securityHeader{
+	{
+		name:    "Strict-Transport-Security",
+		penalty: 20,
+		check: func(value string) (string, string) {
+			maxAge := 0
+			for _, directive := range strings.Split(value, ";") {
+				directive = strings.TrimSpace(strings.ToLower(directive))
+				if strings.HasPrefix(directive, "max-age=") {
+					maxAge, _ = strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+				}
+			}
+			if maxAge < 15552000 {
+				return models.FindingWarn, "max-age should be at least 180 days"
+			}
+			return models.FindingPass, "HSTS is enabled"
+		},
+	},
+	{
+		name:    "Content-Security-Policy",
+		penalty: 20,
+		check: func(value string) (string, string) {
+			lower := strings.ToLower(value)
+			if strings.Contains(lower, "'unsafe-inline'") || strings.Contains(lower, "'unsafe-eval'") {
+				return models.FindingWarn, "policy allows unsafe-inline or unsafe-eval"
+			}
+			return models.FindingPass, "Content Security Policy is set"
+		},
+	},
+	{
+		name:    "X-Frame-Options",
+		penalty: 10,
+		check: func(value string) (string, string) {
+			switch strings.ToUpper(strings.TrimSpace(value)) {
+			case "DENY", "SAMEORIGIN":
+				return models.FindingPass, "framing is restricted"
+			}
+			return models.FindingWarn, "value should be DENY or SAMEORIGIN"
+		},
+	},
+	{
+		name:    "X-Content-Type-Options",
+		penalty: 10,
+		check: func(value string) (string, string) {
+			if strings.ToLower(strings.TrimSpace(value)) != "nosniff" {
+				return models.FindingWarn, "value should be nosniff"
+			}
+			return models.FindingPass, "MIME sniffing is disabled"
+		},
+	},
+	{
+		name:    "Referrer-Policy",
+		penalty: 5,
+		check: func(value string) (string, string) {
+			if strings.Contains(strings.ToLower(value), "unsafe-url") {
+				return models.FindingWarn, "unsafe-url leaks full URLs to third parties"
+			}
+			return models.FindingPass, "Referrer Policy is set"
+		},
+	},
+}
+
+// Audit grades the security headers and TLS configuration of a server
+func (s *SecurityService) Audit(server models.Server) *models.SecurityReport {
+	report := &models.SecurityReport{
+		ServerID:  server.ID,
+		Score:     100,
+		Findings:  []models.SecurityFinding{},
+		CheckedAt: time.Now().UTC(),
+	}
+
+	client, err := s.transports.Client(server)
+	if err != nil {
+		return failedReport(report, err)
+	}
+
+	req, err := http.NewRequest(server.Method, server.URL, nil)
+	if err != nil {
+		return failedReport(report, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return failedReport(report, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil {
+		return failedReport(report, fmt.Errorf("endpoint is not served over TLS"))
+	}
+
+	// Grade security headers
+	for _, header := range securityHeaders {
+		value := resp.Header.Get(header.name)
+		if value == "" {
+			report.Score -= header.penalty
+			report.Findings = append(report.Findings, models.SecurityFinding{
+				Check:   header.name,
+				Status:  models.FindingFail,
+				Message: "header is missing",
+			})
+			continue
+		}
+
+		status, message := header.check(value)
+		if status != models.FindingPass {
+			report.Score -= header.penalty / 2
+		}
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   header.name,
+			Status:  status,
+			Value:   value,
+			Message: message,
+		})
+	}
+
+	// Grade the negotiated connection
+	report.TLSVersion = tls.VersionName(resp.TLS.Version)
+	report.CipherSuite = tls.CipherSuiteName(resp.TLS.CipherSuite)
+	if resp.TLS.Version < tls.VersionTLS12 {
+		report.Score -= 30
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   "TLS version",
+			Status:  models.FindingFail,
+			Value:   report.TLSVersion,
+			Message: "negotiated protocol is deprecated",
+		})
+	} else {
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   "TLS version",
+			Status:  models.FindingPass,
+			Value:   report.TLSVersion,
+			Message: "negotiated protocol is current",
+		})
+	}
+
+	if isInsecureCipherSuite(resp.TLS.CipherSuite) {
+		report.Score -= 20
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   "Cipher suite",
+			Status:  models.FindingFail,
+			Value:   report.CipherSuite,
+			Message: "negotiated cipher suite is insecure",
+		})
+	} else {
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   "Cipher suite",
+			Status:  models.FindingPass,
+			Value:   report.CipherSuite,
+			Message: "negotiated cipher suite is secure",
+		})
+	}
+
+	// Probe for legacy protocol support
+	report.AcceptsTLS10 = s.acceptsTLSVersion(server, tls.VersionTLS10)
+	report.AcceptsTLS11 = s.acceptsTLSVersion(server, tls.VersionTLS11)
+	for _, legacy := range []struct {
+		name     string
+		accepted bool
+	}{
+		{"TLS 1.0", report.AcceptsTLS10},
+		{"TLS 1.1", report.AcceptsTLS11},
+	} {
+		if legacy.accepted {
+			report.Score -= 10
+			report.Findings = append(report.Findings, models.SecurityFinding{
+				Check:   legacy.name,
+				Status:  models.FindingFail,
+				Message: "deprecated protocol is still accepted",
+			})
+			continue
+		}
+		report.Findings = append(report.Findings, models.SecurityFinding{
+			Check:   legacy.name,
+			Status:  models.FindingPass,
+			Message: "deprecated protocol is rejected",
+		})
+	}
+
+	if report.Score < 0 {
+		report.Score = 0
+	}
+	report.Grade = gradeForScore(report.Score)
+	return report
+}
+
+// SaveReport stores a security report
+func (s *SecurityService) SaveReport(report *models.SecurityReport) error {
+	findings, err := json.Marshal(report.Findings)
+	if err != nil {
+		return err
+	}
+	report.FindingsJSON = string(findings)
+
+	result, err := s.db.NamedExec(`
+		INSERT INTO security_reports (server_id, grade, score, tls_version, cipher_suite, accepts_tls10, accepts_tls11, findings, error, checked_at)
+		VALUES (:server_id, :grade, :score, :tls_version, :cipher_suite, :accepts_tls10, :accepts_tls11, :findings, :error, :checked_at)
+	`, report)
+	if err != nil {
+		logger.Error("Failed to insert security report for server %d: %v", report.ServerID, err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	report.ID = int(id)
+	return nil
+}
+
+// GetReports returns the most recent security reports for a server
+func (s *SecurityService) GetReports(id int, limit int) ([]models.SecurityReport, error) {
+	var reports []models.SecurityReport
+	err := s.db.Select(&reports, `
+		SELECT * FROM security_reports
+		WHERE server_id = ?
+		ORDER BY checked_at DESC
+		LIMIT ?
+	`, id, limit)
+	if err != nil {
+		logger.Error("Failed to get security reports for server %d: %v", id, err)
+		return nil, err
+	}
+
+	for i := range reports {
+		if err := json.Unmarshal([]byte(reports[i].FindingsJSON), &reports[i].Findings); err != nil {
+			logger.Warn("Failed to decode findings of security report %d: %v", reports[i].ID, err)
+		}
+	}
+	return reports, nil
+}
+
+// GetOverview returns the latest security report of a server and how it changed over time.
+// It returns nil if the server does not exist.
+func (s *SecurityService) GetOverview(id int, limit int) (*models.SecurityOverview, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", id); err != nil {
+		logger.Error("Failed to get server %d: %v", id, err)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	reports, err := s.GetReports(id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &models.SecurityOverview{
+		History: reports,
+		Changes: []models.SecurityChange{},
+	}
+	if len(reports) > 0 {
+		overview.Latest = &reports[0]
+	}
+
+	// Reports are ordered newest first, so compare each one with its predecessor
+	for i := 0; i < len(reports)-1; i++ {
+		overview.Changes = append(overview.Changes, diffReports(reports[i+1], reports[i])...)
+	}
+	return overview, nil
+}
+
+// diffReports lists the differences between an older and a newer security report
+func diffReports(older, newer models.SecurityReport) []models.SecurityChange {
+	var changes []models.SecurityChange
+	record := func(check, from, to string) {
+		if from != to {
+			changes = append(changes, models.SecurityChange{
+				Check:     check,
+				From:      from,
+				To:        to,
+				ChangedAt: newer.CheckedAt,
+			})
+		}
+	}
+
+	record("Grade", older.Grade, newer.Grade)
+	record("TLS version", older.TLSVersion, newer.TLSVersion)
+	record("Cipher suite", older.CipherSuite, newer.CipherSuite)
+
+	previous := make(map[string]string)
+	for _, finding := range older.Findings {
+		previous[finding.Check] = finding.Status
+	}
+	for _, finding := range newer.Findings {
+		if status, exists := previous[finding.Check]; exists {
+			record(finding.Check, status, finding.Status)
+		}
+	}
+	return changes
+}
+
+// failedReport marks a report as failed with the given error
+func failedReport(report *models.SecurityReport, err error) *models.SecurityReport {
+	errorMsg := err.Error()
+	report.Error = &errorMsg
+	report.Score = 0
+	report.Grade = "F"
+	return report
+}
+
+// gradeForScore converts an audit score into a letter grade
+func gradeForScore(score int) string {
+	switch {
+	case score >= 95:
+		return "A+"
+	case score >= 85:
+		return "A"
+	case score >= 70:
+		return "B"
+	case score >= 55:
+		return "C"
+	case score >= 40:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// isInsecureCipherSuite reports whether a cipher suite is considered insecure by crypto/tls
+func isInsecureCipherSuite(id uint16) bool {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsTLSVersion reports whether a server completes a handshake with the given protocol
+// version. The probe connects the way checks of the server do, so it reaches the same
+// endpoint through the same proxy and network settings.
+func (s *SecurityService) acceptsTLSVersion(server models.Server, version uint16) bool {
+	var suites []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites = append(suites, suite.ID)
+	}
+
+	client, err := s.transports.ProbeClient(server, func(config *tls.Config) {
+		config.MinVersion = version
+		config.MaxVersion = version
+		config.CipherSuites = suites
+		config.InsecureSkipVerify = true // Only the protocol version is being probed
+	})
+	if err != nil {
+		return false
+	}
+
+	// The handshake is what counts, the server may still reject the request itself
+	var accepted atomic.Bool
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				accepted.Store(true)
+			}
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodHead, server.URL, nil)
+	if err != nil {
+		return false
+	}
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	return accepted.Load()
+}
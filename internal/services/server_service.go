@@ -2,46 +2,141 @@ package services
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
 	"github.com/waltertaya/server_check_bd/internal/logger"
 	"github.com/waltertaya/server_check_bd/internal/models"
 )
 
+var (
+	// ErrDependencyCycle is returned when the parents of a server would make it depend on itself
+	ErrDependencyCycle = errors.New("dependency cycle")
+
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrInvalidNetwork is returned when the connection settings of a server cannot be used
+	// together, or its source address has no IP of the address family it is checked over
+	ErrInvalidNetwork = errors.New("invalid network settings")
+)
+
+// serverSorts maps sort fields to their SQL expressions. Nullable columns are coalesced so
+// keyset pagination can compare them, and the expressions match the indexes created in
+// the migrations.
+var serverSorts = map[string]string{
+	models.SortName:         "name COLLATE NOCASE",
+	models.SortState:        "(CASE last_state WHEN 'DOWN' THEN 5 WHEN 'UNREACHABLE' THEN 4 WHEN 'DEGRADED' THEN 3 WHEN 'MAINTENANCE' THEN 2 WHEN 'UP' THEN 1 ELSE 0 END)",
+	models.SortLastChecked:  "COALESCE(last_checked_at, '')",
+	models.SortResponseTime: "COALESCE(last_response_time, -1)",
+	models.SortCreatedAt:    "created_at",
+}
+
+// serverCursor identifies the last server of a page by its sort value and ID
+type serverCursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    int             `json:"id"`
+}
+
 // ServerService handles server-related operations
 type ServerService struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	fullText bool // Whether the servers_fts search index is available
 }
 
 // NewServerService creates a new server service instance
 func NewServerService(db *sqlx.DB) *ServerService {
+	_, err := db.Exec("SELECT rowid FROM servers_fts LIMIT 0")
+	if err != nil {
+		logger.Warn("Full-text search of servers is unavailable, build with the sqlite_fts5 tag to enable it: %v", err)
+	}
+
 	return &ServerService{
-		db: db,
+		db:       db,
+		fullText: err == nil,
 	}
 }
 
-// CreateServer creates a new server
-func (s *ServerService) CreateServer(req models.CreateServerRequest) (*models.Server, error) {
+// NewServerFromRequest builds an unsaved server from a create request, applying defaults
+func NewServerFromRequest(req models.CreateServerRequest) *models.Server {
 	server := &models.Server{
-		Name:           req.Name,
-		Description:    "",
-		URL:            req.URL,
-		Method:         req.Method,
-		Interval:       req.Interval,
-		Timeout:        req.Timeout,
-		ExpectedStatus: req.ExpectedStatus,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		Name:               req.Name,
+		Description:        "",
+		Type:               models.TypeHTTP,
+		URL:                req.URL,
+		Method:             req.Method,
+		Interval:           req.Interval,
+		Timeout:            req.Timeout,
+		ExpectedStatus:     req.ExpectedStatus,
+		SecurityAudit:      req.SecurityAudit,
+		AuditInterval:      req.AuditInterval,
+		CrawlMaxPages:      req.CrawlMaxPages,
+		ProxyURL:           req.ProxyURL,
+		ResolverAddress:    req.ResolverAddress,
+		ResolveIP:          req.ResolveIP,
+		SourceAddress:      req.SourceAddress,
+		ClientCertID:       req.ClientCertID,
+		CABundleID:         req.CABundleID,
+		InsecureSkipVerify: req.InsecureSkipVerify,
+		ConnectionMode:     models.ConnectionKeepAlive,
+		AddressFamily:      models.FamilyAuto,
+		Enabled:            true,
+		GroupID:            req.GroupID,
+		ParentIDs:          uniqueIDs(req.ParentIDs),
+		Tags:               map[string]string{},
+		CreatedAt:          time.Now().UTC(),
+		UpdatedAt:          time.Now().UTC(),
 	}
 
 	if req.Description != nil {
 		server.Description = *req.Description
 	}
+	for key, value := range req.Tags {
+		server.Tags[key] = value
+	}
+	if req.Type != "" {
+		server.Type = req.Type
+	}
+	if req.ConnectionMode != "" {
+		server.ConnectionMode = req.ConnectionMode
+	}
+	if req.AddressFamily != "" {
+		server.AddressFamily = req.AddressFamily
+	}
+	if server.AuditInterval == 0 {
+		server.AuditInterval = int(config.SecurityAuditInterval / time.Millisecond)
+	}
+	if req.CrawlDepth != nil {
+		server.CrawlDepth = *req.CrawlDepth
+	}
+	if server.Type == models.TypeCrawler {
+		if req.CrawlDepth == nil {
+			server.CrawlDepth = config.DefaultCrawlDepth
+		}
+		if server.CrawlMaxPages == 0 {
+			server.CrawlMaxPages = config.DefaultCrawlMaxPages
+		}
+	}
+
+	return server
+}
+
+// CreateServer creates a new server
+func (s *ServerService) CreateServer(req models.CreateServerRequest) (*models.Server, error) {
+	server := NewServerFromRequest(req)
+	if err := s.ValidateServer(server); err != nil {
+		return nil, err
+	}
 
 	result, err := s.db.NamedExec(`
-		INSERT INTO servers (name, description, url, method, interval, timeout, expected_status, created_at, updated_at)
-		VALUES (:name, :description, :url, :method, :interval, :timeout, :expected_status, :created_at, :updated_at)
+		INSERT INTO servers (name, description, type, url, method, interval, timeout, expected_status, security_audit, audit_interval, crawl_depth, crawl_max_pages, proxy_url, resolver_address, resolve_ip, source_address, client_cert_id, ca_bundle_id, insecure_skip_verify, connection_mode, address_family, enabled, group_id, created_at, updated_at)
+		VALUES (:name, :description, :type, :url, :method, :interval, :timeout, :expected_status, :security_audit, :audit_interval, :crawl_depth, :crawl_max_pages, :proxy_url, :resolver_address, :resolve_ip, :source_address, :client_cert_id, :ca_bundle_id, :insecure_skip_verify, :connection_mode, :address_family, :enabled, :group_id, :created_at, :updated_at)
 	`, server)
 	if err != nil {
 		logger.Error("Failed to create server: %v", err)
@@ -55,20 +150,109 @@ func (s *ServerService) CreateServer(req models.CreateServerRequest) (*models.Se
 	}
 
 	server.ID = int(id)
+
+	if err := s.indexServer(server); err != nil {
+		return nil, err
+	}
+	if err := s.setParents(server.ID, server.ParentIDs); err != nil {
+		return nil, err
+	}
+	if err := s.setTags(server.ID, server.Tags); err != nil {
+		return nil, err
+	}
 	return server, nil
 }
 
 // GetServers returns all servers
 func (s *ServerService) GetServers() ([]models.Server, error) {
+	return s.FindServers(models.ServerFilter{})
+}
+
+// FindServers returns the servers matching a filter
+func (s *ServerService) FindServers(filter models.ServerFilter) ([]models.Server, error) {
+	where, args, err := s.serverConditions(filter)
+	if err != nil {
+		return nil, err
+	}
+
 	var servers []models.Server
-	err := s.db.Select(&servers, "SELECT * FROM servers ORDER BY created_at DESC")
+	err = s.db.Select(&servers, "SELECT * FROM servers WHERE "+where+" ORDER BY created_at DESC", args...)
 	if err != nil {
 		logger.Error("Failed to get servers: %v", err)
 		return nil, err
 	}
+
+	if err := s.attachRelations(servers); err != nil {
+		return nil, err
+	}
 	return servers, nil
 }
 
+// ListServers returns a page of the servers matching a query using keyset pagination,
+// along with the total number of matching servers
+func (s *ServerService) ListServers(query models.ServerQuery) (*models.ServerPage, error) {
+	where, args, err := s.serverConditions(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.ServerPage{Servers: []models.Server{}}
+	err = s.db.Get(&page.Total, "SELECT COUNT(*) FROM servers WHERE "+where, args...)
+	if err != nil {
+		logger.Error("Failed to count servers: %v", err)
+		return nil, err
+	}
+
+	sort := query.Sort
+	if sort == "" {
+		sort = models.SortCreatedAt
+	}
+	expr, exists := serverSorts[sort]
+	if !exists {
+		return nil, fmt.Errorf("unknown sort field %q", sort)
+	}
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		value, id, err := decodeServerCursor(sort, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND id %s ?))", expr, comparison, expr, comparison)
+		args = append(args, value, value, id)
+	}
+
+	statement := fmt.Sprintf("SELECT * FROM servers WHERE %s ORDER BY %s %s, id %s", where, expr, direction, direction)
+	if query.Limit > 0 {
+		// Fetch one extra row to know whether another page follows
+		statement += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+
+	err = s.db.Select(&page.Servers, statement, args...)
+	if err != nil {
+		logger.Error("Failed to list servers: %v", err)
+		return nil, err
+	}
+
+	if query.Limit > 0 && len(page.Servers) > query.Limit {
+		page.Servers = page.Servers[:query.Limit]
+		last := page.Servers[len(page.Servers)-1]
+		page.NextCursor, err = encodeServerCursor(sort, last)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.attachRelations(page.Servers); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
 // GetServerByID returns a server by its ID
 func (s *ServerService) GetServerByID(id int) (*models.Server, error) {
 	var server models.Server
@@ -80,6 +264,15 @@ func (s *ServerService) GetServerByID(id int) (*models.Server, error) {
 		logger.Error("Failed to get server %d: %v", id, err)
 		return nil, err
 	}
+
+	server.ParentIDs, err = s.GetParentIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	server.Tags, err = s.GetTags(id)
+	if err != nil {
+		return nil, err
+	}
 	return &server, nil
 }
 
@@ -111,8 +304,73 @@ func (s *ServerService) UpdateServer(id int, req models.UpdateServerRequest) (*m
 	if req.Interval != nil {
 		server.Interval = *req.Interval
 	}
+	if req.SecurityAudit != nil {
+		server.SecurityAudit = *req.SecurityAudit
+	}
+	if req.AuditInterval != nil {
+		server.AuditInterval = *req.AuditInterval
+	}
+	if req.CrawlDepth != nil {
+		server.CrawlDepth = *req.CrawlDepth
+	}
+	if req.CrawlMaxPages != nil {
+		server.CrawlMaxPages = *req.CrawlMaxPages
+	}
+	if req.ProxyURL != nil {
+		server.ProxyURL = *req.ProxyURL
+	}
+	if req.ResolverAddress != nil {
+		server.ResolverAddress = *req.ResolverAddress
+	}
+	if req.ResolveIP != nil {
+		server.ResolveIP = *req.ResolveIP
+	}
+	if req.SourceAddress != nil {
+		server.SourceAddress = *req.SourceAddress
+	}
+	// A reference of 0 detaches the certificate
+	if req.ClientCertID != nil {
+		server.ClientCertID = optionalID(*req.ClientCertID)
+		if err := s.validateCertificate(server.ClientCertID, models.CertificateClient); err != nil {
+			return nil, err
+		}
+	}
+	if req.CABundleID != nil {
+		server.CABundleID = optionalID(*req.CABundleID)
+		if err := s.validateCertificate(server.CABundleID, models.CertificateCA); err != nil {
+			return nil, err
+		}
+	}
+	if req.InsecureSkipVerify != nil {
+		server.InsecureSkipVerify = *req.InsecureSkipVerify
+	}
+	if req.ConnectionMode != nil {
+		server.ConnectionMode = *req.ConnectionMode
+	}
+	if req.AddressFamily != nil {
+		server.AddressFamily = *req.AddressFamily
+	}
+	if req.ParentIDs != nil {
+		server.ParentIDs = uniqueIDs(req.ParentIDs)
+		if err := s.validateParents(id, server.ParentIDs); err != nil {
+			return nil, err
+		}
+	}
+	// A group of 0 removes the server from its group
+	if req.GroupID != nil {
+		server.GroupID = optionalID(*req.GroupID)
+		if err := s.validateGroup(server.GroupID); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		server.Tags = req.Tags
+	}
+	if err := validateNetwork(server); err != nil {
+		return nil, err
+	}
 
-	server.UpdatedAt = time.Now()
+	server.UpdatedAt = time.Now().UTC()
 
 	_, err = s.db.NamedExec(`
 		UPDATE servers
@@ -122,6 +380,20 @@ func (s *ServerService) UpdateServer(id int, req models.UpdateServerRequest) (*m
 			expected_status = :expected_status,
 			timeout = :timeout,
 			interval = :interval,
+			security_audit = :security_audit,
+			audit_interval = :audit_interval,
+			crawl_depth = :crawl_depth,
+			crawl_max_pages = :crawl_max_pages,
+			proxy_url = :proxy_url,
+			resolver_address = :resolver_address,
+			resolve_ip = :resolve_ip,
+			source_address = :source_address,
+			client_cert_id = :client_cert_id,
+			ca_bundle_id = :ca_bundle_id,
+			insecure_skip_verify = :insecure_skip_verify,
+			connection_mode = :connection_mode,
+			address_family = :address_family,
+			group_id = :group_id,
 			updated_at = :updated_at
 		WHERE id = :id
 	`, server)
@@ -130,34 +402,120 @@ func (s *ServerService) UpdateServer(id int, req models.UpdateServerRequest) (*m
 		return nil, err
 	}
 
+	if err := s.indexServer(server); err != nil {
+		return nil, err
+	}
+	if req.ParentIDs != nil {
+		if err := s.setParents(id, server.ParentIDs); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		if err := s.setTags(id, server.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return server, nil
+}
+
+// SetServerEnabled pauses or resumes monitoring of a server
+func (s *ServerService) SetServerEnabled(id int, enabled bool) (*models.Server, error) {
+	server, err := s.GetServerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, nil
+	}
+
+	server.Enabled = enabled
+	server.UpdatedAt = time.Now().UTC()
+
+	_, err = s.db.NamedExec("UPDATE servers SET enabled = :enabled, updated_at = :updated_at WHERE id = :id", server)
+	if err != nil {
+		logger.Error("Failed to set enabled flag of server %d: %v", id, err)
+		return nil, err
+	}
+
 	return server, nil
 }
 
 // DeleteServer deletes a server
 func (s *ServerService) DeleteServer(id int) error {
-	_, err := s.db.Exec("DELETE FROM servers WHERE id = ?", id)
+	tx, err := s.db.Beginx()
 	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM maintenance_window_servers WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to detach server %d from maintenance windows: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM server_dependencies WHERE server_id = ? OR parent_id = ?", id, id); err != nil {
+		logger.Error("Failed to delete dependencies of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM slo_alerts WHERE slo_id IN (SELECT id FROM slos WHERE server_id = ?)", id); err != nil {
+		logger.Error("Failed to delete SLO alerts of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM slos WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to delete SLOs of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM incident_events WHERE incident_id IN (SELECT id FROM incidents WHERE server_id = ?)", id); err != nil {
+		logger.Error("Failed to delete incident timelines of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_threads WHERE incident_id IN (SELECT id FROM incidents WHERE server_id = ?)", id); err != nil {
+		logger.Error("Failed to delete notification threads of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM incidents WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to delete incidents of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notification_bindings WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to delete notification bindings of server %d: %v", id, err)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM server_tags WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to delete tags of server %d: %v", id, err)
+		return err
+	}
+	if s.fullText {
+		if _, err := tx.Exec("DELETE FROM servers_fts WHERE rowid = ?", id); err != nil {
+			logger.Error("Failed to remove server %d from the search index: %v", id, err)
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM servers WHERE id = ?", id); err != nil {
 		logger.Error("Failed to delete server %d: %v", id, err)
 		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
-// UpdateServerStatus updates a server's status
+// UpdateServerStatus stores the result of a check of one address family in the status history
 func (s *ServerService) UpdateServerStatus(id int, status models.ServerStatus) error {
 	history := models.ServerHistory{
-		ServerID:     id,
-		IsUp:         status.IsUp,
-		StatusCode:   status.StatusCode,
-		ResponseTime: status.ResponseTime,
-		ResponseBody: status.ResponseBody,
-		Error:        status.Error,
-		CheckedAt:    status.LastChecked,
+		ServerID:      id,
+		IsUp:          status.IsUp,
+		StatusCode:    status.StatusCode,
+		ResponseTime:  status.ResponseTime,
+		ResponseBody:  status.ResponseBody,
+		Error:         status.Error,
+		CheckedAt:     status.LastChecked,
+		State:         status.State,
+		AddressFamily: status.AddressFamily,
+		RootCauseID:   status.RootCauseID,
 	}
 
 	_, err := s.db.NamedExec(`
-		INSERT INTO status_history (server_id, is_up, status_code, response_time, response_body, error, checked_at)
-		VALUES (:server_id, :is_up, :status_code, :response_time, :response_body, :error, :checked_at)
+		INSERT INTO status_history (server_id, is_up, status_code, response_time, response_body, error, state, address_family, root_cause_id, checked_at)
+		VALUES (:server_id, :is_up, :status_code, :response_time, :response_body, :error, :state, :address_family, :root_cause_id, :checked_at)
 	`, history)
 	if err != nil {
 		logger.Error("Failed to insert status history for server %d: %v", id, err)
@@ -167,47 +525,740 @@ func (s *ServerService) UpdateServerStatus(id int, status models.ServerStatus) e
 	return nil
 }
 
-// GetServerHistory returns the status history for a server
-func (s *ServerService) GetServerHistory(id int, limit int) ([]models.ServerHistory, error) {
-	var history []models.ServerHistory
-	err := s.db.Select(&history, `
+// SetLatestStatus keeps the combined result of a check on the server itself so lists can be
+// filtered and sorted by it. Dual-stack servers store the worst of their address families.
+func (s *ServerService) SetLatestStatus(id int, status models.ServerStatus) error {
+	var certificateExpiresAt *time.Time
+	if status.Certificate != nil {
+		certificateExpiresAt = &status.Certificate.NotAfter
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE servers
+		SET last_state = ?, last_checked_at = ?, last_response_time = ?, certificate_expires_at = COALESCE(?, certificate_expires_at)
+		WHERE id = ?
+	`, status.State, status.LastChecked, status.ResponseTime, certificateExpiresAt, id)
+	if err != nil {
+		logger.Error("Failed to update latest status of server %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// combineStatuses folds the results of the address families of a check into one status: the
+// worst of them, checked when the last of them finished, with the first certificate served
+func combineStatuses(statuses []models.ServerStatus) models.ServerStatus {
+	combined := statuses[0]
+	for _, status := range statuses[1:] {
+		if stateSeverity[status.State] > stateSeverity[combined.State] {
+			lastChecked, certificate := combined.LastChecked, combined.Certificate
+			combined = status
+			combined.LastChecked, combined.Certificate = lastChecked, certificate
+		}
+		if status.LastChecked.After(combined.LastChecked) {
+			combined.LastChecked = status.LastChecked
+		}
+		if combined.Certificate == nil {
+			combined.Certificate = status.Certificate
+		}
+	}
+	return combined
+}
+
+// GetHistory returns the most recent status history of all servers matching a filter
+func (s *ServerService) GetHistory(filter models.ServerFilter, limit int) ([]models.ServerHistory, error) {
+	where, args, err := s.serverConditions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []models.ServerHistory{}
+	err = s.db.Select(&history, `
 		SELECT * FROM status_history
-		WHERE server_id = ?
+		WHERE server_id IN (SELECT id FROM servers WHERE `+where+`)
 		ORDER BY checked_at DESC
 		LIMIT ?
-	`, id, limit)
+	`, append(args, limit)...)
 	if err != nil {
-		logger.Error("Failed to get server history for server %d: %v", id, err)
+		logger.Error("Failed to get status history: %v", err)
 		return nil, err
 	}
 	return history, nil
 }
 
-// GetLatestStatus returns the latest status for a server
+// GetLatestStatuses returns the latest status of the given servers, or of every server when
+// no IDs are given, keyed by server ID. The latest results of the address families a server
+// is currently checked over are combined. Servers that were never checked are left out.
+func (s *ServerService) GetLatestStatuses(ids ...int) (map[int]models.ServerStatus, error) {
+	query := `
+		SELECT h.* FROM status_history h
+		JOIN servers s ON s.id = h.server_id AND (
+			h.address_family = s.address_family
+			OR (s.address_family = ? AND h.address_family IN (?, ?))
+		)
+		WHERE h.id IN (SELECT MAX(id) FROM status_history GROUP BY server_id, address_family)
+		ORDER BY h.server_id, h.address_family
+	`
+	args := []interface{}{models.FamilyBoth, models.FamilyIPv4, models.FamilyIPv6}
+	if len(ids) > 0 {
+		var err error
+		query, args, err = sqlx.In(`
+			SELECT h.* FROM status_history h
+			JOIN servers s ON s.id = h.server_id AND (
+				h.address_family = s.address_family
+				OR (s.address_family = ? AND h.address_family IN (?, ?))
+			)
+			WHERE h.id IN (SELECT MAX(id) FROM status_history WHERE server_id IN (?) GROUP BY server_id, address_family)
+			ORDER BY h.server_id, h.address_family
+		`, models.FamilyBoth, models.FamilyIPv4, models.FamilyIPv6, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	history := []models.ServerHistory{}
+	err := s.db.Select(&history, query, args...)
+	if err != nil {
+		logger.Error("Failed to get latest statuses: %v", err)
+		return nil, err
+	}
+
+	byServer := make(map[int][]models.ServerStatus)
+	for _, h := range history {
+		byServer[h.ServerID] = append(byServer[h.ServerID], models.ServerStatus{
+			IsUp:          h.IsUp,
+			StatusCode:    h.StatusCode,
+			ResponseTime:  h.ResponseTime,
+			ResponseBody:  h.ResponseBody,
+			Error:         h.Error,
+			LastChecked:   h.CheckedAt,
+			State:         h.State,
+			AddressFamily: h.AddressFamily,
+			RootCauseID:   h.RootCauseID,
+		})
+	}
+
+	statuses := make(map[int]models.ServerStatus)
+	for id, families := range byServer {
+		statuses[id] = combineStatuses(families)
+	}
+	return statuses, nil
+}
+
+// AttachStatus embeds the latest status of each server
+func (s *ServerService) AttachStatus(servers []models.Server) error {
+	if len(servers) == 0 {
+		return nil
+	}
+	statuses, err := s.GetLatestStatuses(serverIDs(servers)...)
+	if err != nil {
+		return err
+	}
+
+	for i := range servers {
+		if status, exists := statuses[servers[i].ID]; exists {
+			servers[i].Status = &status
+		}
+	}
+	return nil
+}
+
+// AttachUptime embeds the 24h, 7d and 30d uptime and average latency of each server,
+// computed for all servers in a single query. Hourly rollups stand in for raw history
+// that has already been removed by retention.
+func (s *ServerService) AttachUptime(servers []models.Server, now time.Time) error {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	now = now.UTC()
+	day, week, month := now.Add(-24*time.Hour), now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)
+	ids := serverIDs(servers)
+	query, args, err := sqlx.In(`
+		SELECT server_id,
+			SUM(CASE WHEN at >= ? THEN checks ELSE 0 END) AS day_checks,
+			SUM(CASE WHEN at >= ? THEN up ELSE 0 END) AS day_up,
+			SUM(CASE WHEN at >= ? THEN latency_sum END) * 1.0 / SUM(CASE WHEN at >= ? THEN latency_count END) AS day_latency,
+			SUM(CASE WHEN at >= ? THEN checks ELSE 0 END) AS week_checks,
+			SUM(CASE WHEN at >= ? THEN up ELSE 0 END) AS week_up,
+			SUM(CASE WHEN at >= ? THEN latency_sum END) * 1.0 / SUM(CASE WHEN at >= ? THEN latency_count END) AS week_latency,
+			SUM(checks) AS month_checks,
+			SUM(up) AS month_up,
+			SUM(latency_sum) * 1.0 / SUM(latency_count) AS month_latency
+		FROM (
+			SELECT server_id, checked_at AS at, 1 AS checks, CASE WHEN is_up THEN 1 ELSE 0 END AS up,
+				response_time AS latency_sum, CASE WHEN response_time IS NULL THEN 0 ELSE 1 END AS latency_count
+			FROM status_history
+			WHERE server_id IN (?) AND checked_at >= ? AND state != ?
+			UNION ALL
+			SELECT server_id, bucket_start, checks - maintenance, checks - maintenance - failures,
+				latency_sum, latency_count
+			FROM history_rollups r
+			WHERE server_id IN (?) AND resolution = ? AND bucket_start >= ?
+				AND bucket_start < COALESCE((SELECT MIN(checked_at) FROM status_history h WHERE h.server_id = r.server_id), ?)
+		)
+		GROUP BY server_id
+	`, day, day, day, day, week, week, week, week,
+		ids, month, models.StateMaintenance,
+		ids, models.ResolutionHour, month, now)
+	if err != nil {
+		return err
+	}
+
+	rows := []struct {
+		ServerID     int             `db:"server_id"`
+		DayChecks    int             `db:"day_checks"`
+		DayUp        int             `db:"day_up"`
+		DayLatency   sql.NullFloat64 `db:"day_latency"`
+		WeekChecks   int             `db:"week_checks"`
+		WeekUp       int             `db:"week_up"`
+		WeekLatency  sql.NullFloat64 `db:"week_latency"`
+		MonthChecks  int             `db:"month_checks"`
+		MonthUp      int             `db:"month_up"`
+		MonthLatency sql.NullFloat64 `db:"month_latency"`
+	}{}
+	if err := s.db.Select(&rows, query, args...); err != nil {
+		logger.Error("Failed to get uptime summaries: %v", err)
+		return err
+	}
+
+	summaries := make(map[int]models.UptimeSummary, len(rows))
+	for _, row := range rows {
+		summaries[row.ServerID] = models.UptimeSummary{
+			Day:   uptimeStats(row.DayChecks, row.DayUp, row.DayLatency),
+			Week:  uptimeStats(row.WeekChecks, row.WeekUp, row.WeekLatency),
+			Month: uptimeStats(row.MonthChecks, row.MonthUp, row.MonthLatency),
+		}
+	}
+
+	for i := range servers {
+		summary := summaries[servers[i].ID]
+		servers[i].Uptime = &summary
+	}
+	return nil
+}
+
+// GetLatestStatus returns the latest status for a server, combining its address families
 func (s *ServerService) GetLatestStatus(id int) (*models.ServerStatus, error) {
-	var history models.ServerHistory
-	err := s.db.Get(&history, `
-		SELECT * FROM status_history
-		WHERE server_id = ?
-		ORDER BY checked_at DESC
-		LIMIT 1
-	`, id)
+	statuses, err := s.GetLatestStatuses(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return nil, err
+	}
+	status, exists := statuses[id]
+	if !exists {
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// GetParentIDs returns the IDs of the servers a server depends on
+func (s *ServerService) GetParentIDs(id int) ([]int, error) {
+	parents := []int{}
+	err := s.db.Select(&parents, "SELECT parent_id FROM server_dependencies WHERE server_id = ? ORDER BY parent_id", id)
+	if err != nil {
+		logger.Error("Failed to get parents of server %d: %v", id, err)
+		return nil, err
+	}
+	return parents, nil
+}
+
+// GetDependencyGraph returns every server with its latest state and the dependencies between them
+func (s *ServerService) GetDependencyGraph() (*models.DependencyGraph, error) {
+	servers, err := s.GetServers()
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := s.GetLatestStatuses()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &models.DependencyGraph{
+		Nodes: []models.DependencyNode{},
+		Edges: []models.DependencyEdge{},
+	}
+	for _, server := range servers {
+		node := models.DependencyNode{ID: server.ID, Name: server.Name}
+		if status, ok := statuses[server.ID]; ok {
+			node.State = status.State
+			node.RootCauseID = status.RootCauseID
 		}
-		logger.Error("Failed to get latest status for server %d: %v", id, err)
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	err = s.db.Select(&graph.Edges, "SELECT server_id, parent_id FROM server_dependencies ORDER BY server_id, parent_id")
+	if err != nil {
+		logger.Error("Failed to get server dependencies: %v", err)
+		return nil, err
+	}
+	return graph, nil
+}
+
+// GetTags returns the tags of a server
+func (s *ServerService) GetTags(id int) (map[string]string, error) {
+	rows := []models.Tag{}
+	err := s.db.Select(&rows, "SELECT * FROM server_tags WHERE server_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to get tags of server %d: %v", id, err)
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range rows {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// SetTag sets the value of a single tag on a server
+func (s *ServerService) SetTag(id int, key string, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO server_tags (server_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (server_id, key) DO UPDATE SET value = excluded.value
+	`, id, key, value)
+	if err != nil {
+		logger.Error("Failed to set tag %s of server %d: %v", key, id, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteTag removes a tag from a server
+func (s *ServerService) DeleteTag(id int, key string) error {
+	_, err := s.db.Exec("DELETE FROM server_tags WHERE server_id = ? AND key = ?", id, key)
+	if err != nil {
+		logger.Error("Failed to delete tag %s of server %d: %v", key, id, err)
+		return err
+	}
+	return nil
+}
+
+// GetTagSummaries returns every tag in use with the number of servers carrying it
+func (s *ServerService) GetTagSummaries() ([]models.TagSummary, error) {
+	summaries := []models.TagSummary{}
+	err := s.db.Select(&summaries, `
+		SELECT key, value, COUNT(*) AS servers FROM server_tags
+		GROUP BY key, value
+		ORDER BY key, value
+	`)
+	if err != nil {
+		logger.Error("Failed to get tags: %v", err)
 		return nil, err
 	}
+	return summaries, nil
+}
+
+// ParseTagSelector parses a "key:value" tag selector, a bare key matches any value
+func ParseTagSelector(selector string) (models.TagSelector, error) {
+	key, value, _ := strings.Cut(selector, ":")
+	if key == "" {
+		return models.TagSelector{}, fmt.Errorf("invalid tag selector %q", selector)
+	}
+	return models.TagSelector{Key: key, Value: value}, nil
+}
+
+// serverConditions builds the SQL conditions selecting the servers matching a filter
+func (s *ServerService) serverConditions(filter models.ServerFilter) (string, []interface{}, error) {
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if query := searchQuery(filter.Search); query != "" && s.fullText {
+		conditions = append(conditions, "id IN (SELECT rowid FROM servers_fts WHERE servers_fts MATCH ?)")
+		args = append(args, query)
+	} else {
+		for _, word := range strings.Fields(filter.Search) {
+			pattern := "%" + escapeLike(word) + "%"
+			conditions = append(conditions, `(name LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`)
+			args = append(args, pattern, pattern, pattern)
+		}
+	}
+
+	if len(filter.States) > 0 {
+		condition, stateArgs, err := sqlx.In("last_state IN (?)", filter.States)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, stateArgs...)
+	}
+
+	if len(filter.Types) > 0 {
+		condition, typeArgs, err := sqlx.In("type IN (?)", filter.Types)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, typeArgs...)
+	}
+
+	if filter.Enabled != nil {
+		conditions = append(conditions, "enabled = ?")
+		args = append(args, *filter.Enabled)
+	}
+
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, "id IN (SELECT server_id FROM server_tags WHERE key = ? AND (? = '' OR value = ?))")
+		args = append(args, tag.Key, tag.Value, tag.Value)
+	}
+
+	if filter.GroupID != nil {
+		groups, err := groupWithDescendants(s.db, *filter.GroupID)
+		if err != nil {
+			return "", nil, err
+		}
+		condition, groupArgs, err := sqlx.In("group_id IN (?)", groups)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, groupArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// attachRelations loads the parents and tags of the given servers
+func (s *ServerService) attachRelations(servers []models.Server) error {
+	if len(servers) == 0 {
+		return nil
+	}
+	ids := serverIDs(servers)
+
+	query, args, err := sqlx.In("SELECT server_id, parent_id FROM server_dependencies WHERE server_id IN (?) ORDER BY parent_id", ids)
+	if err != nil {
+		return err
+	}
+	edges := []models.DependencyEdge{}
+	if err := s.db.Select(&edges, query, args...); err != nil {
+		logger.Error("Failed to get server dependencies: %v", err)
+		return err
+	}
+
+	query, args, err = sqlx.In("SELECT * FROM server_tags WHERE server_id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+	rows := []models.Tag{}
+	if err := s.db.Select(&rows, query, args...); err != nil {
+		logger.Error("Failed to get server tags: %v", err)
+		return err
+	}
+
+	index := make(map[int]int, len(servers))
+	for i := range servers {
+		index[servers[i].ID] = i
+		servers[i].ParentIDs = []int{}
+		servers[i].Tags = map[string]string{}
+	}
+	for _, edge := range edges {
+		server := &servers[index[edge.ServerID]]
+		server.ParentIDs = append(server.ParentIDs, edge.ParentID)
+	}
+	for _, tag := range rows {
+		servers[index[tag.ServerID]].Tags[tag.Key] = tag.Value
+	}
+	return nil
+}
 
-	status := &models.ServerStatus{
-		IsUp:         history.IsUp,
-		StatusCode:   history.StatusCode,
-		ResponseTime: history.ResponseTime,
-		ResponseBody: history.ResponseBody,
-		Error:        history.Error,
-		LastChecked:  history.CheckedAt,
+// encodeServerCursor builds the cursor pointing after a server in the given sort order
+func encodeServerCursor(sort string, server models.Server) (string, error) {
+	var value interface{}
+	switch sort {
+	case models.SortName:
+		value = server.Name
+	case models.SortState:
+		value = stateSeverity[server.LastState]
+	case models.SortLastChecked:
+		value = ""
+		if server.LastCheckedAt != nil {
+			value = *server.LastCheckedAt
+		}
+	case models.SortResponseTime:
+		value = -1
+		if server.LastResponseTime != nil {
+			value = *server.LastResponseTime
+		}
+	default:
+		value = server.CreatedAt
 	}
 
-	return status, nil
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(serverCursor{Value: raw, ID: server.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeServerCursor returns the sort value and server ID encoded in a cursor
+func decodeServerCursor(sort string, cursor string) (interface{}, int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var decoded serverCursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch sort {
+	case models.SortName:
+		var name string
+		err = json.Unmarshal(decoded.Value, &name)
+		return name, decoded.ID, cursorError(err)
+	case models.SortState, models.SortResponseTime:
+		var number int
+		err = json.Unmarshal(decoded.Value, &number)
+		return number, decoded.ID, cursorError(err)
+	case models.SortLastChecked:
+		var text string
+		if err := json.Unmarshal(decoded.Value, &text); err != nil || text == "" {
+			return "", decoded.ID, cursorError(err)
+		}
+		checkedAt, err := time.Parse(time.RFC3339Nano, text)
+		return checkedAt.UTC(), decoded.ID, cursorError(err)
+	default:
+		var createdAt time.Time
+		err = json.Unmarshal(decoded.Value, &createdAt)
+		return createdAt.UTC(), decoded.ID, cursorError(err)
+	}
+}
+
+// cursorError maps a cursor decoding failure to ErrInvalidCursor
+func cursorError(err error) error {
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// searchQuery builds the full-text query of a search, in which every word must prefix a word
+// of the name, URL or description. Words are quoted, so FTS5 syntax in them is matched
+// literally.
+func searchQuery(search string) string {
+	var terms []string
+	for _, word := range strings.Fields(search) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// indexServer updates the search index entry of a server
+func (s *ServerService) indexServer(server *models.Server) error {
+	if !s.fullText {
+		return nil
+	}
+
+	_, err := s.db.Exec(
+		"INSERT OR REPLACE INTO servers_fts (rowid, name, url, description) VALUES (?, ?, ?, ?)",
+		server.ID, server.Name, server.URL, server.Description,
+	)
+	if err != nil {
+		logger.Error("Failed to index server %d: %v", server.ID, err)
+		return err
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// setTags replaces the tags of a server
+func (s *ServerService) setTags(id int, tags map[string]string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM server_tags WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to clear tags of server %d: %v", id, err)
+		return err
+	}
+	for key, value := range tags {
+		if _, err := tx.Exec("INSERT INTO server_tags (server_id, key, value) VALUES (?, ?, ?)", id, key, value); err != nil {
+			logger.Error("Failed to add tag %s to server %d: %v", key, id, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ValidateServer checks the settings of a server that is not stored yet, such as one being
+// created or tested, including that the servers, group and certificates it references exist
+func (s *ServerService) ValidateServer(server *models.Server) error {
+	if err := s.validateParents(0, server.ParentIDs); err != nil {
+		return err
+	}
+	if err := s.validateGroup(server.GroupID); err != nil {
+		return err
+	}
+	if err := s.validateCertificate(server.ClientCertID, models.CertificateClient); err != nil {
+		return err
+	}
+	if err := s.validateCertificate(server.CABundleID, models.CertificateCA); err != nil {
+		return err
+	}
+	return validateNetwork(server)
+}
+
+// validateGroup ensures a referenced group exists
+func (s *ServerService) validateGroup(groupID *int) error {
+	if groupID == nil {
+		return nil
+	}
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM server_groups WHERE id = ?", *groupID); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownGroup, *groupID)
+	}
+	return nil
+}
+
+// validateCertificate ensures a referenced certificate exists and is of the expected type
+func (s *ServerService) validateCertificate(certificateID *int, certificateType string) error {
+	if certificateID == nil {
+		return nil
+	}
+	types := []string{}
+	if err := s.db.Select(&types, "SELECT type FROM certificates WHERE id = ?", *certificateID); err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownCertificate, *certificateID)
+	}
+	if types[0] != certificateType {
+		return fmt.Errorf("%w: %d is not a %s certificate", ErrUnknownCertificate, *certificateID, certificateType)
+	}
+	return nil
+}
+
+// parentMap returns the parents of every server keyed by server ID
+func (s *ServerService) parentMap() (map[int][]int, error) {
+	edges := []models.DependencyEdge{}
+	err := s.db.Select(&edges, "SELECT server_id, parent_id FROM server_dependencies ORDER BY parent_id")
+	if err != nil {
+		logger.Error("Failed to get server dependencies: %v", err)
+		return nil, err
+	}
+
+	parents := make(map[int][]int)
+	for _, edge := range edges {
+		parents[edge.ServerID] = append(parents[edge.ServerID], edge.ParentID)
+	}
+	return parents, nil
+}
+
+// validateParents ensures the parents exist and that depending on them would not create a
+// cycle. New servers are passed with an ID of 0 and cannot be part of a cycle yet.
+func (s *ServerService) validateParents(id int, parentIDs []int) error {
+	for _, parentID := range parentIDs {
+		if parentID == id {
+			return fmt.Errorf("%w: server %d cannot depend on itself", ErrDependencyCycle, id)
+		}
+		var count int
+		if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", parentID); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %d", ErrUnknownServer, parentID)
+		}
+	}
+	if id == 0 {
+		return nil
+	}
+
+	parents, err := s.parentMap()
+	if err != nil {
+		return err
+	}
+	parents[id] = parentIDs
+
+	// Walk up from the new parents, reaching the server again means a cycle
+	visited := make(map[int]bool)
+	stack := append([]int{}, parentIDs...)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == id {
+			return fmt.Errorf("%w: server %d would depend on itself", ErrDependencyCycle, id)
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, parents[current]...)
+	}
+	return nil
+}
+
+// setParents replaces the parents of a server
+func (s *ServerService) setParents(id int, parentIDs []int) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM server_dependencies WHERE server_id = ?", id); err != nil {
+		logger.Error("Failed to clear parents of server %d: %v", id, err)
+		return err
+	}
+	for _, parentID := range parentIDs {
+		if _, err := tx.Exec("INSERT INTO server_dependencies (server_id, parent_id) VALUES (?, ?)", id, parentID); err != nil {
+			logger.Error("Failed to add parent %d to server %d: %v", parentID, id, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// uniqueIDs returns the IDs without duplicates, preserving their order
+func uniqueIDs(ids []int) []int {
+	unique := []int{}
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// uptimeStats builds the uptime statistics of a period from its check counts and mean latency
+func uptimeStats(checks int, up int, latency sql.NullFloat64) models.UptimeStats {
+	stats := models.UptimeStats{Checks: checks}
+	if checks > 0 {
+		uptime := float64(up) * 100 / float64(checks)
+		stats.Uptime = &uptime
+	}
+	if latency.Valid {
+		stats.AverageLatency = &latency.Float64
+	}
+	return stats
+}
+
+// serverIDs returns the IDs of the given servers
+func serverIDs(servers []models.Server) []int {
+	ids := make([]int, len(servers))
+	for i, server := range servers {
+		ids[i] = server.ID
+	}
+	return ids
+}
+
+// optionalID converts a zero ID into a nil reference
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
 }
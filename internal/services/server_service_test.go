@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// searchNames returns the names of the servers matching a search
+func searchNames(t *testing.T, servers *ServerService, search string) []string {
+	t.Helper()
+
+	found, err := servers.FindServers(models.ServerFilter{Search: search})
+	if err != nil {
+		t.Fatalf("FindServers(%q): %v", search, err)
+	}
+	names := []string{}
+	for _, server := range found {
+		names = append(names, server.Name)
+	}
+	return names
+}
+
+func TestFindServersSearchFollowsChanges(t *testing.T) {
+	servers := NewServerService(newTestDB(t))
+	description := "payments backend"
+	billing, err := servers.CreateServer(models.CreateServerRequest{
+		Name:           "Billing API",
+		URL:            "https://billing.example.com/health",
+		Description:    &description,
+		Method:         "GET",
+		ExpectedStatus: 200,
+		Timeout:        5000,
+		Interval:       60000,
+	})
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	if _, err := servers.CreateServer(models.CreateServerRequest{
+		Name:           "Web",
+		URL:            "https://www.example.org/",
+		Method:         "GET",
+		ExpectedStatus: 200,
+		Timeout:        5000,
+		Interval:       60000,
+	}); err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+
+	for search, want := range map[string]int{"pay": 1, "example": 2, "example pay": 1, `"NEAR(`: 0} {
+		if got := searchNames(t, servers, search); len(got) != want {
+			t.Errorf("search %q found %v, want %d servers", search, got, want)
+		}
+	}
+
+	name := "Invoicing"
+	if _, err := servers.UpdateServer(billing.ID, models.UpdateServerRequest{Name: &name}); err != nil {
+		t.Fatalf("update server: %v", err)
+	}
+	if got := searchNames(t, servers, "invoic"); len(got) != 1 || got[0] != name {
+		t.Errorf("search after rename found %v, want [%s]", got, name)
+	}
+
+	if err := servers.DeleteServer(billing.ID); err != nil {
+		t.Fatalf("delete server: %v", err)
+	}
+	if got := searchNames(t, servers, "invoic"); len(got) != 0 {
+		t.Errorf("search after delete found %v", got)
+	}
+}
+
+func TestValidateServerRejectsUnknownReferences(t *testing.T) {
+	servers := NewServerService(newTestDB(t))
+	unknown := 42
+	request := models.CreateServerRequest{
+		Name:           "api",
+		URL:            "https://api.example.com/health",
+		Method:         "GET",
+		ExpectedStatus: 200,
+		Timeout:        5000,
+		Interval:       60000,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *models.CreateServerRequest)
+		want   error
+	}{
+		{"valid", func(req *models.CreateServerRequest) {}, nil},
+		{"unknown parent", func(req *models.CreateServerRequest) { req.ParentIDs = []int{unknown} }, ErrUnknownServer},
+		{"unknown group", func(req *models.CreateServerRequest) { req.GroupID = &unknown }, ErrUnknownGroup},
+		{"unknown client certificate", func(req *models.CreateServerRequest) { req.ClientCertID = &unknown }, ErrUnknownCertificate},
+		{"unknown CA bundle", func(req *models.CreateServerRequest) { req.CABundleID = &unknown }, ErrUnknownCertificate},
+		{"invalid network", func(req *models.CreateServerRequest) { req.ResolveIP = "not an IP" }, ErrInvalidNetwork},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := request
+			test.modify(&req)
+			if err := servers.ValidateServer(NewServerFromRequest(req)); !errors.Is(err, test.want) {
+				t.Errorf("ValidateServer = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
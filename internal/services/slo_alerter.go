@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// burnRatePolicy is a multi-window burn rate alerting condition
+type burnRatePolicy struct {
+	severity    string
+	longWindow  time.Duration
+	shortWindow time.Duration
+	threshold   float64
+}
+
+// burnRatePolicies are the fast and slow burn conditions recommended by the Google SRE
+// workbook, a 14.4x burn spends 2% of a 30 day budget in an hour and a 6x burn 5% in six hours
+var burnRatePolicies = []burnRatePolicy{
+	{models.SeverityPage, time.Hour, 5 * time.Minute, 14.4},
+	{models.SeverityTicket, 6 * time.Hour, 30 * time.Minute, 6},
+}
+
+// SLOAlerter continuously evaluates burn rate alerts of SLOs from check history
+type SLOAlerter struct {
+	db            *sqlx.DB
+	slos          *SLOService
+	notifications *NotificationService
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// NewSLOAlerter creates a new SLO alerter instance
+func NewSLOAlerter(db *sqlx.DB, slos *SLOService, notifications *NotificationService) *SLOAlerter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SLOAlerter{
+		db:            db,
+		slos:          slos,
+		notifications: notifications,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start begins evaluating burn rate alerts periodically
+func (a *SLOAlerter) Start() {
+	if config.SLOAlertInterval <= 0 {
+		logger.Info("SLO alerting is disabled")
+		return
+	}
+	logger.Info("Starting SLO alerter")
+	go a.alertLoop()
+}
+
+// Stop stops evaluating burn rate alerts
+func (a *SLOAlerter) Stop() {
+	logger.Info("Stopping SLO alerter")
+	a.cancel()
+}
+
+// alertLoop evaluates alerts on every interval
+func (a *SLOAlerter) alertLoop() {
+	ticker := time.NewTicker(config.SLOAlertInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Evaluate(time.Now().UTC()); err != nil {
+				logger.Error("Failed to evaluate SLO alerts: %v", err)
+			}
+		}
+	}
+}
+
+// Evaluate checks every SLO against the burn rate policies, firing alerts whose long and
+// short windows both burn faster than the threshold and resolving firing alerts that no
+// longer do. An SLO that fails to evaluate is logged and skipped.
+func (a *SLOAlerter) Evaluate(now time.Time) error {
+	now = now.UTC()
+	slos, err := a.slos.GetSLOs()
+	if err != nil {
+		return err
+	}
+
+	for _, slo := range slos {
+		if err := a.evaluate(slo, now); err != nil {
+			logger.Error("Failed to evaluate alerts of SLO %d: %v", slo.ID, err)
+		}
+	}
+	return nil
+}
+
+// evaluate checks an SLO against the burn rate policies
+func (a *SLOAlerter) evaluate(slo models.SLO, now time.Time) error {
+	serverIDs, err := a.slos.sloServers(slo)
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range burnRatePolicies {
+		long, err := a.slos.burnRate(slo, serverIDs, now.Add(-policy.longWindow), now)
+		if err != nil {
+			return err
+		}
+		short, err := a.slos.burnRate(slo, serverIDs, now.Add(-policy.shortWindow), now)
+		if err != nil {
+			return err
+		}
+		breached := long != nil && short != nil && *long > policy.threshold && *short > policy.threshold
+
+		firing, err := a.firingAlert(slo.ID, policy.severity)
+		if err != nil {
+			return err
+		}
+		switch {
+		case breached && firing == nil:
+			if err := a.fire(slo, serverIDs, policy, *long, *short, now); err != nil {
+				return err
+			}
+		case !breached && firing != nil:
+			if err := a.resolve(slo, serverIDs, *firing, burnRateValue(long), burnRateValue(short), now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// burnRateValue returns a burn rate, or zero for a window without events
+func burnRateValue(rate *float64) float64 {
+	if rate == nil {
+		return 0
+	}
+	return *rate
+}
+
+// GetAlerts returns the alerts of an SLO, or of all SLOs if sloID is nil, newest first.
+// An empty state returns alerts in any state.
+func (a *SLOAlerter) GetAlerts(sloID *int, state string) ([]models.SLOAlert, error) {
+	alerts := []models.SLOAlert{}
+	err := a.db.Select(&alerts, `
+		SELECT * FROM slo_alerts
+		WHERE (? IS NULL OR slo_id = ?) AND (? = '' OR state = ?)
+		ORDER BY fired_at DESC, id DESC
+	`, sloID, sloID, state, state)
+	if err != nil {
+		logger.Error("Failed to get SLO alerts: %v", err)
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// firingAlert returns the firing alert of an SLO with a severity, or nil if there is none
+func (a *SLOAlerter) firingAlert(sloID int, severity string) (*models.SLOAlert, error) {
+	var alert models.SLOAlert
+	err := a.db.Get(&alert, "SELECT * FROM slo_alerts WHERE slo_id = ? AND severity = ? AND state = ?", sloID, severity, models.AlertFiring)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get firing alert of SLO %d: %v", sloID, err)
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// fire records a new firing alert and notifies the channels bound to the servers of the SLO
+func (a *SLOAlerter) fire(slo models.SLO, serverIDs []int, policy burnRatePolicy, long, short float64, now time.Time) error {
+	alert := models.SLOAlert{
+		SLOID:         slo.ID,
+		Severity:      policy.severity,
+		State:         models.AlertFiring,
+		LongWindow:    int(policy.longWindow / time.Minute),
+		ShortWindow:   int(policy.shortWindow / time.Minute),
+		Threshold:     policy.threshold,
+		LongBurnRate:  long,
+		ShortBurnRate: short,
+		FiredAt:       now,
+	}
+	_, err := a.db.NamedExec(`
+		INSERT INTO slo_alerts (slo_id, severity, state, long_window, short_window, threshold, long_burn_rate, short_burn_rate, fired_at)
+		VALUES (:slo_id, :severity, :state, :long_window, :short_window, :threshold, :long_burn_rate, :short_burn_rate, :fired_at)
+	`, alert)
+	if err != nil {
+		logger.Error("Failed to record alert of SLO %d: %v", slo.ID, err)
+		return err
+	}
+
+	message := fmt.Sprintf("Burning its error budget at %.1fx over %v and %.1fx over %v",
+		long, policy.longWindow, short, policy.shortWindow)
+	logger.Warn("SLO %q: %s (%s)", slo.Name, message, policy.severity)
+
+	level := models.LevelWarning
+	if policy.severity == models.SeverityPage {
+		level = models.LevelCritical
+	}
+	a.notifications.Notify(models.Notification{
+		Event:   models.NotifySLOAlertFired,
+		Level:   level,
+		Title:   fmt.Sprintf("SLO %s burn rate alert (%s)", slo.Name, policy.severity),
+		Message: message,
+		SLO: &models.NotificationSLO{
+			ID:            slo.ID,
+			Name:          slo.Name,
+			Severity:      policy.severity,
+			LongWindow:    alert.LongWindow,
+			ShortWindow:   alert.ShortWindow,
+			Threshold:     policy.threshold,
+			LongBurnRate:  long,
+			ShortBurnRate: short,
+		},
+		At: now.UTC(),
+	}, serverIDs...)
+	return nil
+}
+
+// resolve marks a firing alert as resolved and notifies the channels bound to the servers of
+// the SLO with the current burn rates
+func (a *SLOAlerter) resolve(slo models.SLO, serverIDs []int, alert models.SLOAlert, long, short float64, now time.Time) error {
+	_, err := a.db.Exec("UPDATE slo_alerts SET state = ?, resolved_at = ? WHERE id = ?", models.AlertResolved, now, alert.ID)
+	if err != nil {
+		logger.Error("Failed to resolve alert %d of SLO %d: %v", alert.ID, slo.ID, err)
+		return err
+	}
+
+	logger.Info("SLO %q burn rate alert (%s) resolved", slo.Name, alert.Severity)
+	a.notifications.Notify(models.Notification{
+		Event:   models.NotifySLOAlertResolved,
+		Level:   models.LevelInfo,
+		Title:   fmt.Sprintf("SLO %s burn rate alert (%s) resolved", slo.Name, alert.Severity),
+		Message: fmt.Sprintf("Burn rate is back below %.1fx", alert.Threshold),
+		SLO: &models.NotificationSLO{
+			ID:            slo.ID,
+			Name:          slo.Name,
+			Severity:      alert.Severity,
+			LongWindow:    alert.LongWindow,
+			ShortWindow:   alert.ShortWindow,
+			Threshold:     alert.Threshold,
+			LongBurnRate:  long,
+			ShortBurnRate: short,
+		},
+		At: now.UTC(),
+	}, serverIDs...)
+	return nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// newTestSLOAlerter creates an alerter for an availability SLO of 99% on one server, with a
+// log channel bound to the server, and returns the ID of the channel
+func newTestSLOAlerter(t *testing.T) (*SLOAlerter, *ServerService, models.SLO, int) {
+	t.Helper()
+
+	useTestEncryptionKey(t)
+	slos, servers, slo := newTestSLOService(t, models.CreateSLORequest{Type: models.SLOAvailability, Target: 99})
+	notifications := NewNotificationService(servers.db)
+	channel := createTestChannel(t, notifications, models.ChannelLog, map[string]string{}, *slo.ServerID)
+	return NewSLOAlerter(servers.db, slos, notifications), servers, slo, channel.ID
+}
+
+// firingAlerts returns the firing alerts of an SLO by severity
+func firingAlerts(t *testing.T, alerter *SLOAlerter, sloID int) map[string]models.SLOAlert {
+	t.Helper()
+
+	alerts, err := alerter.GetAlerts(&sloID, models.AlertFiring)
+	if err != nil {
+		t.Fatalf("GetAlerts: %v", err)
+	}
+	firing := make(map[string]models.SLOAlert)
+	for _, alert := range alerts {
+		if _, ok := firing[alert.Severity]; ok {
+			t.Errorf("more than one firing %s alert", alert.Severity)
+		}
+		firing[alert.Severity] = alert
+	}
+	return firing
+}
+
+func TestSLOAlerterPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing func(i int) bool // Whether the check i minutes ago failed
+		page    bool
+		ticket  bool
+	}{
+		{"healthy", func(i int) bool { return false }, false, false},
+		// 10% failures burn at 10x, above 6x but below 14.4x
+		{"slow burn", func(i int) bool { return i%10 == 0 }, false, true},
+		// The last hour failed, burning at 100x over the page windows and 16.7x over six hours
+		{"fast burn", func(i int) bool { return i <= 60 }, true, true},
+		// The page needs the short window to still burn, the last five minutes passed
+		{"recovered short window", func(i int) bool { return i > 5 && i <= 60 }, false, true},
+		// A burst within the short windows only does not burn the long windows enough
+		{"short burst", func(i int) bool { return i <= 5 }, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alerter, servers, slo, _ := newTestSLOAlerter(t)
+			recordMinutes(t, servers, *slo.ServerID, 6*60, test.failing)
+
+			if err := alerter.Evaluate(sloNow); err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			firing := firingAlerts(t, alerter, slo.ID)
+			if _, ok := firing[models.SeverityPage]; ok != test.page {
+				t.Errorf("page firing = %v, want %v", ok, test.page)
+			}
+			if _, ok := firing[models.SeverityTicket]; ok != test.ticket {
+				t.Errorf("ticket firing = %v, want %v", ok, test.ticket)
+			}
+		})
+	}
+}
+
+func TestSLOAlerterFiresOnceAndResolves(t *testing.T) {
+	alerter, servers, slo, channelID := newTestSLOAlerter(t)
+	recordMinutes(t, servers, *slo.ServerID, 6*60, func(i int) bool { return i <= 60 })
+
+	for i := 0; i < 2; i++ {
+		if err := alerter.Evaluate(sloNow); err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+	}
+	firing := firingAlerts(t, alerter, slo.ID)
+	page, ok := firing[models.SeverityPage]
+	if !ok {
+		t.Fatal("page alert is not firing")
+	}
+	if page.LongWindow != 60 || page.ShortWindow != 5 || page.Threshold != 14.4 ||
+		math.Abs(page.LongBurnRate-100) > 1e-9 || math.Abs(page.ShortBurnRate-100) > 1e-9 || !page.FiredAt.Equal(sloNow) {
+		t.Errorf("page alert = %+v", page)
+	}
+	ticket := firing[models.SeverityTicket]
+	if ticket.LongWindow != 360 || ticket.ShortWindow != 30 || ticket.Threshold != 6 ||
+		math.Abs(ticket.LongBurnRate-100.0/6) > 1e-9 || math.Abs(ticket.ShortBurnRate-100) > 1e-9 {
+		t.Errorf("ticket alert = %+v", ticket)
+	}
+
+	// Checks pass again for the next hour, which clears both short windows and the hour long
+	// page window while the six hour window still burns at 16.7x
+	for i := 1; i <= 60; i++ {
+		recordCheck(t, servers, *slo.ServerID, sloNow.Add(time.Duration(i)*time.Minute-time.Second), models.StateUp, 100)
+	}
+	if err := alerter.Evaluate(sloNow.Add(time.Hour)); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	alerts, err := alerter.GetAlerts(&slo.ID, "")
+	if err != nil {
+		t.Fatalf("GetAlerts: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("%d alerts, want 2", len(alerts))
+	}
+	for _, alert := range alerts {
+		if alert.State != models.AlertResolved || alert.ResolvedAt == nil || !alert.ResolvedAt.Equal(sloNow.Add(time.Hour)) {
+			t.Errorf("%s alert: state %s, resolved at %v", alert.Severity, alert.State, alert.ResolvedAt)
+		}
+	}
+
+	events := []string{}
+	for _, delivery := range channelDeliveries(t, alerter.notifications, channelID) {
+		events = append(events, delivery.Event)
+	}
+	want := []string{models.NotifySLOAlertFired, models.NotifySLOAlertFired, models.NotifySLOAlertResolved, models.NotifySLOAlertResolved}
+	if len(events) != len(want) {
+		t.Fatalf("notifications = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("notifications = %v, want %v", events, want)
+			break
+		}
+	}
+}
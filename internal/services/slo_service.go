@@ -0,0 +1,463 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/waltertaya/server_check_bd/internal/logger"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// defaultSLOWindowDays is the rolling window of SLOs that do not configure one
+const defaultSLOWindowDays = 30
+
+// ErrInvalidSLO is returned when an SLO has an inconsistent target
+var ErrInvalidSLO = errors.New("invalid SLO")
+
+// SLOService manages service level objectives and computes their attainment
+type SLOService struct {
+	db      *sqlx.DB
+	servers *ServerService
+}
+
+// NewSLOService creates a new SLO service instance
+func NewSLOService(db *sqlx.DB, servers *ServerService) *SLOService {
+	return &SLOService{
+		db:      db,
+		servers: servers,
+	}
+}
+
+// CreateSLO validates and stores an SLO
+func (s *SLOService) CreateSLO(req models.CreateSLORequest) (*models.SLO, error) {
+	now := time.Now().UTC()
+	slo := &models.SLO{
+		Name:           req.Name,
+		Description:    req.Description,
+		ServerID:       req.ServerID,
+		GroupID:        req.GroupID,
+		Type:           req.Type,
+		Target:         req.Target,
+		Threshold:      req.Threshold,
+		WindowDays:     req.WindowDays,
+		CalendarPeriod: req.CalendarPeriod,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if slo.WindowDays == 0 {
+		slo.WindowDays = defaultSLOWindowDays
+	}
+	if slo.CalendarPeriod == "" {
+		slo.CalendarPeriod = models.PeriodMonth
+	}
+	if err := s.validate(slo); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.NamedExec(`
+		INSERT INTO slos (name, description, server_id, group_id, type, target, threshold, window_days, calendar_period, created_at, updated_at)
+		VALUES (:name, :description, :server_id, :group_id, :type, :target, :threshold, :window_days, :calendar_period, :created_at, :updated_at)
+	`, slo)
+	if err != nil {
+		logger.Error("Failed to create SLO: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	slo.ID = int(id)
+	return slo, nil
+}
+
+// GetSLOs returns all SLOs
+func (s *SLOService) GetSLOs() ([]models.SLO, error) {
+	slos := []models.SLO{}
+	err := s.db.Select(&slos, "SELECT * FROM slos ORDER BY id")
+	if err != nil {
+		logger.Error("Failed to get SLOs: %v", err)
+		return nil, err
+	}
+	return slos, nil
+}
+
+// GetSLOByID returns an SLO by its ID
+func (s *SLOService) GetSLOByID(id int) (*models.SLO, error) {
+	var slo models.SLO
+	err := s.db.Get(&slo, "SELECT * FROM slos WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Failed to get SLO %d: %v", id, err)
+		return nil, err
+	}
+	return &slo, nil
+}
+
+// UpdateSLO updates an SLO, its server or group and type cannot be changed
+func (s *SLOService) UpdateSLO(id int, req models.UpdateSLORequest) (*models.SLO, error) {
+	slo, err := s.GetSLOByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if slo == nil {
+		return nil, nil
+	}
+
+	if req.Name != nil {
+		slo.Name = *req.Name
+	}
+	if req.Description != nil {
+		slo.Description = *req.Description
+	}
+	if req.Target != nil {
+		slo.Target = *req.Target
+	}
+	if req.Threshold != nil {
+		slo.Threshold = req.Threshold
+	}
+	if req.WindowDays != nil {
+		slo.WindowDays = *req.WindowDays
+	}
+	if req.CalendarPeriod != nil {
+		slo.CalendarPeriod = *req.CalendarPeriod
+	}
+	slo.UpdatedAt = time.Now().UTC()
+	if err := s.validate(slo); err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.NamedExec(`
+		UPDATE slos
+		SET name = :name, description = :description, target = :target, threshold = :threshold,
+			window_days = :window_days, calendar_period = :calendar_period, updated_at = :updated_at
+		WHERE id = :id
+	`, slo)
+	if err != nil {
+		logger.Error("Failed to update SLO %d: %v", id, err)
+		return nil, err
+	}
+	return slo, nil
+}
+
+// DeleteSLO deletes an SLO
+func (s *SLOService) DeleteSLO(id int) error {
+	_, err := s.db.Exec("DELETE FROM slo_alerts WHERE slo_id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete alerts of SLO %d: %v", id, err)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM slos WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Failed to delete SLO %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetServerReports evaluates the SLOs of a server, including those of the groups it is
+// nested in, against its own checks. It returns nil if the server does not exist.
+func (s *SLOService) GetServerReports(id int, now time.Time) ([]models.SLOReport, error) {
+	server, err := s.servers.GetServerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, nil
+	}
+
+	groups := []int{}
+	if server.GroupID != nil {
+		groups, err = groupAncestors(s.db, *server.GroupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slos, err := s.objectives(&id, groups)
+	if err != nil {
+		return nil, err
+	}
+	return s.reports(slos, []int{id}, now)
+}
+
+// GetGroupReports evaluates the SLOs of a group, including those of the groups it is nested
+// in, against the checks of all servers in the group and its subgroups. It returns nil if
+// the group does not exist.
+func (s *SLOService) GetGroupReports(id int, now time.Time) ([]models.SLOReport, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM server_groups WHERE id = ?", id); err != nil {
+		logger.Error("Failed to get group %d: %v", id, err)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	groups, err := groupAncestors(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	slos, err := s.objectives(nil, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupMembers(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.reports(slos, members, now)
+}
+
+// sloServers returns the IDs of the servers whose checks count towards an SLO
+func (s *SLOService) sloServers(slo models.SLO) ([]int, error) {
+	if slo.ServerID != nil {
+		return []int{*slo.ServerID}, nil
+	}
+	return s.groupMembers(*slo.GroupID)
+}
+
+// groupMembers returns the IDs of the servers in a group and its subgroups
+func (s *SLOService) groupMembers(id int) ([]int, error) {
+	subtree, err := groupWithDescendants(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := sqlx.In("SELECT id FROM servers WHERE group_id IN (?) ORDER BY id", subtree)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []int{}
+	if err := s.db.Select(&members, query, args...); err != nil {
+		logger.Error("Failed to get servers of group %d: %v", id, err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// objectives returns the SLOs defined on a server or on any of the given groups
+func (s *SLOService) objectives(serverID *int, groups []int) ([]models.SLO, error) {
+	if len(groups) == 0 {
+		groups = []int{0}
+	}
+	query, args, err := sqlx.In("SELECT * FROM slos WHERE server_id = ? OR group_id IN (?) ORDER BY id", serverID, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	slos := []models.SLO{}
+	if err := s.db.Select(&slos, query, args...); err != nil {
+		logger.Error("Failed to get SLOs: %v", err)
+		return nil, err
+	}
+	return slos, nil
+}
+
+// reports evaluates SLOs over their rolling window and current calendar period
+func (s *SLOService) reports(slos []models.SLO, serverIDs []int, now time.Time) ([]models.SLOReport, error) {
+	now = now.UTC()
+	reports := make([]models.SLOReport, 0, len(slos))
+	for _, slo := range slos {
+		rollingStart := now.AddDate(0, 0, -slo.WindowDays)
+		rolling, err := s.evaluate(slo, serverIDs, rollingStart, now, now)
+		if err != nil {
+			return nil, err
+		}
+
+		periodStart, periodEnd := calendarPeriod(now, slo.CalendarPeriod)
+		calendar, err := s.evaluate(slo, serverIDs, periodStart, now, periodEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, models.SLOReport{SLO: slo, Rolling: rolling, Calendar: calendar})
+	}
+	return reports, nil
+}
+
+// evaluate computes the attainment of an SLO from the checks between from and to. The error
+// budget is sized for the whole window up to end, assuming events keep arriving at the rate
+// seen so far, so a calendar period that has just started does not look exhausted.
+func (s *SLOService) evaluate(slo models.SLO, serverIDs []int, from, to, end time.Time) (models.SLOWindow, error) {
+	window := models.SLOWindow{Start: from, End: end, Met: true}
+
+	events, bad, err := s.events(slo, serverIDs, from, to)
+	if err != nil {
+		return window, err
+	}
+	window.Events = int(math.Round(events))
+	window.BadEvents = int(math.Round(bad))
+	if events == 0 {
+		return window, nil
+	}
+
+	sli := (events - bad) / events * 100
+	allowed := (100 - slo.Target) / 100
+	burnRate := bad / events / allowed
+	expected := events * end.Sub(from).Seconds() / to.Sub(from).Seconds()
+	remaining := (1 - bad/(allowed*expected)) * 100
+
+	window.SLI = &sli
+	window.BurnRate = &burnRate
+	window.ErrorBudgetRemaining = &remaining
+	window.Met = sli >= slo.Target
+	return window, nil
+}
+
+// events counts the events and bad events of an SLO in the checks of servers between from
+// and to, skipping checks during maintenance. Ranges whose raw history was removed by
+// retention are counted from hourly rollups.
+func (s *SLOService) events(slo models.SLO, serverIDs []int, from, to time.Time) (float64, float64, error) {
+	if len(serverIDs) == 0 {
+		return 0, 0, nil
+	}
+	threshold := 0
+	if slo.Threshold != nil {
+		threshold = *slo.Threshold
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT COUNT(*) AS checks,
+			COALESCE(SUM(CASE WHEN is_up THEN 0 ELSE 1 END), 0) AS failures,
+			COUNT(response_time) AS timed,
+			COALESCE(SUM(CASE WHEN response_time > ? THEN 1 ELSE 0 END), 0) AS slow
+		FROM status_history
+		WHERE server_id IN (?) AND checked_at >= ? AND checked_at < ? AND state != ?
+	`, threshold, serverIDs, from, to, models.StateMaintenance)
+	if err != nil {
+		return 0, 0, err
+	}
+	var raw struct {
+		Checks   int `db:"checks"`
+		Failures int `db:"failures"`
+		Timed    int `db:"timed"`
+		Slow     int `db:"slow"`
+	}
+	if err := s.db.Get(&raw, query, args...); err != nil {
+		logger.Error("Failed to count SLO events: %v", err)
+		return 0, 0, err
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT * FROM history_rollups r
+		WHERE server_id IN (?) AND resolution = ? AND bucket_start >= ? AND bucket_start < ?
+			AND bucket_start < COALESCE((SELECT MIN(checked_at) FROM status_history h WHERE h.server_id = r.server_id), ?)
+	`, serverIDs, models.ResolutionHour, from, to, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	rollups := []models.HistoryRollup{}
+	if err := s.db.Select(&rollups, query, args...); err != nil {
+		logger.Error("Failed to get SLO rollups: %v", err)
+		return 0, 0, err
+	}
+
+	if slo.Type == models.SLOLatency {
+		events, bad := float64(raw.Timed), float64(raw.Slow)
+		for _, rollup := range rollups {
+			events += float64(rollup.LatencyCount)
+			bad += float64(rollup.LatencyCount) * (1 - fractionWithin(rollup, threshold))
+		}
+		return events, bad, nil
+	}
+
+	events, bad := float64(raw.Checks), float64(raw.Failures)
+	for _, rollup := range rollups {
+		events += float64(rollup.Checks - rollup.Maintenance)
+		bad += float64(rollup.Failures)
+	}
+	return events, bad, nil
+}
+
+// burnRate returns how fast the error budget of an SLO was consumed between from and to,
+// or nil if there were no events
+func (s *SLOService) burnRate(slo models.SLO, serverIDs []int, from, to time.Time) (*float64, error) {
+	events, bad, err := s.events(slo, serverIDs, from, to)
+	if err != nil || events == 0 {
+		return nil, err
+	}
+	rate := bad / events / ((100 - slo.Target) / 100)
+	return &rate, nil
+}
+
+// validate checks that an SLO targets exactly one existing server or group and has a
+// threshold if and only if it is a latency SLO
+func (s *SLOService) validate(slo *models.SLO) error {
+	if (slo.ServerID == nil) == (slo.GroupID == nil) {
+		return fmt.Errorf("%w: exactly one of serverId and groupId is required", ErrInvalidSLO)
+	}
+	if slo.Type == models.SLOLatency && slo.Threshold == nil {
+		return fmt.Errorf("%w: latency SLOs require a threshold", ErrInvalidSLO)
+	}
+	if slo.Type == models.SLOAvailability {
+		slo.Threshold = nil
+	}
+
+	if slo.ServerID != nil {
+		var count int
+		if err := s.db.Get(&count, "SELECT COUNT(*) FROM servers WHERE id = ?", *slo.ServerID); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %d", ErrUnknownServer, *slo.ServerID)
+		}
+	}
+	return s.servers.validateGroup(slo.GroupID)
+}
+
+// fractionWithin estimates the fraction of the response times in a rollup at or below a
+// threshold by interpolating linearly between its minimum, percentiles and maximum
+func fractionWithin(rollup models.HistoryRollup, threshold int) float64 {
+	if rollup.LatencyCount == 0 {
+		return 1
+	}
+	points := []struct {
+		latency  float64
+		fraction float64
+	}{
+		{float64(*rollup.Min), 0},
+		{float64(*rollup.P50), 0.50},
+		{float64(*rollup.P95), 0.95},
+		{float64(*rollup.P99), 0.99},
+		{float64(*rollup.Max), 1},
+	}
+
+	value := float64(threshold)
+	if value < points[0].latency {
+		return 0
+	}
+	for i := 1; i < len(points); i++ {
+		if value >= points[i].latency {
+			continue
+		}
+		lower, upper := points[i-1], points[i]
+		return lower.fraction + (upper.fraction-lower.fraction)*(value-lower.latency)/(upper.latency-lower.latency)
+	}
+	return 1
+}
+
+// calendarPeriod returns the start and end of the UTC calendar week, month or quarter containing t
+func calendarPeriod(t time.Time, period string) (time.Time, time.Time) {
+	t = t.UTC()
+	switch period {
+	case models.PeriodWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case models.PeriodQuarter:
+		start := time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
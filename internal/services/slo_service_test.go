@@ -0,0 +1,203 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// sloNow is the evaluation time of the SLO tests
+var sloNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// recordCheck stores a check of a server in its history
+func recordCheck(t *testing.T, servers *ServerService, serverID int, at time.Time, state string, responseTime int) {
+	t.Helper()
+
+	status := models.ServerStatus{
+		IsUp:          state == models.StateUp,
+		StatusCode:    intPtr(200),
+		ResponseTime:  intPtr(responseTime),
+		LastChecked:   at,
+		State:         state,
+		AddressFamily: models.FamilyAuto,
+	}
+	if err := servers.UpdateServerStatus(serverID, status); err != nil {
+		t.Fatalf("record check: %v", err)
+	}
+}
+
+// recordMinutes stores one check a minute for the given number of minutes before sloNow,
+// the check i minutes ago failing when failing(i) is true
+func recordMinutes(t *testing.T, servers *ServerService, serverID, minutes int, failing func(i int) bool) {
+	t.Helper()
+
+	for i := 1; i <= minutes; i++ {
+		state := models.StateUp
+		if failing(i) {
+			state = models.StateDown
+		}
+		recordCheck(t, servers, serverID, sloNow.Add(-time.Duration(i)*time.Minute), state, 100)
+	}
+}
+
+// newTestSLOService creates an SLO service with one server and an SLO on it
+func newTestSLOService(t *testing.T, slo models.CreateSLORequest) (*SLOService, *ServerService, models.SLO) {
+	t.Helper()
+
+	db := newTestDB(t)
+	servers := NewServerService(db)
+	server := createTestServer(t, servers)
+	slos := NewSLOService(db, servers)
+
+	slo.Name = "api " + slo.Type
+	slo.ServerID = &server.ID
+	created, err := slos.CreateSLO(slo)
+	if err != nil {
+		t.Fatalf("CreateSLO: %v", err)
+	}
+	return slos, servers, *created
+}
+
+// assertFloat fails the test unless a value is set and close to want
+func assertFloat(t *testing.T, name string, got *float64, want float64) {
+	t.Helper()
+
+	if got == nil {
+		t.Errorf("%s = nil, want %v", name, want)
+	} else if math.Abs(*got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, *got, want)
+	}
+}
+
+func TestSLOEvaluate(t *testing.T) {
+	slos, servers, slo := newTestSLOService(t, models.CreateSLORequest{Type: models.SLOAvailability, Target: 98})
+	// 100 checks with 2 failures, and checks during maintenance that do not count
+	recordMinutes(t, servers, *slo.ServerID, 100, func(i int) bool { return i == 10 || i == 50 })
+	recordCheck(t, servers, *slo.ServerID, sloNow.Add(-90*time.Second), models.StateMaintenance, 100)
+	recordCheck(t, servers, *slo.ServerID, sloNow.Add(-150*time.Second), models.StateMaintenance, 100)
+	from := sloNow.Add(-100 * time.Minute)
+
+	window, err := slos.evaluate(slo, []int{*slo.ServerID}, from, sloNow, sloNow)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if window.Events != 100 || window.BadEvents != 2 {
+		t.Errorf("events = %d, bad = %d, want 100 and 2", window.Events, window.BadEvents)
+	}
+	assertFloat(t, "SLI", window.SLI, 98)
+	assertFloat(t, "burn rate", window.BurnRate, 1)
+	assertFloat(t, "remaining budget", window.ErrorBudgetRemaining, 0)
+	if !window.Met {
+		t.Error("SLO at its target is not met")
+	}
+
+	// Halfway through a period the budget is sized for twice the events seen so far
+	window, err = slos.evaluate(slo, []int{*slo.ServerID}, from, sloNow, sloNow.Add(100*time.Minute))
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	assertFloat(t, "remaining budget halfway", window.ErrorBudgetRemaining, 50)
+
+	slo.Target = 99.5
+	window, err = slos.evaluate(slo, []int{*slo.ServerID}, from, sloNow, sloNow)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	assertFloat(t, "burn rate of a stricter target", window.BurnRate, 4)
+	assertFloat(t, "remaining budget of a stricter target", window.ErrorBudgetRemaining, -300)
+	if window.Met {
+		t.Error("SLO below its target is met")
+	}
+}
+
+func TestSLOEvaluateWithoutEvents(t *testing.T) {
+	slos, _, slo := newTestSLOService(t, models.CreateSLORequest{Type: models.SLOAvailability, Target: 99})
+
+	window, err := slos.evaluate(slo, []int{*slo.ServerID}, sloNow.Add(-time.Hour), sloNow, sloNow)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if window.Events != 0 || window.SLI != nil || window.BurnRate != nil || window.ErrorBudgetRemaining != nil || !window.Met {
+		t.Errorf("window without events = %+v", window)
+	}
+}
+
+func TestSLOEventsCountsRollupsBeforeRawHistory(t *testing.T) {
+	threshold := 200
+	slos, servers, slo := newTestSLOService(t, models.CreateSLORequest{Type: models.SLOLatency, Target: 99, Threshold: &threshold})
+	serverID := *slo.ServerID
+	recordCheck(t, servers, serverID, sloNow.Add(-30*time.Minute), models.StateUp, 100)
+	recordCheck(t, servers, serverID, sloNow.Add(-20*time.Minute), models.StateUp, 300)
+	recordCheck(t, servers, serverID, sloNow.Add(-10*time.Minute), models.StateDown, 250)
+
+	// The first rollup predates the raw history, the second overlaps it and is ignored
+	for _, rollup := range []models.HistoryRollup{
+		{ServerID: serverID, BucketStart: sloNow.Add(-3 * time.Hour), Checks: 60, Failures: 3, Maintenance: 10, LatencyCount: 40,
+			Min: intPtr(100), P50: intPtr(150), P95: intPtr(250), P99: intPtr(400), Max: intPtr(500)},
+		{ServerID: serverID, BucketStart: sloNow.Add(-30 * time.Minute), Checks: 60, Failures: 60, LatencyCount: 60,
+			Min: intPtr(900), P50: intPtr(900), P95: intPtr(900), P99: intPtr(900), Max: intPtr(900)},
+	} {
+		rollup.Resolution = models.ResolutionHour
+		_, err := servers.db.NamedExec(`
+			INSERT INTO history_rollups (server_id, resolution, bucket_start, checks, failures, maintenance, latency_count, latency_sum, min, max, p50, p95, p99)
+			VALUES (:server_id, :resolution, :bucket_start, :checks, :failures, :maintenance, :latency_count, :latency_sum, :min, :max, :p50, :p95, :p99)
+		`, rollup)
+		if err != nil {
+			t.Fatalf("insert rollup: %v", err)
+		}
+	}
+	from := sloNow.Add(-4 * time.Hour)
+
+	// 3 timed checks with 2 slower than 200ms, and 27.5% of the 40 rolled up response times,
+	// interpolated between the median of 150ms and the 95th percentile of 250ms
+	events, bad, err := slos.events(slo, []int{serverID}, from, sloNow)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if events != 43 || math.Abs(bad-13) > 1e-9 {
+		t.Errorf("latency events = %v, bad = %v, want 43 and 13", events, bad)
+	}
+
+	// 3 checks with 1 failure, and the 50 rolled up checks outside maintenance with 3 failures
+	slo.Type = models.SLOAvailability
+	events, bad, err = slos.events(slo, []int{serverID}, from, sloNow)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if events != 53 || bad != 4 {
+		t.Errorf("availability events = %v, bad = %v, want 53 and 4", events, bad)
+	}
+
+	if events, bad, err := slos.events(slo, nil, from, sloNow); err != nil || events != 0 || bad != 0 {
+		t.Errorf("events of no servers = %v, %v, %v", events, bad, err)
+	}
+}
+
+func TestFractionWithin(t *testing.T) {
+	rollup := models.HistoryRollup{LatencyCount: 100, Min: intPtr(100), P50: intPtr(150), P95: intPtr(250), P99: intPtr(400), Max: intPtr(500)}
+	tests := []struct {
+		threshold int
+		want      float64
+	}{
+		{50, 0},
+		{100, 0},
+		{125, 0.25},
+		{150, 0.5},
+		{200, 0.725},
+		{250, 0.95},
+		{450, 0.995},
+		{500, 1},
+		{1000, 1},
+	}
+	for _, test := range tests {
+		if got := fractionWithin(rollup, test.threshold); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("fractionWithin(%d) = %v, want %v", test.threshold, got, test.want)
+		}
+	}
+
+	if got := fractionWithin(models.HistoryRollup{}, 100); got != 1 {
+		t.Errorf("fractionWithin without response times = %v, want 1", got)
+	}
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+// ProxyDirect disables the global default proxy for a monitor
+const ProxyDirect = "direct"
+
+// TransportManager shares HTTP transports between checks so connections can be reused.
+// Transports are keyed by the settings that affect how connections are made, so monitors
+// with identical settings share a pool.
+type TransportManager struct {
+	certificates *CertificateService
+	transports   map[string]*http.Transport
+	users        map[string]map[transportUser]bool
+	keys         map[transportUser]string
+	mu           sync.Mutex
+}
+
+// transportUser identifies a check using a transport, dual-stack monitors use one per address family
+type transportUser struct {
+	serverID int
+	family   string
+}
+
+// NewTransportManager creates a new transport manager instance
+func NewTransportManager(certificates *CertificateService) *TransportManager {
+	return &TransportManager{
+		certificates: certificates,
+		transports:   make(map[string]*http.Transport),
+		users:        make(map[string]map[transportUser]bool),
+		keys:         make(map[transportUser]string),
+	}
+}
+
+// Client returns an HTTP client for checking a server
+func (m *TransportManager) Client(server models.Server) (*http.Client, error) {
+	transport, err := m.transport(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   time.Duration(server.Timeout) * time.Millisecond,
+		Transport: transport,
+	}, nil
+}
+
+// ProbeClient returns an HTTP client for a one-off request that connects to a server exactly
+// like its checks, through its proxy, resolver, pinned IP and source address, but with its TLS
+// configuration adjusted by configure. Connections are not kept.
+func (m *TransportManager) ProbeClient(server models.Server, configure func(*tls.Config)) (*http.Client, error) {
+	transport, err := m.build(server)
+	if err != nil {
+		return nil, err
+	}
+	transport.DisableKeepAlives = true
+	configure(transport.TLSClientConfig)
+
+	return &http.Client{
+		Timeout:   time.Duration(server.Timeout) * time.Millisecond,
+		Transport: transport,
+	}, nil
+}
+
+// Release detaches a server from its transports and closes pools once unused
+func (m *TransportManager) Release(serverID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for user := range m.keys {
+		if user.serverID == serverID {
+			m.release(user)
+		}
+	}
+}
+
+// Retain detaches a server from the transports of address families it is no longer checked
+// over, such as those of IPv4 and IPv6 after a dual-stack monitor switches to auto
+func (m *TransportManager) Retain(serverID int, families ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for user := range m.keys {
+		if user.serverID == serverID && !slices.Contains(families, user.family) {
+			m.release(user)
+		}
+	}
+}
+
+// Close closes all idle connections of every transport
+func (m *TransportManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, transport := range m.transports {
+		transport.CloseIdleConnections()
+		delete(m.transports, key)
+	}
+	m.users = make(map[string]map[transportUser]bool)
+	m.keys = make(map[transportUser]string)
+}
+
+// transport returns the shared transport for a server, building it on first use
+func (m *TransportManager) transport(server models.Server) (*http.Transport, error) {
+	// Unsaved monitors get a throwaway transport that keeps no idle connections
+	if server.ID == 0 {
+		transport, err := m.build(server)
+		if err != nil {
+			return nil, err
+		}
+		transport.DisableKeepAlives = true
+		return transport, nil
+	}
+
+	key := transportKey(server)
+	user := transportUser{serverID: server.ID, family: server.AddressFamily}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if previous, exists := m.keys[user]; exists && previous != key {
+		m.release(user)
+	}
+
+	transport, exists := m.transports[key]
+	if !exists {
+		var err error
+		transport, err = m.build(server)
+		if err != nil {
+			return nil, err
+		}
+		m.transports[key] = transport
+		m.users[key] = make(map[transportUser]bool)
+	}
+
+	m.users[key][user] = true
+	m.keys[user] = key
+	return transport, nil
+}
+
+// release detaches a user from its transport, the caller must hold the lock
+func (m *TransportManager) release(user transportUser) {
+	key, exists := m.keys[user]
+	if !exists {
+		return
+	}
+	delete(m.keys, user)
+	delete(m.users[key], user)
+
+	if len(m.users[key]) == 0 {
+		if transport, exists := m.transports[key]; exists {
+			transport.CloseIdleConnections()
+		}
+		delete(m.transports, key)
+		delete(m.users, key)
+	}
+}
+
+// build creates a new transport with the network and TLS settings of a server
+func (m *TransportManager) build(server models.Server) (*http.Transport, error) {
+	transport, err := newTransport(server)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := m.certificates.TLSConfig(server)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// Fresh mode measures a cold connection, including the TCP and TLS handshakes, on every check
+	if server.ConnectionMode == models.ConnectionFresh {
+		transport.DisableKeepAlives = true
+	}
+	return transport, nil
+}
+
+// transportKey identifies the settings that require a dedicated transport
+func transportKey(server models.Server) string {
+	id := func(ref *int) int {
+		if ref == nil {
+			return 0
+		}
+		return *ref
+	}
+
+	host := ""
+	if target, err := url.Parse(server.URL); err == nil {
+		host = target.Hostname()
+	}
+
+	return fmt.Sprintf("%s|%s|%s|%d|%s|%s|%s|%s|%d|%d|%t",
+		host,
+		server.AddressFamily,
+		server.ConnectionMode,
+		server.Timeout,
+		server.ProxyURL,
+		server.ResolverAddress,
+		server.ResolveIP,
+		server.SourceAddress,
+		id(server.ClientCertID),
+		id(server.CABundleID),
+		server.InsecureSkipVerify,
+	)
+}
+
+// newTransport builds an HTTP transport honouring the proxy, DNS and source address settings of a server
+func newTransport(server models.Server) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(server.Timeout) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if server.SourceAddress != "" {
+		ip, err := sourceIP(server.SourceAddress, server.AddressFamily)
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	if server.ResolverAddress != "" {
+		dialer.Resolver = newResolver(server.ResolverAddress, dialer.Timeout)
+	}
+
+	// Restrict connections to a single address family when requested
+	dial := dialer.DialContext
+	if network := familyNetwork(server.AddressFamily); network != "" {
+		dial = func(ctx context.Context, _, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+
+	// Pin the target host to a fixed IP while keeping the original Host header and SNI
+	if server.ResolveIP != "" {
+		ip := net.ParseIP(server.ResolveIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid resolve IP %q", server.ResolveIP)
+		}
+		target, err := url.Parse(server.URL)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err == nil && host == target.Hostname() {
+				address = net.JoinHostPort(ip.String(), port)
+			}
+			return dial(ctx, network, address)
+		}
+	}
+
+	proxyURL := server.ProxyURL
+	if proxyURL == "" {
+		proxyURL = config.DefaultProxy
+	}
+	switch proxyURL {
+	case "", ProxyDirect:
+		transport.Proxy = nil
+	default:
+		parsed, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", parsed.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(parsed)
+	}
+
+	return transport, nil
+}
+
+// familyNetwork returns the dial network forcing an address family, or "" for any family
+func familyNetwork(family string) string {
+	switch family {
+	case models.FamilyIPv4:
+		return "tcp4"
+	case models.FamilyIPv6:
+		return "tcp6"
+	default:
+		return ""
+	}
+}
+
+// newResolver returns a resolver that sends DNS queries to the given server
+func newResolver(address string, timeout time.Duration) *net.Resolver {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// sourceIP resolves a source address given either as an IP or as a network interface name
+// to an IP of the address family of a check. Link-local addresses of an interface are
+// skipped as they cannot reach targets on other networks.
+func sourceIP(source, family string) (net.IP, error) {
+	network := familyNetwork(family)
+	if ip := net.ParseIP(source); ip != nil {
+		if !networkAccepts(network, ip) {
+			return nil, fmt.Errorf("source address %s is not an %s address", source, family)
+		}
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source address %q: %w", source, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || !networkAccepts(network, ipNet.IP) {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	if network != "" {
+		return nil, fmt.Errorf("interface %s has no usable %s addresses", source, family)
+	}
+	return nil, fmt.Errorf("interface %s has no usable addresses", source)
+}
+
+// networkAccepts reports whether an IP belongs to the address family of a dial network
+// returned by familyNetwork
+func networkAccepts(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4":
+		return ip.To4() != nil
+	case "tcp6":
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// validateNetwork ensures the proxy, DNS, pinned IP, source address and address family
+// settings of a server work together for every family it is checked over. A proxy resolves
+// and connects to the target itself, so a resolver or pinned IP would be ignored.
+func validateNetwork(server *models.Server) error {
+	proxyURL := server.ProxyURL
+	if proxyURL == "" {
+		proxyURL = config.DefaultProxy
+	}
+	if proxyURL != "" && proxyURL != ProxyDirect {
+		if server.ResolverAddress != "" {
+			return fmt.Errorf("%w: resolverAddress does not apply to checks through a proxy", ErrInvalidNetwork)
+		}
+		if server.ResolveIP != "" {
+			return fmt.Errorf("%w: resolveIp does not apply to checks through a proxy", ErrInvalidNetwork)
+		}
+	}
+
+	for _, family := range checkFamilies(*server) {
+		if server.ResolveIP != "" {
+			ip := net.ParseIP(server.ResolveIP)
+			if ip == nil {
+				return fmt.Errorf("%w: invalid resolve IP %q", ErrInvalidNetwork, server.ResolveIP)
+			}
+			if !networkAccepts(familyNetwork(family), ip) {
+				return fmt.Errorf("%w: resolve IP %s is not an %s address", ErrInvalidNetwork, server.ResolveIP, family)
+			}
+		}
+		if server.SourceAddress != "" {
+			if _, err := sourceIP(server.SourceAddress, family); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidNetwork, err)
+			}
+		}
+	}
+	return nil
+}
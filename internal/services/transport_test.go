@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/waltertaya/server_check_bd/internal/config"
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+func TestValidateNetwork(t *testing.T) {
+	tests := []struct {
+		name         string
+		server       models.Server
+		defaultProxy string
+		valid        bool
+	}{
+		{"no settings", models.Server{AddressFamily: models.FamilyAuto}, "", true},
+		{"proxy with source address", models.Server{ProxyURL: "socks5://proxy:1080", SourceAddress: "127.0.0.1", AddressFamily: models.FamilyAuto}, "", true},
+		{"proxy with resolver", models.Server{ProxyURL: "socks5://proxy:1080", ResolverAddress: "10.0.0.2:53", AddressFamily: models.FamilyAuto}, "", false},
+		{"proxy with resolve IP", models.Server{ProxyURL: "http://proxy:3128", ResolveIP: "10.0.1.15", AddressFamily: models.FamilyAuto}, "", false},
+		{"default proxy with resolve IP", models.Server{ResolveIP: "10.0.1.15", AddressFamily: models.FamilyAuto}, "http://proxy:3128", false},
+		{"direct despite default proxy", models.Server{ProxyURL: ProxyDirect, ResolveIP: "10.0.1.15", AddressFamily: models.FamilyAuto}, "http://proxy:3128", true},
+		{"IPv4 resolve IP over IPv4", models.Server{ResolveIP: "10.0.1.15", AddressFamily: models.FamilyIPv4}, "", true},
+		{"IPv4 resolve IP over IPv6", models.Server{ResolveIP: "10.0.1.15", AddressFamily: models.FamilyIPv6}, "", false},
+		{"IPv6 resolve IP over IPv4", models.Server{ResolveIP: "2001:db8::15", AddressFamily: models.FamilyIPv4}, "", false},
+		{"IPv6 resolve IP over IPv6", models.Server{ResolveIP: "2001:db8::15", AddressFamily: models.FamilyIPv6}, "", true},
+		{"resolve IP over both families", models.Server{ResolveIP: "10.0.1.15", AddressFamily: models.FamilyBoth}, "", false},
+		{"IPv4 source over IPv6", models.Server{SourceAddress: "127.0.0.1", AddressFamily: models.FamilyIPv6}, "", false},
+		{"IPv4 source over both families", models.Server{SourceAddress: "127.0.0.1", AddressFamily: models.FamilyBoth}, "", false},
+		{"loopback interface over IPv4", models.Server{SourceAddress: "lo", AddressFamily: models.FamilyIPv4}, "", true},
+		{"unknown interface", models.Server{SourceAddress: "missing0", AddressFamily: models.FamilyAuto}, "", false},
+	}
+
+	defaultProxy := config.DefaultProxy
+	t.Cleanup(func() { config.DefaultProxy = defaultProxy })
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.DefaultProxy = test.defaultProxy
+			err := validateNetwork(&test.server)
+			if test.valid && err != nil {
+				t.Errorf("validateNetwork: %v", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidNetwork) {
+				t.Errorf("validateNetwork = %v, want ErrInvalidNetwork", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+	"golang.org/x/net/http/httpguts"
+)
+
+// webhookEventVersion is the version of the webhook event format, raised on incompatible changes
+const webhookEventVersion = 1
+
+// Headers of webhook requests. The signature is the hex HMAC-SHA256 of the timestamp, a dot and
+// the body, keyed with the channel secret, so receivers can reject old or replayed requests.
+const (
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookIDHeader        = "X-Webhook-Id"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// minWebhookSecretLength is the shortest accepted signing secret
+const minWebhookSecretLength = 16
+
+// webhookEvent is the body of a webhook request
+type webhookEvent struct {
+	Version    int              `json:"version"`
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Level      string           `json:"level"`
+	Title      string           `json:"title"`
+	Message    string           `json:"message"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       webhookEventData `json:"data"`
+}
+
+// webhookEventData holds what the event is about, fields that do not apply are left out
+type webhookEventData struct {
+	Server      *models.NotificationServer      `json:"server,omitempty"`
+	Incident    *models.NotificationIncident    `json:"incident,omitempty"`
+	SLO         *models.NotificationSLO         `json:"slo,omitempty"`
+	Certificate *models.NotificationCertificate `json:"certificate,omitempty"`
+}
+
+// webhookSettings are the settings of a webhook channel
+type webhookSettings struct {
+	URL     string            `json:"url"`
+	Secret  string            `json:"secret"`
+	Headers map[string]string `json:"headers"` // Sent with every request
+}
+
+// webhookNotifier posts signed JSON events to a URL
+type webhookNotifier struct {
+	httpDelivery
+	settings webhookSettings
+}
+
+// newWebhookNotifier creates a webhook notifier
+func newWebhookNotifier(config json.RawMessage) (Notifier, error) {
+	var settings webhookSettings
+	if err := decodeNotifierConfig(config, &settings); err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhookURL("url", settings.URL); err != nil {
+		return nil, err
+	}
+	if len(settings.Secret) < minWebhookSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+	for name, value := range settings.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid header %q", name)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if canonical == "Content-Type" || canonical == "Content-Length" || canonical == "Host" || strings.HasPrefix(canonical, "X-Webhook-") {
+			return nil, fmt.Errorf("header %q is set by the webhook", name)
+		}
+	}
+	return &webhookNotifier{settings: settings}, nil
+}
+
+// Send posts a notification as a signed event
+func (n *webhookNotifier) Send(ctx context.Context, notification models.Notification) error {
+	if notification.ID == "" {
+		return errors.New("notification has no event ID")
+	}
+
+	body, err := json.Marshal(webhookEvent{
+		Version:    webhookEventVersion,
+		ID:         notification.ID,
+		Type:       notification.Event,
+		Level:      notification.Level,
+		Title:      notification.Title,
+		Message:    notification.Message,
+		OccurredAt: notification.At,
+		Data: webhookEventData{
+			Server:      notification.Server,
+			Incident:    notification.Incident,
+			SLO:         notification.SLO,
+			Certificate: notification.Certificate,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	for name, value := range n.settings.Headers {
+		headers[name] = value
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers["Content-Type"] = "application/json"
+	headers[webhookEventHeader] = notification.Event
+	headers[webhookIDHeader] = notification.ID
+	headers[webhookTimestampHeader] = timestamp
+	headers[webhookSignatureHeader] = "sha256=" + signWebhook(n.settings.Secret, timestamp, body)
+
+	_, err = n.post(ctx, n.settings.URL, headers, body)
+	return err
+}
+
+// signWebhook returns the hex HMAC-SHA256 of a timestamped webhook body
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
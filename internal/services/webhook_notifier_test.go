@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/waltertaya/server_check_bd/internal/models"
+)
+
+func TestWebhookSignsEvents(t *testing.T) {
+	const secret = "0123456789abcdef"
+	standIn := newHTTPStandIn(t, httpResponse{204, ""})
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":     standIn.URL + "/events",
+		"secret":  secret,
+		"headers": map[string]string{"X-Team": "ops"},
+	})
+	notifier, err := newNotifier(models.ChannelWebhook, config)
+	if err != nil {
+		t.Fatalf("newNotifier: %v", err)
+	}
+
+	notification := sampleNotification()
+	before := time.Now().Unix()
+	if err := notifier.Send(context.Background(), notification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	request := standIn.next(t)
+
+	timestamp := request.Header.Get(webhookTimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sent < before || sent > time.Now().Unix() {
+		t.Errorf("timestamp = %q, want the time of sending", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(request.Body)
+	if signature, want := request.Header.Get(webhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+
+	for header, want := range map[string]string{
+		"Content-Type":     "application/json",
+		webhookEventHeader: notification.Event,
+		webhookIDHeader:    notification.ID,
+		"X-Team":           "ops",
+	} {
+		if value := request.Header.Get(header); value != want {
+			t.Errorf("%s = %q, want %q", header, value, want)
+		}
+	}
+
+	var event webhookEvent
+	if err := json.Unmarshal(request.Body, &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event.Version != webhookEventVersion || event.ID != notification.ID || event.Type != notification.Event || event.Title != notification.Title {
+		t.Errorf("event = %+v", event)
+	}
+	if event.Data.Server == nil || event.Data.Server.Name != notification.Server.Name || event.Data.Incident == nil || event.Data.Incident.ID != notification.Incident.ID {
+		t.Errorf("event data = %+v", event.Data)
+	}
+	if status := notifier.(ResponseNotifier).ResponseStatus(); status == nil || *status != 204 {
+		t.Errorf("response status = %v, want 204", status)
+	}
+}
+
+func TestWebhookRejectsUnsignableSettings(t *testing.T) {
+	for name, settings := range map[string]map[string]interface{}{
+		"short secret":    {"url": "https://example.com/events", "secret": "short"},
+		"reserved header": {"url": "https://example.com/events", "secret": "0123456789abcdef", "headers": map[string]string{"X-Webhook-Signature": "forged"}},
+		"invalid url":     {"url": "ftp://example.com/events", "secret": "0123456789abcdef"},
+	} {
+		config, _ := json.Marshal(settings)
+		if _, err := newNotifier(models.ChannelWebhook, config); err == nil {
+			t.Errorf("%s: settings accepted", name)
+		}
+	}
+}